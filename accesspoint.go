@@ -0,0 +1,64 @@
+package s3store
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// isAccessPoint reports whether bucket is an S3 Access Point ARN
+// rather than a plain bucket name. Other S3 ARNs, such as those of
+// buckets or objects, are not access points.
+func isAccessPoint(bucket string) bool {
+	if !arn.IsARN(bucket) {
+		return false
+	}
+	a, err := arn.Parse(bucket)
+	if err != nil {
+		return false
+	}
+	// Resources are "accesspoint/<name>" and, on Outposts,
+	// "outpost/<id>/accesspoint/<name>", with "/" or ":" as separator.
+	fields := strings.FieldsFunc(a.Resource, func(r rune) bool { return r == '/' || r == ':' })
+	switch a.Service {
+	case "s3":
+		return len(fields) == 2 && fields[0] == "accesspoint"
+	case "s3-outposts":
+		return len(fields) == 4 && fields[0] == "outpost" && fields[2] == "accesspoint"
+	}
+	return false
+}
+
+// isMultiRegionAccessPoint reports whether bucket is a Multi-Region
+// Access Point ARN. These carry no region and end in ".mrap".
+func isMultiRegionAccessPoint(bucket string) bool {
+	if !isAccessPoint(bucket) {
+		return false
+	}
+	a, _ := arn.Parse(bucket)
+	return a.Region == "" && strings.HasSuffix(a.Resource, ".mrap")
+}
+
+// useAccessPoint adjusts the client for access point addressing. Access
+// points are reached through their own virtual-hosted endpoint, so
+// path-style addressing and transfer acceleration cannot be used, and
+// requests are signed for the region embedded in the ARN.
+func useAccessPoint(o *s3.Options) {
+	o.UsePathStyle = false
+	o.UseAccelerate = false
+	o.UseARNRegion = true
+}
+
+// useMultiRegionAccessPoint adjusts the client for Multi-Region Access
+// Points. Requests are routed to the closest healthy region by S3 and
+// signed with SigV4a, which the client only does while multi-region
+// access points are enabled. Dual-stack and FIPS endpoints are not
+// available for them.
+func useMultiRegionAccessPoint(o *s3.Options) {
+	useAccessPoint(o)
+	o.DisableMultiRegionAccessPoints = false
+	o.EndpointOptions.UseDualStackEndpoint = aws.DualStackEndpointStateUnset
+	o.EndpointOptions.UseFIPSEndpoint = aws.FIPSEndpointStateUnset
+}
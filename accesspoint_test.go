@@ -0,0 +1,68 @@
+package s3store_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+// clientOptions returns the options of the client a store
+// for bucket is given with opts.
+func clientOptions(t *testing.T, bucket string, opts ...s3store.Option) s3.Options {
+	t.Helper()
+	cfg := aws.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("test", "test", ""),
+	}
+	return s3store.NewS3StoreFromConfig(cfg, bucket, opts...).Client().Options()
+}
+
+func TestAccessPoint(t *testing.T) {
+	opts := []s3store.Option{s3store.WithPathStyle(), s3store.WithTransferAcceleration()}
+	for _, bucket := range []string{
+		"arn:aws:s3:us-west-2:123456789012:accesspoint/certs",
+		"arn:aws:s3:us-west-2:123456789012:accesspoint:certs",
+		"arn:aws:s3-outposts:us-west-2:123456789012:outpost/op-01ac5d28a6a232904/accesspoint/certs",
+	} {
+		o := clientOptions(t, bucket, opts...)
+		if o.UsePathStyle || o.UseAccelerate || !o.UseARNRegion {
+			t.Errorf("%s: UsePathStyle = %v, UseAccelerate = %v, UseARNRegion = %v, want virtual-hosted ARN region addressing",
+				bucket, o.UsePathStyle, o.UseAccelerate, o.UseARNRegion)
+		}
+	}
+	for _, bucket := range []string{
+		"certs",
+		"arn:aws:s3:::certs",
+		"arn:aws:s3:::certs/certificates/example.com.crt",
+		"arn:aws:s3-outposts:us-west-2:123456789012:outpost/op-01ac5d28a6a232904",
+		"arn:aws:sns:us-west-2:123456789012:accesspoint/certs",
+	} {
+		o := clientOptions(t, bucket, opts...)
+		if !o.UsePathStyle || !o.UseAccelerate || o.UseARNRegion {
+			t.Errorf("%s: addressing adjusted as for an access point", bucket)
+		}
+	}
+}
+
+func TestMultiRegionAccessPoint(t *testing.T) {
+	const mrap = "arn:aws:s3::123456789012:accesspoint/mfzwi23gnjvgw.mrap"
+	o := clientOptions(t, mrap, s3store.WithPathStyle(), s3store.WithDualStack(), s3store.WithFIPS())
+	if o.UsePathStyle || !o.UseARNRegion || o.DisableMultiRegionAccessPoints {
+		t.Errorf("UsePathStyle = %v, UseARNRegion = %v, DisableMultiRegionAccessPoints = %v",
+			o.UsePathStyle, o.UseARNRegion, o.DisableMultiRegionAccessPoints)
+	}
+	if o.EndpointOptions.UseDualStackEndpoint != aws.DualStackEndpointStateUnset ||
+		o.EndpointOptions.UseFIPSEndpoint != aws.FIPSEndpointStateUnset {
+		t.Errorf("EndpointOptions = %+v, want dual-stack and FIPS unset", o.EndpointOptions)
+	}
+
+	// Access points in a region keep dual-stack and FIPS endpoints.
+	o = clientOptions(t, "arn:aws:s3:us-west-2:123456789012:accesspoint/certs", s3store.WithDualStack(), s3store.WithFIPS())
+	if o.EndpointOptions.UseDualStackEndpoint != aws.DualStackEndpointStateEnabled ||
+		o.EndpointOptions.UseFIPSEndpoint != aws.FIPSEndpointStateEnabled {
+		t.Errorf("EndpointOptions of a regional access point = %+v", o.EndpointOptions)
+	}
+}
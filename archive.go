@@ -0,0 +1,184 @@
+package s3store
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ArchiveOption configures ExportTar and ImportTar.
+type ArchiveOption func(*archiveConfig)
+
+type archiveConfig struct {
+	key []byte
+}
+
+// ArchiveEncryptionKey encrypts the archive with AES-GCM using key, which
+// must be 16, 24 or 32 bytes long. The same key is needed to import it.
+func ArchiveEncryptionKey(key []byte) ArchiveOption {
+	return func(c *archiveConfig) {
+		c.key = key
+	}
+}
+
+// ExportTar writes every key in the store to w as a gzipped tar
+// archive, using the storage keys as file names. Like with Snapshot,
+// locks, the trash and snapshots are not included. When encrypted, the
+// archive is assembled in memory before being written.
+func (s *S3Store) ExportTar(ctx context.Context, w io.Writer, opts ...ArchiveOption) error {
+	var cfg archiveConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	out := w
+	var buf bytes.Buffer
+	if cfg.key != nil {
+		out = &buf
+	}
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	namePrefix := s.Filename(ctx, "")
+	if namePrefix != "" {
+		namePrefix += "/"
+	}
+	seen := make(map[string]bool)
+	err := s.walkNames(ctx, namePrefix, func(obj types.Object) error {
+		name := aws.ToString(obj.Key)
+		key := s.keyName(name)
+		if !s.snapshotted(name) || seen[key] {
+			return nil
+		}
+		seen[key] = true
+		value, err := s.Load(ctx, key)
+		if err != nil {
+			return fmt.Errorf("exporting %s: %w", s.logKey(key), err)
+		}
+		hdr := &tar.Header{
+			Name:    key,
+			Mode:    0600,
+			Size:    int64(len(value)),
+			ModTime: aws.ToTime(obj.LastModified),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err = tw.Write(value)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	if cfg.key == nil {
+		return nil
+	}
+	sealed, err := sealArchive(cfg.key, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(sealed)
+	return err
+}
+
+// ImportTar restores every file of an archive written by ExportTar,
+// storing it under its original key.
+func (s *S3Store) ImportTar(ctx context.Context, r io.Reader, opts ...ArchiveOption) error {
+	var cfg archiveConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if cfg.key != nil {
+		sealed, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		plain, err := openArchive(cfg.key, sealed)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(plain)
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		value, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if err := s.Store(ctx, hdr.Name, value); err != nil {
+			return fmt.Errorf("importing %s: %w", s.logKey(hdr.Name), err)
+		}
+	}
+}
+
+// sealArchive encrypts b with AES-GCM, prefixing the random nonce.
+func sealArchive(key, b []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, b, nil), nil
+}
+
+// openArchive decrypts an archive produced by sealArchive.
+func openArchive(key, b []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < gcm.NonceSize() {
+		return nil, errors.New("archive too short")
+	}
+	nonce, ciphertext := b[:gcm.NonceSize()], b[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting archive: %w", err)
+	}
+	return plain, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
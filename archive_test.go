@@ -0,0 +1,76 @@
+package s3store_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+func TestExportImportTar(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	s := newTestStoreOn(t, mem, s3store.WithSoftDelete(24*time.Hour))
+	staging := newTestStoreOn(t, mem, s3store.WithPrefix("certmagic-staging"))
+	mustStore(t, s, "certificates/acme/a.com/a.com.crt", "top", "deleted")
+	mustStore(t, staging, "staging-only")
+	if err := s.Delete(ctx, "deleted"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Snapshot(ctx, "pre"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Lock(ctx, "l"); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Unlock(ctx, "l")
+	want := []string{"certificates/acme/a.com/a.com.crt", "top"}
+
+	key := bytes.Repeat([]byte{1}, 32)
+	for _, opts := range [][]s3store.ArchiveOption{nil, {s3store.ArchiveEncryptionKey(key)}} {
+		var archive bytes.Buffer
+		if err := s.ExportTar(ctx, &archive, opts...); err != nil {
+			t.Fatal(err)
+		}
+		if opts != nil && bytes.Contains(archive.Bytes(), []byte("a.com.crt")) {
+			t.Error("encrypted archive contains a key name in plain text")
+		}
+		dst, _ := newTestStore(t)
+		if err := dst.ImportTar(ctx, &archive, opts...); err != nil {
+			t.Fatal(err)
+		}
+		keys, err := dst.List(ctx, "", true)
+		if err != nil || !reflect.DeepEqual(keys, want) {
+			t.Fatalf("imported keys = %q, %v, want %q", keys, err, want)
+		}
+		for _, k := range keys {
+			if v, err := dst.Load(ctx, k); err != nil || string(v) != k {
+				t.Errorf("imported %s = %q, %v", k, v, err)
+			}
+		}
+	}
+}
+
+func TestImportTarWrongKey(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	mustStore(t, s, "k")
+	var archive bytes.Buffer
+	if err := s.ExportTar(ctx, &archive, s3store.ArchiveEncryptionKey(bytes.Repeat([]byte{1}, 32))); err != nil {
+		t.Fatal(err)
+	}
+	dst, _ := newTestStore(t)
+	if err := dst.ImportTar(ctx, &archive, s3store.ArchiveEncryptionKey(bytes.Repeat([]byte{2}, 32))); err == nil {
+		t.Fatal("ImportTar with the wrong key succeeded")
+	}
+	if dst.Exists(ctx, "k") {
+		t.Fatal("ImportTar with the wrong key stored keys")
+	}
+	if err := dst.ImportTar(ctx, bytes.NewReader([]byte("short")), s3store.ArchiveEncryptionKey(bytes.Repeat([]byte{1}, 32))); err == nil {
+		t.Fatal("ImportTar of a truncated archive succeeded")
+	}
+}
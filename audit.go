@@ -0,0 +1,155 @@
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// AuditEvent records a single mutating operation.
+type AuditEvent struct {
+	Time     time.Time `json:"time"`
+	Op       string    `json:"op"`
+	Key      string    `json:"key"`
+	Identity string    `json:"identity"`
+	Host     string    `json:"host"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// AuditSink receives an AuditEvent for every Store, Delete, Lock and
+// Unlock (and their bulk and streaming variants) made through the store.
+// Record must be safe for concurrent use.
+type AuditSink interface {
+	Record(ctx context.Context, event AuditEvent) error
+}
+
+// WithAuditSink sends audit events to sink, so compliance teams can
+// answer who touched a private key and when. Use NewS3AuditSink to keep
+// the audit log in the bucket itself.
+func WithAuditSink(sink AuditSink) Option {
+	return func(s *S3Store) {
+		s.auditSink = sink
+	}
+}
+
+// audit records op on key with its outcome. Failures to
+// record are logged and don't affect the operation.
+func (s *S3Store) audit(ctx context.Context, op, key string, opErr error) {
+	if s.auditSink == nil {
+		return
+	}
+	host, _ := os.Hostname()
+	event := AuditEvent{
+		Time:     time.Now().UTC(),
+		Op:       op,
+		Key:      key,
+		Identity: s.callerIdentity(ctx),
+		Host:     host,
+	}
+	if opErr != nil {
+		event.Error = opErr.Error()
+	}
+	if err := s.auditSink.Record(context.WithoutCancel(ctx), event); err != nil {
+		log.Printf("[ERROR][%s] Recording audit event for %s of '%s': %v", s, op, s.logKey(key), err)
+	}
+}
+
+// callerIdentity returns the ARN of the AWS principal the store's
+// requests are signed as. It is empty if the identity cannot be
+// determined; failed lookups are retried with the next event rather
+// than leaving the rest of the log without an identity.
+func (s *S3Store) callerIdentity(ctx context.Context) string {
+	id := s.identity
+	id.mu.Lock()
+	defer id.mu.Unlock()
+	if id.known {
+		return id.arn
+	}
+	client, ok := s.client.(*s3.Client)
+	if !ok {
+		id.known = true
+		return ""
+	}
+	opts := client.Options()
+	stsClient := sts.New(sts.Options{
+		Region:      opts.Region,
+		Credentials: opts.Credentials,
+		HTTPClient:  opts.HTTPClient,
+	})
+	out, err := stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		log.Printf("[WARNING][%s] Determining caller identity for audit log: %v", s, err)
+		return ""
+	}
+	id.arn, id.known = aws.ToString(out.Arn), true
+	return id.arn
+}
+
+// identity caches the caller identity of a store once it is known.
+type identity struct {
+	mu    sync.Mutex
+	arn   string
+	known bool
+}
+
+// auditDir is the directory under the storage prefix
+// S3AuditSink writes the audit log to.
+const auditDir = "audit"
+
+// auditLogged reports whether key lies in the audit log while prefix
+// doesn't. The audit log is not part of the store's data, so listings,
+// snapshots, exports and DeletePrefix leave it out unless they are
+// of the audit directory itself.
+func auditLogged(key, prefix string) bool {
+	return strings.HasPrefix(key, auditDir+"/") && !strings.HasPrefix(prefix+"/", auditDir+"/")
+}
+
+// S3AuditSink writes each audit event as its own JSON object under the
+// "audit/<date>/" directory of a store, which List and the other key
+// listings only include when asked for that directory. Objects are written with
+// If-None-Match so they are never overwritten, making the log
+// append-only; pair it with S3 Object Lock or a bucket policy denying
+// deletes under that directory for tamper resistance.
+type S3AuditSink struct {
+	store *S3Store
+}
+
+// NewS3AuditSink returns a sink writing to store's bucket and prefix.
+func NewS3AuditSink(store *S3Store) *S3AuditSink {
+	return &S3AuditSink{store: store}
+}
+
+// Record writes event to the bucket.
+func (a *S3AuditSink) Record(ctx context.Context, event AuditEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	key := path.Join(auditDir, event.Time.Format("2006-01-02"),
+		event.Time.Format("150405.000000000")+"-"+hex.EncodeToString(suffix)+".json")
+	_, err = a.store.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      a.store.bucket,
+		Key:         aws.String(a.store.Filename(ctx, key)),
+		Body:        bytes.NewReader(b),
+		ContentType: aws.String("application/json"),
+		IfNoneMatch: aws.String("*"),
+		ACL:         a.store.acl,
+	})
+	return err
+}
@@ -0,0 +1,166 @@
+package s3store_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+func TestS3AuditSink(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	logStore := newTestStoreOn(t, mem, s3store.WithPrefix("logs"))
+	s := newTestStoreOn(t, mem, s3store.WithAuditSink(s3store.NewS3AuditSink(logStore)))
+	mustStore(t, s, "k")
+	if err := s.Delete(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+
+	var ops []string
+	for _, name := range objectNames(t, mem, testBucket) {
+		if !strings.HasPrefix(name, "logs/audit/") {
+			continue
+		}
+		out, err := mem.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(testBucket), Key: aws.String(name)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(out.Body)
+		var event s3store.AuditEvent
+		if err := json.Unmarshal(b, &event); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		ops = append(ops, event.Op+" "+event.Key)
+	}
+	if strings.Join(ops, ",") != "store k,delete k" {
+		t.Fatalf("audit log = %q, want a store and a delete of k", ops)
+	}
+}
+
+func TestAuditLogExcluded(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	s := newTestStoreOn(t, mem, s3store.WithAuditSink(s3store.NewS3AuditSink(newTestStoreOn(t, mem))))
+	mustStore(t, s, "k")
+	logged := func() []string {
+		t.Helper()
+		keys, err := s.List(ctx, "audit", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return keys
+	}
+	if len(logged()) != 1 {
+		t.Fatalf("audit log = %q, want the store of k", logged())
+	}
+
+	for _, recursive := range []bool{false, true} {
+		if keys, err := s.List(ctx, "", recursive); err != nil || !reflect.DeepEqual(keys, []string{"k"}) {
+			t.Errorf("List(recursive=%v) = %q, %v, want the audit log left out", recursive, keys, err)
+		}
+	}
+	var iterated []string
+	for key, err := range s.Iter(ctx, "") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		iterated = append(iterated, key)
+	}
+	if !reflect.DeepEqual(iterated, []string{"k"}) {
+		t.Errorf("Iter = %q, want the audit log left out", iterated)
+	}
+
+	id, err := s.Snapshot(ctx, "pre")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys, _ := s.List(ctx, "snapshots/"+id, true); len(keys) != 1 || !strings.HasSuffix(keys[0], "/k") {
+		t.Errorf("snapshot = %q, want only k", keys)
+	}
+	var archive bytes.Buffer
+	if err := s.ExportTar(ctx, &archive); err != nil {
+		t.Fatal(err)
+	}
+	dst, _ := newTestStore(t)
+	if err := dst.ImportTar(ctx, &archive); err != nil {
+		t.Fatal(err)
+	}
+	if keys, _ := dst.List(ctx, "", true); !reflect.DeepEqual(keys, []string{"k"}) {
+		t.Errorf("exported keys = %q, want the audit log left out", keys)
+	}
+
+	before := len(logged())
+	if err := s.DeletePrefix(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	if s.Exists(ctx, "k") {
+		t.Fatal("DeletePrefix kept k")
+	}
+	if n := len(logged()); n <= before {
+		t.Fatalf("audit log has %d events after DeletePrefix, want the %d before and more", n, before)
+	}
+}
+
+// stsTransport answers STS requests itself, failing the first fails
+// GetCallerIdentity calls, and sends every other request on.
+type stsTransport struct {
+	fails int32
+	calls atomic.Int32
+}
+
+func (s *stsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasPrefix(req.URL.Host, "sts.") {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	status, body := http.StatusOK, `<GetCallerIdentityResponse><GetCallerIdentityResult>`+
+		`<Arn>arn:aws:iam::123456789012:user/certmagic</Arn></GetCallerIdentityResult></GetCallerIdentityResponse>`
+	if s.calls.Add(1) <= s.fails {
+		status, body = http.StatusForbidden, `<ErrorResponse><Error><Code>AccessDenied</Code></Error></ErrorResponse>`
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"text/xml"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestAuditIdentity(t *testing.T) {
+	f := newFakeS3(t)
+	sts := &stsTransport{fails: 1}
+	client := s3.New(s3.Options{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+		BaseEndpoint: aws.String(f.URL),
+		UsePathStyle: true,
+		HTTPClient:   &http.Client{Transport: sts},
+	})
+	var sink recordingSink
+	s3store.NewS3StoreForTesting(f.URL, "certs") // creates the bucket
+	s := s3store.NewS3StoreFromClient(client, "certs", s3store.WithAllowHTTP(), s3store.WithAuditSink(&sink))
+	mustStore(t, s, "a", "b", "c")
+
+	var identities []string
+	for _, e := range sink.events {
+		identities = append(identities, e.Identity)
+	}
+	const arn = "arn:aws:iam::123456789012:user/certmagic"
+	if want := []string{"", arn, arn}; !reflect.DeepEqual(identities, want) {
+		t.Fatalf("identities = %q, want %q", identities, want)
+	}
+	if n := sts.calls.Load(); n != 2 {
+		t.Fatalf("GetCallerIdentity called %d times, want a retry after the failure only", n)
+	}
+}
@@ -0,0 +1,101 @@
+package s3store
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// WithCircuitBreaker stops sending requests to S3 for cooldown after
+// failures consecutive requests found it unreachable (network errors,
+// timeouts and 5xx responses), so that a dead endpoint makes requests
+// fail fast with ErrCircuitOpen instead of stalling every TLS handshake
+// on long timeouts. Cached values are still served by Load. Once the
+// cooldown has passed a single request is let through to probe the
+// endpoint, closing the circuit if it succeeds. Reads fall back to the
+// failover bucket, if configured, while the circuit is open. Like the
+// other client options it has no effect on a custom S3API.
+func WithCircuitBreaker(failures int, cooldown time.Duration) Option {
+	return func(s *S3Store) {
+		s.breaker = &circuitBreaker{threshold: failures, cooldown: cooldown}
+	}
+}
+
+// circuitBreaker tracks consecutive request failures.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow reports whether a request may be sent. While the circuit is
+// half-open only one probing request is allowed at a time.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record records the outcome of a request and reports whether
+// it opened the circuit.
+func (b *circuitBreaker) record(ctx context.Context, err error) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if ctx.Err() != nil {
+		// Canceled requests say nothing about the endpoint.
+		return false
+	}
+	if !isUnavailable(ctx, err) {
+		b.failures = 0
+		return false
+	}
+	b.failures++
+	if b.failures < b.threshold {
+		return false
+	}
+	b.openUntil = time.Now().Add(b.cooldown)
+	return true
+}
+
+// useCircuitBreaker is a client option that guards every
+// request with the store's circuit breaker.
+func (s *S3Store) useCircuitBreaker(o *s3.Options) {
+	b := s.breaker
+	o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("S3StoreCircuitBreaker", func(
+			ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler,
+		) (middleware.InitializeOutput, middleware.Metadata, error) {
+			if !b.allow() {
+				return middleware.InitializeOutput{}, middleware.Metadata{}, ErrCircuitOpen
+			}
+			out, md, err := next.HandleInitialize(ctx, in)
+			if b.record(ctx, err) {
+				log.Printf("[ERROR][%s] S3 unreachable, failing fast for %s: %v", s, b.cooldown, err)
+			}
+			return out, md, err
+		}), middleware.Before)
+	})
+}
+
+// isCircuitOpen reports whether err is a request rejected
+// by the circuit breaker.
+func isCircuitOpen(err error) bool {
+	return errors.Is(err, ErrCircuitOpen)
+}
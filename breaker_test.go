@@ -0,0 +1,56 @@
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeS3(t)
+	f.buckets["certs"] = make(map[string][]byte)
+	client := s3.New(s3.Options{
+		Region:           "us-east-1",
+		BaseEndpoint:     aws.String(f.URL),
+		UsePathStyle:     true,
+		Credentials:      credentials.NewStaticCredentialsProvider("test", "test", ""),
+		RetryMaxAttempts: 1,
+	})
+	s := s3store.NewS3StoreFromClient(client, "certs",
+		s3store.WithAllowHTTP(), s3store.WithCircuitBreaker(2, 200*time.Millisecond))
+	mustStore(t, s, "k")
+	var requests atomic.Int32
+	f.handle = func(*http.Request) { requests.Add(1) }
+
+	f.fail(http.StatusServiceUnavailable)
+	for range 2 {
+		if _, err := s.Load(ctx, "k"); err == nil || errors.Is(err, s3store.ErrCircuitOpen) {
+			t.Fatalf("Load from a failing endpoint = %v, want its error", err)
+		}
+	}
+	if _, err := s.Load(ctx, "k"); !errors.Is(err, s3store.ErrCircuitOpen) {
+		t.Fatalf("Load with the circuit open = %v, want ErrCircuitOpen", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Fatalf("%d requests sent, want none with the circuit open", n-2)
+	}
+
+	// After the cooldown a probe closes the circuit again.
+	f.fail(0)
+	time.Sleep(200 * time.Millisecond)
+	if v, err := s.Load(ctx, "k"); err != nil || string(v) != "k" {
+		t.Fatalf("Load after the cooldown = %q, %v", v, err)
+	}
+	if _, err := s.Load(ctx, "k"); err != nil {
+		t.Fatalf("Load with the circuit closed: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package s3store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// WithCreateBucket creates the bucket when the store is constructed if
+// it doesn't exist yet, see EnsureBucket.
+func WithCreateBucket() Option {
+	return func(s *S3Store) {
+		s.createBucket = true
+	}
+}
+
+// EnsureBucket creates the bucket if it doesn't exist, with versioning
+// and default server-side encryption enabled, so that new environments
+// can be bootstrapped without provisioning the bucket out of band.
+// Object Lock is enabled too if WithObjectLock is used. Existing
+// buckets are left untouched.
+func (s *S3Store) EnsureBucket(ctx context.Context) error {
+	created, err := s.createBucketIfMissing(ctx)
+	if err != nil || !created {
+		return err
+	}
+
+	_, err = s.client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: s.bucket,
+		VersioningConfiguration: &types.VersioningConfiguration{
+			Status: types.BucketVersioningStatusEnabled,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("enabling bucket versioning: %w", err)
+	}
+
+	_, err = s.client.PutBucketEncryption(ctx, &s3.PutBucketEncryptionInput{
+		Bucket: s.bucket,
+		ServerSideEncryptionConfiguration: &types.ServerSideEncryptionConfiguration{
+			Rules: []types.ServerSideEncryptionRule{{
+				ApplyServerSideEncryptionByDefault: &types.ServerSideEncryptionByDefault{
+					SSEAlgorithm: types.ServerSideEncryptionAes256,
+				},
+				BucketKeyEnabled: aws.Bool(true),
+			}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("enabling bucket encryption: %w", err)
+	}
+	return nil
+}
+
+// createBucketIfMissing creates the bucket unless it already exists,
+// reporting whether it was created.
+func (s *S3Store) createBucketIfMissing(ctx context.Context) (bool, error) {
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: s.bucket})
+	if err == nil {
+		return false, nil
+	}
+	var nf *types.NotFound
+	if !errors.As(err, &nf) {
+		return false, fmt.Errorf("checking bucket: %w", err)
+	}
+
+	input := &s3.CreateBucketInput{Bucket: s.bucket}
+	if len(s.objectLock) > 0 {
+		input.ObjectLockEnabledForBucket = aws.Bool(true)
+	}
+	if region := s.region; region != "" && region != "us-east-1" {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(region),
+		}
+	}
+	if _, err := s.client.CreateBucket(ctx, input); err != nil {
+		return false, fmt.Errorf("creating bucket: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,84 @@
+package s3store
+
+import (
+	"context"
+	"sync"
+)
+
+// bulkConcurrency bounds the number of keys LoadMany
+// and StoreMany process at once.
+const bulkConcurrency = 16
+
+// Result is the outcome of loading a single key with LoadMany.
+type Result struct {
+	Value []byte
+	Err   error
+}
+
+// LoadMany loads all of keys concurrently, with bounded parallelism,
+// and returns the value or error for each key. Loading a site's
+// certificate, private key and metadata this way costs one round trip
+// instead of three.
+func (s *S3Store) LoadMany(ctx context.Context, keys []string) map[string]Result {
+	results := make(map[string]Result, len(keys))
+	var mu sync.Mutex
+	s.forEachKey(keys, func(key string) {
+		b, err := s.Load(ctx, key)
+		mu.Lock()
+		defer mu.Unlock()
+		results[key] = Result{Value: b, Err: err}
+	})
+	return results
+}
+
+// StoreError reports the keys that could not be stored
+// by StoreMany, along with the reason for each.
+type StoreError struct {
+	Failed map[string]error
+}
+
+func (e *StoreError) Error() string {
+	return failedKeysMessage("storing", e.Failed)
+}
+
+// StoreMany stores all of values concurrently, with bounded
+// parallelism. Every key is attempted; if some could not be stored, a
+// *StoreError listing them is returned.
+func (s *S3Store) StoreMany(ctx context.Context, values map[string][]byte) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	serr := &StoreError{Failed: make(map[string]error)}
+	var mu sync.Mutex
+	s.forEachKey(keys, func(key string) {
+		if err := s.Store(ctx, key, values[key]); err != nil {
+			mu.Lock()
+			defer mu.Unlock()
+			serr.Failed[key] = err
+		}
+	})
+	if len(serr.Failed) > 0 {
+		return serr
+	}
+	return nil
+}
+
+// forEachKey calls fn for each of keys, running up to bulkConcurrency
+// calls at once, and returns when all of them have returned.
+func (s *S3Store) forEachKey(keys []string, fn func(key string)) {
+	sem := make(chan struct{}, bulkConcurrency)
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(key)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,56 @@
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+func TestLoadMany(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	var keys []string
+	for i := range 40 {
+		keys = append(keys, fmt.Sprintf("k%d", i))
+	}
+	mustStore(t, s, keys...)
+
+	results := s.LoadMany(ctx, append(keys, "missing"))
+	if len(results) != len(keys)+1 {
+		t.Fatalf("LoadMany returned %d results, want %d", len(results), len(keys)+1)
+	}
+	for _, key := range keys {
+		if r := results[key]; r.Err != nil || string(r.Value) != key {
+			t.Errorf("result for %s = %q, %v", key, r.Value, r.Err)
+		}
+	}
+	if r := results["missing"]; !errors.Is(r.Err, s3store.ErrNotFound) {
+		t.Errorf("result for missing key = %v, want ErrNotFound", r.Err)
+	}
+}
+
+func TestStoreMany(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t, s3store.WithWriteOnce("protected"))
+	mustStore(t, s, "protected")
+	err := s.StoreMany(ctx, map[string][]byte{
+		"a":         []byte("a"),
+		"b":         []byte("b"),
+		"protected": []byte("new"),
+	})
+	var serr *s3store.StoreError
+	if !errors.As(err, &serr) || len(serr.Failed) != 1 || !errors.Is(serr.Failed["protected"], s3store.ErrExists) {
+		t.Fatalf("StoreMany = %v, want a StoreError for the protected key", err)
+	}
+	for _, key := range []string{"a", "b"} {
+		if v, err := s.Load(ctx, key); err != nil || string(v) != key {
+			t.Errorf("Load(%s) = %q, %v", key, v, err)
+		}
+	}
+	if err := s.StoreMany(ctx, map[string][]byte{"c": nil}); err != nil {
+		t.Fatal(err)
+	}
+}
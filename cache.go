@@ -0,0 +1,177 @@
+package s3store
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+)
+
+// WithCache enables an in-memory LRU read-through cache holding up to
+// size values. Values are cached on Load and invalidated by Store and
+// Delete calls made through the same store, so steady-state operation
+// needs next to no GET requests. Writes made by other processes are not
+// observed until the value is evicted.
+func WithCache(size int) Option {
+	return func(s *S3Store) {
+		s.cache = newCache(size)
+	}
+}
+
+// WithCacheRevalidation makes cache hits revalidate against S3 with a
+// conditional GET on the cached ETag. A 304 Not Modified response serves
+// the cached value without downloading it again, while changes made by
+// other processes are still picked up. It enables a cache of size
+// objects if none is configured.
+func WithCacheRevalidation(size int) Option {
+	return func(s *S3Store) {
+		if s.cache == nil {
+			s.cache = newCache(size)
+		}
+		s.revalidate = true
+	}
+}
+
+// WithServeStale makes Load return the cached value when revalidating
+// it fails because S3 is unreachable or throttling, logging a warning,
+// so that a transient S3 outage doesn't take down TLS termination. It
+// only has an effect together with WithCacheRevalidation, as other
+// cache hits are served without contacting S3 anyway.
+func WithServeStale() Option {
+	return func(s *S3Store) {
+		s.serveStale = true
+	}
+}
+
+// Preload loads every key under prefixes into the cache, so that the
+// first flood of TLS handshakes after a deploy is served from memory
+// instead of turning into a flood of GET requests. Keys are loaded
+// concurrently like with LoadMany. It returns the number of keys
+// loaded; keys that failed to load are reported in the error. Without a
+// cache it does nothing.
+func (s *S3Store) Preload(ctx context.Context, prefixes ...string) (int, error) {
+	if s.cache == nil {
+		return 0, nil
+	}
+	var keys []string
+	for _, prefix := range prefixes {
+		k, err := s.List(ctx, prefix, true)
+		if err != nil {
+			return 0, err
+		}
+		keys = append(keys, k...)
+	}
+	results := s.LoadMany(ctx, keys)
+	var n int
+	var errs []error
+	for _, key := range keys {
+		if err := results[key].Err; err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		n++
+	}
+	return n, errors.Join(errs...)
+}
+
+// isRetryable reports whether err is a transient failure after
+// which a stale cached value may be served.
+func isRetryable(ctx context.Context, err error) bool {
+	return isUnavailable(ctx, err) || errors.Is(kindOf(err), ErrThrottled)
+}
+
+// isNotModified reports whether err is a 304 Not Modified response to
+// a conditional GET.
+func isNotModified(err error) bool {
+	var re *awshttp.ResponseError
+	return errors.As(err, &re) && re.HTTPStatusCode() == http.StatusNotModified
+}
+
+// cache is a fixed size LRU cache of object values keyed by storage key.
+// Values are copied in and out, so callers may modify them.
+type cache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+	// gen is incremented by every remove, so that a value read before
+	// an invalidation isn't added back by a Load racing a Store.
+	gen uint64
+}
+
+type cacheEntry struct {
+	key      string
+	value    []byte
+	etag     string
+	modified time.Time
+}
+
+func newCache(size int) *cache {
+	return &cache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *cache) get(key string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return cacheEntry{}, false
+	}
+	c.ll.MoveToFront(e)
+	entry := *e.Value.(*cacheEntry)
+	entry.value = bytes.Clone(entry.value)
+	return entry, true
+}
+
+// generation returns the current generation, to be passed to add
+// for a value read from S3 afterwards.
+func (c *cache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// add caches entry unless an entry was removed since generation gen,
+// in which case entry may be stale.
+func (c *cache) add(entry cacheEntry, gen uint64) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen != gen {
+		return
+	}
+	entry.value = bytes.Clone(entry.value)
+	key := entry.key
+	if e, ok := c.items[key]; ok {
+		e.Value = &entry
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&entry)
+	for c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *cache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	if e, ok := c.items[key]; ok {
+		c.ll.Remove(e)
+		delete(c.items, key)
+	}
+}
@@ -0,0 +1,158 @@
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+func TestCacheCopiesValues(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t, s3store.WithCache(10))
+	mustStore(t, s, "k")
+	v, err := s.Load(ctx, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v[0] = 'x'
+	if v, _ := s.Load(ctx, "k"); string(v) != "k" {
+		t.Fatalf("cached value changed to %q by the caller", v)
+	}
+	v, _ = s.Load(ctx, "k")
+	v[0] = 'x'
+	if v, _ := s.Load(ctx, "k"); string(v) != "k" {
+		t.Fatalf("cached value changed to %q by the caller", v)
+	}
+}
+
+// pausingGet holds every GetObject response until resume is closed,
+// after signalling on fetched that the object was read.
+type pausingGet struct {
+	*memstore.Client
+	fetched chan struct{}
+	resume  chan struct{}
+}
+
+func (p *pausingGet) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	out, err := p.Client.GetObject(ctx, params, optFns...)
+	p.fetched <- struct{}{}
+	<-p.resume
+	return out, err
+}
+
+func TestCacheLoadRacingStore(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	mustStore(t, newTestStoreOn(t, mem), "k")
+
+	api := &pausingGet{mem, make(chan struct{}, 1), make(chan struct{})}
+	s := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(api), s3store.WithCache(10))
+	loaded := make(chan []byte)
+	go func() {
+		v, _ := s.Load(ctx, "k")
+		loaded <- v
+	}()
+	<-api.fetched
+	if err := s.Store(ctx, "k", []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	close(api.resume)
+	if v := <-loaded; string(v) != "k" {
+		t.Fatalf("racing Load = %q, want the old value", v)
+	}
+	go func() { <-api.fetched }()
+	if v, _ := s.Load(ctx, "k"); string(v) != "new" {
+		t.Fatalf("Load after Store = %q, want %q; the stale value was cached", v, "new")
+	}
+}
+
+// outage fails every GetObject with err while err is set.
+type outage struct {
+	*memstore.Client
+	err *error
+}
+
+func (o outage) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if *o.err != nil {
+		return nil, *o.err
+	}
+	return o.Client.GetObject(ctx, params, optFns...)
+}
+
+// responseError returns the error the SDK reports for
+// an S3 response with status and error code.
+func responseError(status int, code string) error {
+	return &awshttp.ResponseError{ResponseError: &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{StatusCode: status}},
+		Err:      &smithy.GenericAPIError{Code: code},
+	}}
+}
+
+func TestServeStale(t *testing.T) {
+	ctx := context.Background()
+	var err error
+	api := outage{memstore.New(testBucket), &err}
+	stale := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(api),
+		s3store.WithCacheRevalidation(10), s3store.WithServeStale())
+	strict := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(api), s3store.WithCacheRevalidation(10))
+	mustStore(t, stale, "k")
+	mustStore(t, strict, "k")
+	for _, s := range []*s3store.S3Store{stale, strict} {
+		if _, err := s.Load(ctx, "k"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = responseError(http.StatusServiceUnavailable, "SlowDown")
+	if v, err := stale.Load(ctx, "k"); err != nil || string(v) != "k" {
+		t.Fatalf("Load while throttled = %q, %v, want the cached value", v, err)
+	}
+	if _, err := strict.Load(ctx, "k"); !errors.Is(err, s3store.ErrThrottled) {
+		t.Fatalf("Load while throttled without WithServeStale = %v, want ErrThrottled", err)
+	}
+	err = responseError(http.StatusForbidden, "AccessDenied")
+	if _, err := stale.Load(ctx, "k"); !errors.Is(err, s3store.ErrAccessDenied) {
+		t.Fatalf("Load when denied = %v, want ErrAccessDenied", err)
+	}
+}
+
+func TestPreload(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	writer := newTestStoreOn(t, mem)
+	mustStore(t, writer, "certificates/a.com/a.com.crt", "certificates/b.com/b.com.crt", "acme/users/x")
+
+	s := newTestStoreOn(t, mem, s3store.WithCache(10))
+	if n, err := s.Preload(ctx, "certificates"); err != nil || n != 2 {
+		t.Fatalf("Preload = %d, %v, want 2 keys", n, err)
+	}
+	// Preloaded keys are served from the cache once
+	// they are gone from the bucket; others aren't.
+	for _, key := range []string{"certificates/a.com/a.com.crt", "certificates/b.com/b.com.crt", "acme/users/x"} {
+		if _, err := mem.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(testBucket),
+			Key:    aws.String(s.Filename(ctx, key)),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v, err := s.Load(ctx, "certificates/a.com/a.com.crt"); err != nil || string(v) != "certificates/a.com/a.com.crt" {
+		t.Fatalf("Load of preloaded key = %q, %v", v, err)
+	}
+	if _, err := s.Load(ctx, "acme/users/x"); !errors.Is(err, s3store.ErrNotFound) {
+		t.Fatalf("Load of key that wasn't preloaded = %v, want ErrNotFound", err)
+	}
+
+	if n, err := writer.Preload(ctx, "certificates"); err != nil || n != 0 {
+		t.Fatalf("Preload without a cache = %d, %v, want 0", n, err)
+	}
+}
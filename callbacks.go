@@ -0,0 +1,86 @@
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"path"
+	"time"
+)
+
+// webhookTimeout bounds how long a webhook delivery may take.
+const webhookTimeout = 10 * time.Second
+
+// callback is a function registered for keys matching pattern.
+type callback struct {
+	pattern string
+	fn      func(ctx context.Context, event ChangeEvent)
+}
+
+// WithCallback calls fn after every successful Store or Delete of a key
+// matching pattern, as interpreted by path.Match; for example
+// "certificates/*/*/*.crt" matches newly issued certificates. fn runs
+// synchronously, so it should return quickly.
+func WithCallback(pattern string, fn func(ctx context.Context, event ChangeEvent)) Option {
+	return func(s *S3Store) {
+		s.callbacks = append(s.callbacks, callback{pattern: pattern, fn: fn})
+	}
+}
+
+// WithWebhook POSTs the ChangeEvent as JSON to url after every
+// successful Store or Delete of a key matching pattern, e.g. to post
+// new certificate issuance to a Slack workflow. Deliveries happen in the
+// background and failures are logged, without url since webhook URLs
+// usually embed a secret.
+func WithWebhook(pattern, url string) Option {
+	return func(s *S3Store) {
+		WithCallback(pattern, func(ctx context.Context, event ChangeEvent) {
+			go func() {
+				if err := postWebhook(ctx, url, event); err != nil {
+					log.Printf("[ERROR][%s] Delivering %s webhook for '%s': %v", s, event.Op, s.logKey(event.Key), err)
+				}
+			}()
+		})(s)
+	}
+}
+
+func postWebhook(ctx context.Context, url string, event ChangeEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		var ue *neturl.Error
+		if errors.As(err, &ue) {
+			ue.URL = req.URL.Scheme + "://" + req.URL.Host
+		}
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// runCallbacks calls the callbacks whose pattern matches key.
+func (s *S3Store) runCallbacks(ctx context.Context, op, key string) {
+	for _, cb := range s.callbacks {
+		if ok, _ := path.Match(cb.pattern, key); ok {
+			cb.fn(ctx, s.changeEvent(op, key))
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package s3store_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+func TestCallback(t *testing.T) {
+	ctx := context.Background()
+	var got []string
+	s, _ := newTestStore(t, s3store.WithCallback("certificates/*/*/*.crt", func(_ context.Context, e s3store.ChangeEvent) {
+		got = append(got, e.Op+" "+e.Key)
+	}))
+	mustStore(t, s, "certificates/acme/a.com/a.com.crt", "certificates/acme/a.com/a.com.key")
+	if err := s.Delete(ctx, "certificates/acme/a.com/a.com.crt"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"store certificates/acme/a.com/a.com.crt", "delete certificates/acme/a.com/a.com.crt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("callbacks = %q, want %q", got, want)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	events := make(chan s3store.ChangeEvent, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e s3store.ChangeEvent
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Error(err)
+		}
+		events <- e
+	}))
+	defer srv.Close()
+
+	s, _ := newTestStore(t, s3store.WithWebhook("certificates/*", srv.URL))
+	mustStore(t, s, "other", "certificates/k")
+	select {
+	case e := <-events:
+		if e.Op != "store" || e.Key != "certificates/k" || e.Bucket != testBucket {
+			t.Fatalf("webhook got %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+}
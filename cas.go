@@ -0,0 +1,36 @@
+package s3store
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// LoadWithETag retrieves the value at key along with its ETag, which
+// can be passed to StoreIfMatch to update the value without losing
+// concurrent changes.
+func (s *S3Store) LoadWithETag(ctx context.Context, key string) ([]byte, string, error) {
+	defer s.metrics.observe("load", time.Now())
+	var e cacheEntry
+	err := s.withFailover(ctx, func(st *S3Store) (err error) {
+		e, err = st.load(ctx, key)
+		return err
+	})
+	return e.value, e.etag, s.opError("load", key, err)
+}
+
+// StoreIfMatch saves value at key only if the stored value still has
+// the given ETag, as returned by LoadWithETag, and returns ErrModified
+// otherwise. Concurrent writers, such as several Caddy instances
+// updating the same metadata, can then reload and retry instead of
+// silently overwriting each other's changes.
+func (s *S3Store) StoreIfMatch(ctx context.Context, key string, value []byte, etag string) (err error) {
+	if s.skipDryRun("store", key) {
+		return nil
+	}
+	defer func() { s.mutated(ctx, "store", err, key) }()
+	input := s.putObjectInput(ctx, key, value)
+	input.IfMatch = aws.String(etag)
+	return s.put(ctx, key, value, input)
+}
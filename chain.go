@@ -0,0 +1,145 @@
+package s3store
+
+import (
+	"context"
+	"log"
+	"sort"
+
+	cm "github.com/caddyserver/certmagic"
+)
+
+// ChainStorage is a certmagic Storage that layers several storages,
+// typically an S3Store followed by a local FileStorage. Reads try each
+// storage in turn and back-fill keys found further down the chain into
+// the storages before it, allowing graceful degradation when S3 is
+// unavailable and gradual migration from one storage to another. Writes
+// and deletes go to every storage. Locking is delegated to the first
+// storage only.
+type ChainStorage struct {
+	storages []cm.Storage
+}
+
+// NewChainStorage returns a ChainStorage trying storages in order.
+func NewChainStorage(primary cm.Storage, fallbacks ...cm.Storage) *ChainStorage {
+	return &ChainStorage{storages: append([]cm.Storage{primary}, fallbacks...)}
+}
+
+// Lock obtains a lock named by key from the primary storage.
+func (c *ChainStorage) Lock(ctx context.Context, key string) error {
+	return c.storages[0].Lock(ctx, key)
+}
+
+// Unlock releases the lock for key on the primary storage.
+func (c *ChainStorage) Unlock(ctx context.Context, key string) error {
+	return c.storages[0].Unlock(ctx, key)
+}
+
+// Store saves value at key in every storage, returning
+// the first error encountered.
+func (c *ChainStorage) Store(ctx context.Context, key string, value []byte) error {
+	var firstErr error
+	for _, st := range c.storages {
+		if err := st.Store(ctx, key, value); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Load retrieves the value at key from the first storage that has it,
+// back-filling it into the storages before that one.
+func (c *ChainStorage) Load(ctx context.Context, key string) ([]byte, error) {
+	var firstErr error
+	for i, st := range c.storages {
+		value, err := st.Load(ctx, key)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		c.backfill(ctx, i, key, value)
+		return value, nil
+	}
+	return nil, firstErr
+}
+
+// backfill stores value in the storages preceding index i. Failures are
+// logged since the value was loaded successfully regardless.
+func (c *ChainStorage) backfill(ctx context.Context, i int, key string, value []byte) {
+	for _, st := range c.storages[:i] {
+		if err := st.Store(ctx, key, value); err != nil {
+			log.Printf("[WARNING] Back-filling '%s' into %v: %v", key, st, err)
+		}
+	}
+}
+
+// Delete deletes key from every storage, returning
+// the first error encountered.
+func (c *ChainStorage) Delete(ctx context.Context, key string) error {
+	var firstErr error
+	for _, st := range c.storages {
+		if err := st.Delete(ctx, key); err != nil && firstErr == nil && st.Exists(ctx, key) {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Exists returns true if key exists in any storage.
+func (c *ChainStorage) Exists(ctx context.Context, key string) bool {
+	for _, st := range c.storages {
+		if st.Exists(ctx, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the union of the keys matching prefix in all storages.
+// An error is only returned if no storage could be listed.
+func (c *ChainStorage) List(ctx context.Context, prefix string, recursive bool) ([]string, error) {
+	seen := make(map[string]bool)
+	var firstErr error
+	var listed bool
+	for _, st := range c.storages {
+		keys, err := st.List(ctx, prefix, recursive)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		listed = true
+		for _, k := range keys {
+			seen[k] = true
+		}
+	}
+	if !listed {
+		return nil, firstErr
+	}
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Stat returns information about key from the first storage that has it.
+func (c *ChainStorage) Stat(ctx context.Context, key string) (cm.KeyInfo, error) {
+	var firstErr error
+	for _, st := range c.storages {
+		info, err := st.Stat(ctx, key)
+		if err == nil {
+			return info, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return cm.KeyInfo{}, firstErr
+}
+
+// Interface guard
+var _ cm.Storage = (*ChainStorage)(nil)
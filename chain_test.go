@@ -0,0 +1,164 @@
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+
+	"github.com/caddyserver/certmagic"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+// readOnly is a certmagic Storage that fails every write.
+type readOnly struct {
+	certmagic.Storage
+}
+
+func (readOnly) Store(context.Context, string, []byte) error {
+	return errors.New("read-only storage")
+}
+
+func TestChainStorageReadThrough(t *testing.T) {
+	ctx := context.Background()
+	primary, _ := newTestStore(t)
+	second, _ := newTestStore(t)
+	third := &certmagic.FileStorage{Path: t.TempDir()}
+	c := s3store.NewChainStorage(primary, second, third)
+	if err := second.Store(ctx, "k", []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+	if err := third.Store(ctx, "k", []byte("third")); err != nil {
+		t.Fatal(err)
+	}
+	if err := third.Store(ctx, "only-third", []byte("third")); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := c.Load(ctx, "k"); err != nil || string(v) != "second" {
+		t.Fatalf("Load = %q, %v, want the value of the first storage having it", v, err)
+	}
+	if v, err := primary.Load(ctx, "k"); err != nil || string(v) != "second" {
+		t.Fatalf("primary after Load = %q, %v, want it back-filled", v, err)
+	}
+	if v, _ := third.Load(ctx, "k"); string(v) != "third" {
+		t.Fatalf("third after Load = %q, want it untouched", v)
+	}
+	if _, err := c.Load(ctx, "only-third"); err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range []certmagic.Storage{primary, second} {
+		if !st.Exists(ctx, "only-third") {
+			t.Errorf("%v not back-filled from the last storage", st)
+		}
+	}
+	if info, err := c.Stat(ctx, "k"); err != nil || info.Size != int64(len("second")) {
+		t.Fatalf("Stat = %+v, %v, want the primary's key", info, err)
+	}
+
+	if _, err := c.Load(ctx, "missing"); !errors.Is(err, s3store.ErrNotFound) || !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Load of missing key = %v, want the primary's ErrNotFound", err)
+	}
+	if c.Exists(ctx, "missing") {
+		t.Fatal("Exists of missing key")
+	}
+	if _, err := c.Stat(ctx, "missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Stat of missing key = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestChainStorageWrites(t *testing.T) {
+	ctx := context.Background()
+	primary, _ := newTestStore(t)
+	fallback := &certmagic.FileStorage{Path: t.TempDir()}
+	c := s3store.NewChainStorage(primary, fallback)
+	if err := c.Store(ctx, "a/1", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range []certmagic.Storage{primary, fallback} {
+		if v, err := st.Load(ctx, "a/1"); err != nil || string(v) != "v" {
+			t.Errorf("%v after Store = %q, %v", st, v, err)
+		}
+	}
+
+	// List merges the keys of all storages.
+	mustStore(t, primary, "a/2")
+	if err := fallback.Store(ctx, "a/3", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	if keys, err := c.List(ctx, "a", false); err != nil || !reflect.DeepEqual(keys, []string{"a/1", "a/2", "a/3"}) {
+		t.Fatalf("List = %q, %v", keys, err)
+	}
+
+	if err := c.Delete(ctx, "a/1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range []certmagic.Storage{primary, fallback} {
+		if st.Exists(ctx, "a/1") {
+			t.Errorf("%v still has the deleted key", st)
+		}
+	}
+	// Keys missing from some storages are deleted from the others.
+	if err := c.Delete(ctx, "a/3"); err != nil {
+		t.Fatalf("Delete of key missing from the primary: %v", err)
+	}
+	if fallback.Exists(ctx, "a/3") {
+		t.Fatal("fallback still has the deleted key")
+	}
+
+	if err := c.Lock(ctx, "l"); err != nil {
+		t.Fatal(err)
+	}
+	if fallback.Exists(ctx, "locks/l.lock") {
+		t.Error("Lock took a lock on the fallback")
+	}
+	if !primary.Exists(ctx, "locks/l.lock") {
+		t.Error("Lock didn't take the lock on the primary")
+	}
+	if err := c.Unlock(ctx, "l"); err != nil {
+		t.Fatal(err)
+	}
+	if primary.Exists(ctx, "locks/l.lock") {
+		t.Error("Unlock didn't release the lock on the primary")
+	}
+}
+
+func TestChainStorageErrors(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	down := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(failingAPI{mem, errors.New("connection reset")}))
+	fallback, _ := newTestStore(t)
+	c := s3store.NewChainStorage(down, fallback)
+
+	// Reads fall through an unreachable primary.
+	mustStore(t, fallback, "k")
+	if v, err := c.Load(ctx, "k"); err != nil || string(v) != "k" {
+		t.Fatalf("Load with the primary down = %q, %v, want the fallback's value", v, err)
+	}
+	if _, err := c.Load(ctx, "missing"); err == nil || errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Load of missing key with the primary down = %v, want the primary's error", err)
+	}
+
+	// A failed write is reported, but doesn't keep the others from being written.
+	other, _ := newTestStore(t)
+	c = s3store.NewChainStorage(readOnly{other}, fallback)
+	if err := c.Store(ctx, "w", []byte("v")); err == nil {
+		t.Fatal("Store into a read-only storage succeeded")
+	}
+	if !fallback.Exists(ctx, "w") {
+		t.Fatal("Store didn't write past the failing storage")
+	}
+
+	// List fails only when no storage could be listed.
+	unlistable := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(failingList{memstore.New(testBucket)}))
+	c = s3store.NewChainStorage(unlistable, fallback)
+	if keys, err := c.List(ctx, "", false); err != nil || !reflect.DeepEqual(keys, []string{"k", "w"}) {
+		t.Fatalf("List with one storage failing = %q, %v", keys, err)
+	}
+	c = s3store.NewChainStorage(unlistable, unlistable)
+	if _, err := c.List(ctx, "", false); err == nil {
+		t.Fatal("List with every storage failing succeeded")
+	}
+}
@@ -0,0 +1,139 @@
+package s3store
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// validateOutputChecksumID is the ID of the SDK middleware that
+// validates response checksums while the body is read.
+const validateOutputChecksumID = "AWSChecksum:ValidateOutputPayloadChecksum"
+
+// ChecksumError is returned by Load when the data read does not match
+// the SHA-256 checksum S3 stored with the object, meaning the object is
+// truncated or corrupted.
+type ChecksumError struct {
+	Key      string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %s: expected sha256 %s, got %s", e.Key, e.Expected, e.Actual)
+}
+
+// WithChecksums attaches a SHA-256 checksum (x-amz-checksum-sha256) to
+// every write, which S3 verifies on receipt, and verifies it again on
+// Load and the streaming reads, returning a *ChecksumError if the data
+// does not match.
+func WithChecksums() Option {
+	return func(s *S3Store) {
+		s.checksums = true
+	}
+}
+
+// WithContentMD5 sends a Content-MD5 header with every single part
+// write so the server rejects corrupted uploads. It is meant for S3
+// compatible backends that do not support the newer checksum
+// algorithms, so the SDK's default CRC32 checksums are only sent when
+// an operation requires them.
+func WithContentMD5() Option {
+	return func(s *S3Store) {
+		s.contentMD5 = true
+		s.clientOpts = append(s.clientOpts, func(o *s3.Options) {
+			o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+		})
+	}
+}
+
+// md5Checksum returns the base64 encoded MD5 digest
+// of b, as used by Content-MD5.
+func md5Checksum(b []byte) string {
+	sum := md5.Sum(b)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// sha256Checksum returns the base64 encoded SHA-256 digest of b,
+// as used by x-amz-checksum-sha256.
+func sha256Checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// addChecksum sets the checksum fields of a PutObject request for
+// value. Multipart uploads get per-part checksums from the upload
+// manager, so only the algorithm is set for them.
+func (s *S3Store) addChecksum(input *s3.PutObjectInput, value []byte) {
+	input.ChecksumAlgorithm = types.ChecksumAlgorithmSha256
+	if value != nil && !s.useMultipart(int64(len(value))) {
+		input.ChecksumSHA256 = aws.String(sha256Checksum(value))
+	}
+}
+
+// getObjectWithChecksum fetches an object asking S3 to return its stored
+// checksums. The SDK's own validation is skipped so that mismatches can
+// be reported as a *ChecksumError by verifyChecksum instead.
+func (s *S3Store) getObjectWithChecksum(ctx context.Context, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	input.ChecksumMode = types.ChecksumModeEnabled
+	return s.client.GetObject(ctx, input, func(o *s3.Options) {
+		o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+			stack.Deserialize.Remove(validateOutputChecksumID)
+			return nil
+		})
+	})
+}
+
+// verifyChecksum compares b with the checksum S3 returned for key.
+// Objects without a SHA-256 checksum, and multipart objects whose
+// checksum covers the parts rather than the whole body, are accepted.
+func verifyChecksum(key string, b []byte, expected *string) error {
+	sum := sha256.Sum256(b)
+	return verifyDigest(key, sum[:], expected)
+}
+
+// verifyDigest is like verifyChecksum for the SHA-256 digest sum.
+func verifyDigest(key string, sum []byte, expected *string) error {
+	want := aws.ToString(expected)
+	if want == "" || strings.Contains(want, "-") {
+		return nil
+	}
+	if got := base64.StdEncoding.EncodeToString(sum); got != want {
+		return &ChecksumError{Key: key, Expected: want, Actual: got}
+	}
+	return nil
+}
+
+// checksumReader hashes the value read from an object and verifies it
+// against the object's checksum once the end of the value is reached.
+type checksumReader struct {
+	io.ReadCloser
+	key      string
+	expected *string
+	hash     hash.Hash
+}
+
+func newChecksumReader(key string, body io.ReadCloser, expected *string) *checksumReader {
+	return &checksumReader{ReadCloser: body, key: key, expected: expected, hash: sha256.New()}
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF {
+		if cerr := verifyDigest(r.key, r.hash.Sum(nil), r.expected); cerr != nil {
+			return n, cerr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,58 @@
+package s3store_test
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+func TestLoadVerifiesChecksum(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	mustStore(t, newTestStoreOn(t, mem, s3store.WithChecksums()), "k")
+	mustStore(t, newTestStoreOn(t, mem), "unsummed")
+
+	s := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(corrupting{mem}), s3store.WithChecksums())
+	_, err := s.Load(ctx, "k")
+	var cerr *s3store.ChecksumError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("Load of corrupted object = %v, want *ChecksumError", err)
+	}
+	if cerr.Key != "k" || cerr.Expected == "" || cerr.Expected == cerr.Actual {
+		t.Fatalf("ChecksumError = %+v", cerr)
+	}
+	// Objects written without a checksum can't be verified.
+	if _, err := s.Load(ctx, "unsummed"); err != nil {
+		t.Fatalf("Load of object without checksum: %v", err)
+	}
+
+	intact := newTestStoreOn(t, mem, s3store.WithChecksums())
+	if v, err := intact.Load(ctx, "k"); err != nil || string(v) != "k" {
+		t.Fatalf("Load = %q, %v", v, err)
+	}
+}
+
+func TestContentMD5(t *testing.T) {
+	api := &recordingInputs{Client: memstore.New(testBucket), puts: make(map[string]*s3.PutObjectInput)}
+	mustStore(t, s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(api), s3store.WithContentMD5()), "k")
+	sum := md5.Sum([]byte("k"))
+	if got, want := aws.ToString(api.puts["certmagic/k"].ContentMD5), base64.StdEncoding.EncodeToString(sum[:]); got != want {
+		t.Errorf("Content-MD5 = %q, want %q", got, want)
+	}
+	if o := clientOptions(t, testBucket, s3store.WithContentMD5()); o.RequestChecksumCalculation != aws.RequestChecksumCalculationWhenRequired {
+		t.Errorf("RequestChecksumCalculation = %v, want checksums only when required", o.RequestChecksumCalculation)
+	}
+
+	plain := &recordingInputs{Client: memstore.New(testBucket), puts: make(map[string]*s3.PutObjectInput)}
+	mustStore(t, s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(plain)), "k")
+	if sent := plain.puts["certmagic/k"].ContentMD5; sent != nil {
+		t.Errorf("Content-MD5 sent without WithContentMD5: %q", *sent)
+	}
+}
@@ -0,0 +1,163 @@
+// Command s3store inspects and maintains certificate storage
+// written by the s3store package, using the same prefix and layout.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	cm "github.com/caddyserver/certmagic"
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+const usage = `usage: s3store [flags] <command> [args]
+
+commands:
+  ls [prefix]         list keys under prefix
+  get <key>           write the value at key to stdout
+  put <key> [file]    store the contents of file (or stdin) at key
+  rm <key>            delete key
+  restore <key>       restore the latest trashed copy or prior version of key
+  stat <key>          show size, modification time and metadata of key
+  locks               list held locks and their age
+  locks rm <name>     release the lock called name
+  migrate <dir>       copy a certmagic file storage at dir into the bucket
+
+flags:
+`
+
+func main() {
+	bucket := flag.String("bucket", "", "bucket `name` or access point ARN")
+	region := flag.String("region", "", "AWS `region` of the bucket")
+	prefix := flag.String("prefix", "certmagic", "storage `prefix`")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *bucket == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	store := s3store.NewS3Store(*bucket, *region, s3store.WithPrefix(*prefix))
+	if err := run(context.Background(), store, flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "s3store:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, store *s3store.S3Store, cmd string, args []string) error {
+	switch cmd {
+	case "ls":
+		prefix := ""
+		if len(args) > 0 {
+			prefix = args[0]
+		}
+		keys, err := store.List(ctx, prefix, true)
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			fmt.Println(k)
+		}
+		return nil
+
+	case "get":
+		if len(args) != 1 {
+			return fmt.Errorf("get: expected a key")
+		}
+		_, err := store.LoadTo(ctx, args[0], os.Stdout)
+		return err
+
+	case "put":
+		if len(args) < 1 || len(args) > 2 {
+			return fmt.Errorf("put: expected a key and optional file")
+		}
+		var r io.Reader = os.Stdin
+		if len(args) == 2 {
+			f, err := os.Open(args[1])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		value, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return store.Store(ctx, args[0], value)
+
+	case "rm":
+		if len(args) != 1 {
+			return fmt.Errorf("rm: expected a key")
+		}
+		return store.Delete(ctx, args[0])
+
+	case "restore":
+		if len(args) != 1 {
+			return fmt.Errorf("restore: expected a key")
+		}
+		return store.Restore(ctx, args[0])
+
+	case "stat":
+		if len(args) != 1 {
+			return fmt.Errorf("stat: expected a key")
+		}
+		info, err := store.StatExtended(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("key:      %s\nsize:     %d\nmodified: %s\n", info.Key, info.Size, info.Modified.Format(time.RFC3339))
+		for k, v := range info.Metadata {
+			fmt.Printf("meta:     %s=%s\n", k, v)
+		}
+		return nil
+
+	case "locks":
+		if len(args) == 2 && args[0] == "rm" {
+			return store.ForceUnlock(ctx, args[1])
+		}
+		if len(args) != 0 {
+			return fmt.Errorf("locks: unexpected arguments")
+		}
+		locks, err := store.Locks(ctx)
+		if err != nil {
+			return err
+		}
+		for _, l := range locks {
+			fmt.Printf("%s\t%s\n", l.Key, time.Since(l.Modified).Round(time.Second))
+		}
+		return nil
+
+	case "migrate":
+		if len(args) != 1 {
+			return fmt.Errorf("migrate: expected a directory")
+		}
+		src := &cm.FileStorage{Path: args[0]}
+		result, err := s3store.Migrate(ctx, src, store, s3store.MigrateProgressFunc(func(p s3store.MigrateProgress) {
+			status := "copied"
+			switch {
+			case p.Err != nil:
+				status = "failed: " + p.Err.Error()
+			case p.Skipped:
+				status = "skipped"
+			}
+			fmt.Fprintf(os.Stderr, "[%d/%d] %s %s\n", p.Done, p.Total, p.Key, status)
+		}))
+		if err != nil {
+			return err
+		}
+		if len(result.Failed) > 0 {
+			return fmt.Errorf("migrate: %d keys failed", len(result.Failed))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown command %q", cmd)
+}
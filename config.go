@@ -0,0 +1,144 @@
+package s3store
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is a declarative description of a store, for standalone tools
+// and servers other than Caddy. It can be read from a file with
+// LoadConfig; the fields correspond to the variables read by
+// NewS3StoreFromEnv.
+type Config struct {
+	Bucket    string   `json:"bucket" yaml:"bucket"`
+	Region    string   `json:"region,omitempty" yaml:"region,omitempty"`
+	Prefix    string   `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	Endpoint  string   `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	PathStyle bool     `json:"path_style,omitempty" yaml:"path_style,omitempty"`
+	Provider  Provider `json:"provider,omitempty" yaml:"provider,omitempty"`
+	// UnsafeLocking allows locking on providers that can't create
+	// lock files atomically, see WithUnsafeLocking.
+	UnsafeLocking bool `json:"unsafe_locking,omitempty" yaml:"unsafe_locking,omitempty"`
+
+	// AccessKey and SecretKey are static credentials. The default
+	// credential chain is used if they are empty.
+	AccessKey string `json:"access_key,omitempty" yaml:"access_key,omitempty"`
+	SecretKey string `json:"secret_key,omitempty" yaml:"secret_key,omitempty"`
+
+	KMSKey    string `json:"kms_key,omitempty" yaml:"kms_key,omitempty"`
+	CacheSize int    `json:"cache_size,omitempty" yaml:"cache_size,omitempty"`
+
+	// CAFile is a PEM file of CAs trusted in addition to the
+	// system roots.
+	CAFile             string `json:"ca_file,omitempty" yaml:"ca_file,omitempty"`
+	Proxy              string `json:"proxy,omitempty" yaml:"proxy,omitempty"`
+	NoProxy            string `json:"no_proxy,omitempty" yaml:"no_proxy,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty"`
+
+	// AllowHTTP allows a plain http:// Endpoint.
+	AllowHTTP bool `json:"allow_http,omitempty" yaml:"allow_http,omitempty"`
+}
+
+// LoadConfig reads a Config from the JSON or YAML file at path. The
+// format is chosen by the file extension: ".yaml" and ".yml" are read
+// as YAML, anything else as JSON.
+func LoadConfig(path string) (Config, error) {
+	var c Config
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &c)
+	default:
+		err = json.Unmarshal(b, &c)
+	}
+	if err != nil {
+		return c, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// NewStore returns a store configured by c. opts are applied after the
+// options derived from c, so they take precedence. Unlike NewS3Store, it
+// returns an error instead of exiting if the AWS configuration can't be
+// loaded or the bucket can't be created.
+func (c Config) NewStore(opts ...Option) (*S3Store, error) {
+	if c.Bucket == "" {
+		return nil, errors.New("no bucket configured")
+	}
+	cfgOpts, err := c.options()
+	if err != nil {
+		return nil, err
+	}
+	return buildS3Store(c.Bucket, c.Region, append(cfgOpts, opts...))
+}
+
+// options returns the options c describes, other than the
+// bucket and region.
+func (c Config) options() ([]Option, error) {
+	var opts []Option
+	if c.Prefix != "" {
+		opts = append(opts, WithPrefix(c.Prefix))
+	}
+	if c.Endpoint != "" {
+		opts = append(opts, WithEndpoint(c.Endpoint))
+	}
+	if c.PathStyle {
+		opts = append(opts, WithPathStyle())
+	}
+	if c.Provider != "" {
+		if _, ok := providerProfiles[c.Provider]; !ok {
+			return nil, fmt.Errorf("unknown provider %q", c.Provider)
+		}
+		opts = append(opts, WithProviderProfile(c.Provider))
+	}
+	if c.UnsafeLocking {
+		opts = append(opts, WithUnsafeLocking())
+	}
+	if (c.AccessKey == "") != (c.SecretKey == "") {
+		return nil, errors.New("access key and secret key must be set together")
+	}
+	if c.AccessKey != "" {
+		opts = append(opts, WithCredentialsProvider(credentials.NewStaticCredentialsProvider(c.AccessKey, c.SecretKey, "")))
+	}
+	if c.KMSKey != "" {
+		opts = append(opts, WithKMSKey(c.KMSKey))
+	}
+	if c.CacheSize > 0 {
+		opts = append(opts, WithCache(c.CacheSize))
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+		}
+		opts = append(opts, WithRootCAs(pool))
+	}
+	if c.Proxy != "" {
+		opts = append(opts, WithProxy(c.Proxy, c.NoProxy))
+	}
+	if c.InsecureSkipVerify {
+		opts = append(opts, WithInsecureSkipTLSVerify())
+	}
+	if c.AllowHTTP {
+		opts = append(opts, WithAllowHTTP())
+	}
+	return opts, nil
+}
@@ -0,0 +1,57 @@
+package s3store
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// deadlockThreshold is how long Lock waits for a lock
+// that isn't stale before giving up. It is a variable
+// so that tests can shorten it.
+var deadlockThreshold = staleLockDuration * 2
+
+// DeadlockError is returned by Lock when a lock could not be obtained
+// within the deadlock threshold although it never became stale. It
+// matches ErrLockHeld.
+type DeadlockError struct {
+	Key string
+	// Waited is how long Lock waited.
+	Waited time.Duration
+	// LockAge is the age of the lock file when Lock gave up.
+	LockAge time.Duration
+	// Owner describes the lock's holder.
+	Owner LockInfo
+	// Waiters is the number of Lock calls in this process,
+	// including this one, that were waiting for the lock.
+	Waiters int
+}
+
+func (e *DeadlockError) Error() string {
+	return fmt.Sprintf("possible deadlock: %s passed trying to obtain lock for %s "+
+		"(lock age %s, held by %s pid %d instance %s, %d waiters)",
+		e.Waited.Round(time.Second), e.Key, e.LockAge.Round(time.Second),
+		e.Owner.Hostname, e.Owner.PID, e.Owner.InstanceID, e.Waiters)
+}
+
+func (e *DeadlockError) Unwrap() error {
+	return ErrLockHeld
+}
+
+// deadlock gathers diagnostics about the lock rec stored in lockFile
+// that Lock waited for since start, and logs them.
+func (s *S3Store) deadlock(key, lockFile string, start time.Time, rec lockRecord) error {
+	age := time.Since(rec.modified)
+	if !rec.AcquiredAt.IsZero() {
+		age = time.Since(rec.AcquiredAt)
+	}
+	err := &DeadlockError{
+		Key:     s.logKey(key),
+		Waited:  time.Since(start),
+		LockAge: age,
+		Owner:   rec.LockInfo,
+		Waiters: s.lockState.waiting(lockFile),
+	}
+	log.Printf("[ERROR][%s] %v", s, err)
+	return err
+}
@@ -0,0 +1,34 @@
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+func TestDeadlockError(t *testing.T) {
+	s3store.SetDeadlockThreshold(t, 100*time.Millisecond)
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	a := newTestStoreOn(t, mem)
+	b := newTestStoreOn(t, mem)
+	if err := a.Lock(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := b.Lock(ctx, "k")
+	var de *s3store.DeadlockError
+	if !errors.As(err, &de) || !errors.Is(err, s3store.ErrLockHeld) {
+		t.Fatalf("Lock of a lock held too long = %v, want a DeadlockError", err)
+	}
+	host, _ := os.Hostname()
+	if de.Key != "k" || de.Waiters != 1 || de.Waited < 100*time.Millisecond ||
+		de.Owner.PID != os.Getpid() || de.Owner.Hostname != host {
+		t.Fatalf("DeadlockError = %+v", de)
+	}
+}
@@ -0,0 +1,208 @@
+package s3store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// deleteBatchSize is the maximum number of keys
+// accepted by a single DeleteObjects call.
+const deleteBatchSize = 1000
+
+// DeleteError reports the keys that could not be deleted
+// by a batch delete, along with the reason for each.
+type DeleteError struct {
+	Failed map[string]error
+}
+
+func (e *DeleteError) Error() string {
+	return failedKeysMessage("deleting", e.Failed)
+}
+
+// failedKeysMessage formats the per-key errors of a batch operation,
+// sorted by key.
+func failedKeysMessage(verb string, failed map[string]error) string {
+	keys := make([]string, 0, len(failed))
+	for k := range failed {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	msgs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", k, failed[k]))
+	}
+	return fmt.Sprintf("%s %d keys failed: %s", verb, len(keys), strings.Join(msgs, "; "))
+}
+
+// DeleteMany deletes all of keys, issuing one DeleteObjects
+// request per 1000 keys instead of one request per key. If some
+// keys could not be deleted, a *DeleteError listing them is returned.
+func (s *S3Store) DeleteMany(ctx context.Context, keys []string) (err error) {
+	if s.skipDryRun("delete", keys...) {
+		return nil
+	}
+	defer func() { s.mutated(ctx, "delete", err, keys...) }()
+	objectKeys := make(map[string]string, len(keys))
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		name := s.Filename(ctx, key)
+		objectKeys[name] = key
+		names = append(names, name)
+	}
+
+	names, trashFailed := s.softDeleteNames(ctx, names)
+	failed, err := s.deleteObjects(ctx, names)
+	for name, ferr := range trashFailed {
+		failed[name] = ferr
+	}
+	for _, key := range keys {
+		if s.cache != nil {
+			s.cache.remove(key)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		derr := &DeleteError{Failed: make(map[string]error, len(failed))}
+		for name, ferr := range failed {
+			derr.Failed[objectKeys[name]] = ferr
+		}
+		return derr
+	}
+	return s.replicate(ctx, func(ctx context.Context, r *S3Store) error {
+		return r.DeleteMany(ctx, keys)
+	})
+}
+
+// DeletePrefix deletes every key stored under prefix, e.g. all assets
+// of a decommissioned site. Like List, prefix is a directory: deleting
+// "certificates/acme/example.com" leaves example.com.au alone. Locks,
+// snapshots and the audit log are kept unless prefix lies within their
+// directory.
+// Objects are listed page by page and each page is removed with a
+// single DeleteObjects request. If some keys could not be deleted, a
+// *DeleteError listing them is returned after the remaining keys have
+// been processed.
+func (s *S3Store) DeletePrefix(ctx context.Context, prefix string) (err error) {
+	if s.skipDryRun("delete prefix", prefix) {
+		return nil
+	}
+	defer func() { s.mutated(ctx, "delete prefix", err, prefix) }()
+	prefix = strings.Trim(prefix, "/")
+	namePrefix := s.Filename(ctx, prefix)
+	if namePrefix != "" {
+		namePrefix += "/"
+	}
+	derr := &DeleteError{Failed: make(map[string]error)}
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: s.bucket,
+		Prefix: aws.String(namePrefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		names := make([]string, 0, len(page.Contents))
+		for _, obj := range page.Contents {
+			name := aws.ToString(obj.Key)
+			if prefix != "" && !strings.HasPrefix(s.keyName(name), prefix+"/") {
+				continue
+			}
+			if s.protected(name, prefix) || s.softDelete && s.inTrash(name) {
+				continue
+			}
+			names = append(names, name)
+		}
+		names, trashFailed := s.softDeleteNames(ctx, names)
+		failed, err := s.deleteObjects(ctx, names)
+		for name, ferr := range trashFailed {
+			failed[name] = ferr
+		}
+		for _, name := range names {
+			if s.cache != nil {
+				s.cache.remove(s.keyName(name))
+			}
+		}
+		if err != nil {
+			return err
+		}
+		for name, ferr := range failed {
+			derr.Failed[s.keyName(name)] = ferr
+		}
+	}
+	if len(derr.Failed) > 0 {
+		return derr
+	}
+	return s.replicate(ctx, func(ctx context.Context, r *S3Store) error {
+		return r.DeletePrefix(ctx, prefix)
+	})
+}
+
+// protected reports whether DeletePrefix of prefix must leave the
+// object name alone because it is a lock, part of a snapshot or of the
+// audit log, and prefix doesn't lie within their directory.
+func (s *S3Store) protected(name, prefix string) bool {
+	if strings.HasPrefix(name, s.lockDir()+"/") {
+		return !strings.HasPrefix(prefix+"/", "locks/")
+	}
+	key := s.keyName(name)
+	return strings.HasPrefix(key, snapshotDir+"/") && !strings.HasPrefix(prefix+"/", snapshotDir+"/") ||
+		auditLogged(key, prefix)
+}
+
+// softDeleteNames moves names to the trash if soft delete is enabled.
+// It returns the names that may now be deleted and those that could not
+// be moved.
+func (s *S3Store) softDeleteNames(ctx context.Context, names []string) ([]string, map[string]error) {
+	if !s.softDelete {
+		return names, nil
+	}
+	failed := s.moveToTrash(ctx, names)
+	moved := make([]string, 0, len(names))
+	for _, name := range names {
+		if _, ok := failed[name]; !ok {
+			moved = append(moved, name)
+		}
+	}
+	return moved, failed
+}
+
+// deleteObjects deletes the given object keys in batches. It returns
+// the object keys S3 refused to delete; err is set only when a whole
+// request failed.
+func (s *S3Store) deleteObjects(ctx context.Context, names []string) (map[string]error, error) {
+	failed := make(map[string]error)
+	for start := 0; start < len(names); start += deleteBatchSize {
+		end := start + deleteBatchSize
+		if end > len(names) {
+			end = len(names)
+		}
+		objects := make([]types.ObjectIdentifier, 0, end-start)
+		for _, name := range names[start:end] {
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(name)})
+		}
+		input := &s3.DeleteObjectsInput{
+			Bucket: s.bucket,
+			Delete: &types.Delete{
+				Objects: objects,
+				Quiet:   aws.Bool(true),
+			},
+		}
+		result, err := s.client.DeleteObjects(ctx, input)
+		if err != nil {
+			return failed, err
+		}
+		for _, e := range result.Errors {
+			failed[aws.ToString(e.Key)] = fmt.Errorf("%s: %s", aws.ToString(e.Code), aws.ToString(e.Message))
+		}
+	}
+	return failed, nil
+}
@@ -0,0 +1,92 @@
+package s3store_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+// captureLog returns the output logged during the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(prev) })
+	return &buf
+}
+
+func TestDeleteMany(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	mustStore(t, s, "a", "b", "c")
+	if err := s.DeleteMany(ctx, []string{"a", "b", "missing"}); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := s.List(ctx, "", true)
+	if err != nil || strings.Join(keys, ",") != "c" {
+		t.Fatalf("keys left = %q, %v", keys, err)
+	}
+}
+
+func TestDeleteManyDryRunRedactsKeys(t *testing.T) {
+	out := captureLog(t)
+	s, _ := newTestStore(t, s3store.WithDryRun(), s3store.WithRedaction())
+	keys := []string{"certificates/acme/a.com/a.com.key", "certificates/acme/a.com/a.com.crt"}
+	if err := s.DeleteMany(context.Background(), keys); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "a.com.key") {
+		t.Errorf("private key name logged: %s", out)
+	}
+	if !strings.Contains(out.String(), "a.com.crt") {
+		t.Errorf("certificate name not logged: %s", out)
+	}
+}
+
+func TestDeletePrefix(t *testing.T) {
+	ctx := context.Background()
+	s, mem := newTestStore(t)
+	staging := newTestStoreOn(t, mem, s3store.WithPrefix("certmagic-staging"))
+	mustStore(t, s, "certificates/acme/example.com/example.com.crt", "certificates/acme/example.com.au/example.com.au.crt", "top")
+	mustStore(t, staging, "k")
+
+	if err := s.DeletePrefix(ctx, "certificates/acme/example.com/"); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := s.List(ctx, "", true)
+	if err != nil || strings.Join(keys, ",") != "certificates/acme/example.com.au/example.com.au.crt,top" {
+		t.Fatalf("keys after deleting a site = %q, %v, want its sibling kept", keys, err)
+	}
+
+	if _, err := s.Snapshot(ctx, "pre"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Lock(ctx, "l"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeletePrefix(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	keys, err = s.List(ctx, "", false)
+	if err != nil || strings.Join(keys, ",") != "locks,snapshots" {
+		t.Fatalf("keys after deleting everything = %q, %v, want only locks and snapshots", keys, err)
+	}
+	if err := s.Unlock(ctx, "l"); err != nil {
+		t.Fatalf("Unlock after DeletePrefix: %v", err)
+	}
+	if !staging.Exists(ctx, "k") {
+		t.Fatal("DeletePrefix deleted a key of a store with a longer prefix")
+	}
+
+	if err := s.DeletePrefix(ctx, "snapshots"); err != nil {
+		t.Fatal(err)
+	}
+	if snapshots, err := s.ListSnapshots(ctx); err != nil || len(snapshots) != 0 {
+		t.Fatalf("snapshots after deleting them = %q, %v", snapshots, err)
+	}
+}
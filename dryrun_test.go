@@ -0,0 +1,79 @@
+package s3store_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+// objectNames returns the names of all objects in bucket.
+func objectNames(t *testing.T, mem *memstore.Client, bucket string) []string {
+	t.Helper()
+	out, err := mem.ListObjectsV2(context.Background(), &s3.ListObjectsV2Input{Bucket: aws.String(bucket)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, obj := range out.Contents {
+		names = append(names, aws.ToString(obj.Key))
+	}
+	return names
+}
+
+func TestDryRun(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket, "other")
+	live := newTestStoreOn(t, mem, s3store.WithSoftDelete(0))
+	mustStore(t, live, "a", "b")
+	if err := live.Delete(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	expired, _ := json.Marshal(s3store.LockInfo{ExpiresAt: time.Now().Add(-time.Hour)})
+	_, err := mem.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(testBucket),
+		Key:    aws.String("certmagic/locks/stale.lock"),
+		Body:   strings.NewReader(string(expired)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := objectNames(t, mem, testBucket)
+
+	s := newTestStoreOn(t, mem, s3store.WithDryRun(), s3store.WithSoftDelete(0))
+	mustStore(t, s, "c")
+	if err := s.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.CleanStaleLocks(ctx); err != nil || n != 1 {
+		t.Errorf("CleanStaleLocks = %d, %v, want 1 lock", n, err)
+	}
+	if n, err := s.PurgeTrash(ctx); err != nil || n != 1 {
+		t.Errorf("PurgeTrash = %d, %v, want 1 object", n, err)
+	}
+	if err := s.Verify(ctx); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	dst := s3store.NewS3Store("other", "us-east-1", s3store.WithS3API(mem), s3store.WithDryRun())
+	if _, err := s.Relocate(ctx, dst, true); err != nil {
+		t.Errorf("Relocate: %v", err)
+	}
+
+	if after := objectNames(t, mem, testBucket); strings.Join(after, ",") != strings.Join(before, ",") {
+		t.Errorf("dry run changed the bucket from %q to %q", before, after)
+	}
+	if names := objectNames(t, mem, "other"); len(names) != 0 {
+		t.Errorf("dry run relocated %q", names)
+	}
+	for _, h := range s.LatencyMetrics() {
+		if h.Op == "delete" && h.Count != 0 {
+			t.Errorf("dry run recorded %d delete latencies", h.Count)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package s3store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strings"
+)
+
+// KeyEncoder translates between certmagic keys and object names, e.g.
+// to avoid characters some S3 compatible providers reject. Encode is
+// applied to each "/" separated element of a key on its own, so that
+// listing by prefix keeps working. Decode reverses Encode and reports
+// false if elem can't be decoded.
+type KeyEncoder interface {
+	Encode(elem string) string
+	Decode(elem string) (string, bool)
+}
+
+// Key encoders for use with WithKeyEncoder.
+var (
+	// IdentityEncoding stores keys as they are. It is the default.
+	IdentityEncoding KeyEncoder = identityEncoding{}
+
+	// URLEncoding URL-escapes each key element.
+	URLEncoding KeyEncoder = urlEncoding{}
+
+	// HashEncoding replaces each key element with its SHA-256 hash. It
+	// can't be decoded, so List and the other APIs returning keys
+	// return the hashed names.
+	HashEncoding KeyEncoder = hashEncoding{}
+)
+
+// WithKeyEncoder sets how keys are encoded into object names. Objects
+// written with a different encoding aren't found, so it must not be
+// changed for an existing store without migrating its data.
+func WithKeyEncoder(enc KeyEncoder) Option {
+	return func(s *S3Store) {
+		s.keyEncoder = enc
+	}
+}
+
+type identityEncoding struct{}
+
+func (identityEncoding) Encode(elem string) string         { return elem }
+func (identityEncoding) Decode(elem string) (string, bool) { return elem, true }
+
+type urlEncoding struct{}
+
+func (urlEncoding) Encode(elem string) string { return url.PathEscape(elem) }
+
+func (urlEncoding) Decode(elem string) (string, bool) {
+	s, err := url.PathUnescape(elem)
+	return s, err == nil
+}
+
+type hashEncoding struct{}
+
+func (hashEncoding) Encode(elem string) string {
+	sum := sha256.Sum256([]byte(elem))
+	return hex.EncodeToString(sum[:])
+}
+
+func (hashEncoding) Decode(string) (string, bool) { return "", false }
+
+// encodeKey applies the store's key encoder to each element of key.
+func (s *S3Store) encodeKey(key string) string {
+	if s.keyEncoder == nil || key == "" {
+		return key
+	}
+	elems := strings.Split(key, "/")
+	for i, elem := range elems {
+		elems[i] = s.keyEncoder.Encode(elem)
+	}
+	return strings.Join(elems, "/")
+}
+
+// decodeKey is the inverse of encodeKey. Elements that can't be
+// decoded are returned as stored.
+func (s *S3Store) decodeKey(name string) string {
+	if s.keyEncoder == nil || name == "" {
+		return name
+	}
+	elems := strings.Split(name, "/")
+	for i, elem := range elems {
+		if dec, ok := s.keyEncoder.Decode(elem); ok {
+			elems[i] = dec
+		}
+	}
+	return strings.Join(elems, "/")
+}
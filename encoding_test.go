@@ -0,0 +1,41 @@
+package s3store_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+func TestKeyEncoding(t *testing.T) {
+	ctx := context.Background()
+	s, mem := newTestStore(t, s3store.WithPrefix("p"), s3store.WithKeyEncoder(s3store.URLEncoding))
+	mustStore(t, s, "certificates/*.example.com/*.example.com+ü.crt")
+	if names := objectNames(t, mem, testBucket); !reflect.DeepEqual(names, []string{"p/certificates/%2A.example.com/%2A.example.com+%C3%BC.crt"}) {
+		t.Fatalf("objects = %q", names)
+	}
+	keys, err := s.List(ctx, "certificates", true)
+	if err != nil || !reflect.DeepEqual(keys, []string{"certificates/*.example.com/*.example.com+ü.crt"}) {
+		t.Fatalf("List = %q, %v", keys, err)
+	}
+	if v, err := s.Load(ctx, keys[0]); err != nil || string(v) != keys[0] {
+		t.Fatalf("Load = %q, %v", v, err)
+	}
+
+	s, mem = newTestStore(t, s3store.WithPrefix("p"), s3store.WithKeyEncoder(s3store.HashEncoding))
+	mustStore(t, s, "a/b")
+	hash := func(elem string) string {
+		sum := sha256.Sum256([]byte(elem))
+		return hex.EncodeToString(sum[:])
+	}
+	want := "p/" + hash("a") + "/" + hash("b")
+	if names := objectNames(t, mem, testBucket); !reflect.DeepEqual(names, []string{want}) {
+		t.Fatalf("objects = %q, want %s", names, want)
+	}
+	if v, err := s.Load(ctx, "a/b"); err != nil || string(v) != "a/b" {
+		t.Fatalf("Load = %q, %v", v, err)
+	}
+}
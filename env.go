@@ -0,0 +1,90 @@
+package s3store
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// NewS3StoreFromEnv returns a store configured by the following
+// environment variables, for container deployments:
+//
+//	S3STORE_BUCKET                bucket name or access point ARN (required)
+//	S3STORE_REGION                region; the SDK default, e.g. AWS_REGION, if unset
+//	S3STORE_PREFIX                key prefix; "certmagic" if unset
+//	S3STORE_ENDPOINT              custom endpoint URL
+//	S3STORE_PATH_STYLE            "true" for path-style addressing
+//	S3STORE_PROVIDER              provider profile: aws, r2, b2 or spaces
+//	S3STORE_UNSAFE_LOCKING        "true" to allow locking on providers without conditional writes
+//	S3STORE_ACCESS_KEY            static credentials; the default chain if unset
+//	S3STORE_SECRET_KEY
+//	S3STORE_KMS_KEY               KMS key ID or ARN for SSE-KMS
+//	S3STORE_CACHE_SIZE            number of values to cache in memory
+//	S3STORE_CA_FILE               PEM file of CAs to trust in addition to the system roots
+//	S3STORE_PROXY                 proxy URL
+//	S3STORE_NO_PROXY              hosts to connect to directly, in the NO_PROXY format
+//	S3STORE_INSECURE_SKIP_VERIFY  "true" to skip TLS certificate verification
+//	S3STORE_ALLOW_HTTP            "true" to allow a plain http:// endpoint
+//
+// opts are applied after the options derived from the environment, so
+// they take precedence. An invalid variable, an AWS configuration that
+// can't be loaded or a bucket that can't be created is returned as an
+// error.
+func NewS3StoreFromEnv(opts ...Option) (*S3Store, error) {
+	c, err := configFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return c.NewStore(opts...)
+}
+
+// configFromEnv returns the Config described by the
+// S3STORE_ environment variables.
+func configFromEnv() (Config, error) {
+	c := Config{
+		Bucket:    os.Getenv("S3STORE_BUCKET"),
+		Region:    os.Getenv("S3STORE_REGION"),
+		Prefix:    os.Getenv("S3STORE_PREFIX"),
+		Endpoint:  os.Getenv("S3STORE_ENDPOINT"),
+		Provider:  Provider(os.Getenv("S3STORE_PROVIDER")),
+		AccessKey: os.Getenv("S3STORE_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3STORE_SECRET_KEY"),
+		KMSKey:    os.Getenv("S3STORE_KMS_KEY"),
+		CAFile:    os.Getenv("S3STORE_CA_FILE"),
+		Proxy:     os.Getenv("S3STORE_PROXY"),
+		NoProxy:   os.Getenv("S3STORE_NO_PROXY"),
+	}
+	var err error
+	if c.PathStyle, err = envBool("S3STORE_PATH_STYLE"); err != nil {
+		return c, err
+	}
+	if c.UnsafeLocking, err = envBool("S3STORE_UNSAFE_LOCKING"); err != nil {
+		return c, err
+	}
+	if c.InsecureSkipVerify, err = envBool("S3STORE_INSECURE_SKIP_VERIFY"); err != nil {
+		return c, err
+	}
+	if c.AllowHTTP, err = envBool("S3STORE_ALLOW_HTTP"); err != nil {
+		return c, err
+	}
+	if v := os.Getenv("S3STORE_CACHE_SIZE"); v != "" {
+		if c.CacheSize, err = strconv.Atoi(v); err != nil {
+			return c, fmt.Errorf("S3STORE_CACHE_SIZE: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// envBool parses the boolean environment variable name, which
+// defaults to false.
+func envBool(name string) (bool, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", name, err)
+	}
+	return b, nil
+}
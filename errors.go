@@ -0,0 +1,122 @@
+package s3store
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// Errors returned by the store. They wrap the underlying SDK error, so
+// callers can branch on the failure mode with errors.Is while errors.As
+// still reaches the SDK's types. ErrNotFound also matches
+// fs.ErrNotExist, as certmagic requires.
+var (
+	ErrNotFound     = fmt.Errorf("s3store: key not found: %w", fs.ErrNotExist)
+	ErrExists       = fmt.Errorf("s3store: key already exists: %w", fs.ErrExist)
+	ErrModified     = errors.New("s3store: key modified concurrently")
+	ErrLockHeld     = errors.New("s3store: lock held by another process")
+	ErrLockStale    = errors.New("s3store: lock is stale")
+	ErrLockNotHeld  = errors.New("s3store: lock not held by this store")
+	ErrLockUnsafe   = errors.New("s3store: provider can't create lock files atomically, see WithUnsafeLocking")
+	ErrAccessDenied = errors.New("s3store: access denied")
+	ErrThrottled    = errors.New("s3store: request throttled")
+	ErrArchived     = errors.New("s3store: object archived, restore requested")
+	ErrCircuitOpen  = errors.New("s3store: circuit breaker open, S3 unreachable")
+
+	ErrInsecureTransport = errors.New("s3store: refusing to send request over plain HTTP, see WithAllowHTTP")
+	ErrPresignDenied     = errors.New("s3store: key may not be presigned, see WithPresignPatterns")
+)
+
+// Error describes a failed operation on a key, so that errors from
+// several stores in one process can be told apart.
+type Error struct {
+	Op     string
+	Bucket string
+	Key    string
+	// Kind is one of the ErrXxx values, or nil if the
+	// failure doesn't match any of them.
+	Kind error
+	// Err is the underlying error, usually from the SDK.
+	Err error
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s %s/%s", e.Op, e.Bucket, e.Key)
+	if e.Kind != nil {
+		msg += ": " + e.Kind.Error()
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// opError wraps err, if not nil, in an *Error for op on key.
+func (s *S3Store) opError(op, key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	return s.kindError(op, key, kindOf(err), err)
+}
+
+// kindError wraps err in an *Error of the given kind for op on key
+// and counts it in the store's error metrics.
+func (s *S3Store) kindError(op, key string, kind, err error) error {
+	e := &Error{
+		Op:     op,
+		Bucket: aws.ToString(s.bucket),
+		Key:    s.logKey(key),
+		Kind:   kind,
+		Err:    err,
+	}
+	s.metrics.countError(e)
+	return e
+}
+
+func kindOf(err error) error {
+	var nsk *types.NoSuchKey
+	var nf *types.NotFound
+	if errors.As(err, &nsk) || errors.As(err, &nf) {
+		return ErrNotFound
+	}
+	var ios *types.InvalidObjectState
+	if errors.As(err, &ios) {
+		return ErrArchived
+	}
+	var ae smithy.APIError
+	if errors.As(err, &ae) {
+		if ae.ErrorCode() == "AccessDenied" {
+			return ErrAccessDenied
+		}
+		if _, ok := retry.DefaultThrottleErrorCodes[ae.ErrorCode()]; ok {
+			return ErrThrottled
+		}
+	}
+	var re *awshttp.ResponseError
+	if errors.As(err, &re) {
+		switch re.HTTPStatusCode() {
+		case http.StatusNotFound:
+			return ErrNotFound
+		case http.StatusForbidden:
+			return ErrAccessDenied
+		case http.StatusTooManyRequests:
+			return ErrThrottled
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+// failingAPI fails every GetObject with err.
+type failingAPI struct {
+	*memstore.Client
+	err error
+}
+
+func (f failingAPI) GetObject(context.Context, *s3.GetObjectInput, ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	return nil, f.err
+}
+
+func TestTypedErrors(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		err  error
+		kind error
+	}{
+		{&types.NoSuchKey{}, s3store.ErrNotFound},
+		{&smithy.GenericAPIError{Code: "AccessDenied"}, s3store.ErrAccessDenied},
+		{&smithy.GenericAPIError{Code: "SlowDown"}, s3store.ErrThrottled},
+		{&types.InvalidObjectState{}, s3store.ErrArchived},
+		{errors.New("boom"), nil},
+	}
+	for _, tt := range tests {
+		s := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(failingAPI{memstore.New(testBucket), tt.err}))
+		_, err := s.Load(ctx, "k")
+		var e *s3store.Error
+		if !errors.As(err, &e) {
+			t.Fatalf("Load error %v is not an *Error", err)
+		}
+		if e.Op != "load" || e.Bucket != testBucket || e.Key != "k" || e.Kind != tt.kind {
+			t.Errorf("Load error = %+v, want kind %v", e, tt.kind)
+		}
+		if tt.kind != nil && !errors.Is(err, tt.kind) {
+			t.Errorf("Load error %v doesn't match %v", err, tt.kind)
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Load error %v doesn't wrap the SDK error", err)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package s3store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// certificatesDir is the directory certmagic stores certificates,
+// private keys and their metadata in.
+const certificatesDir = "certificates"
+
+// ChangeEvent describes a change to a stored object.
+type ChangeEvent struct {
+	Time   time.Time `json:"time"`
+	Op     string    `json:"op"`
+	Key    string    `json:"key"`
+	Bucket string    `json:"bucket"`
+	Prefix string    `json:"prefix"`
+}
+
+// Publisher delivers change events to an external system.
+type Publisher interface {
+	Publish(ctx context.Context, event ChangeEvent) error
+}
+
+// WithPublisher publishes a ChangeEvent through p after every successful
+// write or delete under certificates/, so that inventory, CMDB and
+// monitoring systems learn about issuance and renewals as they happen.
+// Publishing failures are logged and don't fail the operation.
+func WithPublisher(p Publisher) Option {
+	return func(s *S3Store) {
+		s.publishers = append(s.publishers, p)
+	}
+}
+
+// mutated is called after every mutating operation on keys
+// to record it in the audit log and notify publishers.
+func (s *S3Store) mutated(ctx context.Context, op string, err error, keys ...string) {
+	for _, key := range keys {
+		s.audit(ctx, op, key, err)
+		if err == nil && (op == "store" || strings.HasPrefix(op, "delete")) {
+			s.publish(ctx, op, key)
+			s.runCallbacks(ctx, op, key)
+		}
+	}
+}
+
+// publish sends a ChangeEvent for key if it lies under certificates/.
+func (s *S3Store) publish(ctx context.Context, op, key string) {
+	if len(s.publishers) == 0 || !strings.HasPrefix(key, certificatesDir+"/") {
+		return
+	}
+	event := s.changeEvent(op, key)
+	for _, p := range s.publishers {
+		if err := p.Publish(context.WithoutCancel(ctx), event); err != nil {
+			log.Printf("[ERROR][%s] Publishing %s event for '%s': %v", s, op, s.logKey(key), err)
+		}
+	}
+}
+
+// changeEvent describes op on key.
+func (s *S3Store) changeEvent(op, key string) ChangeEvent {
+	return ChangeEvent{
+		Time:   time.Now().UTC(),
+		Op:     op,
+		Key:    key,
+		Bucket: aws.ToString(s.bucket),
+		Prefix: s.prefix,
+	}
+}
+
+// SNSPublishAPI is the method of the SNS client used by SNSPublisher.
+type SNSPublishAPI interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+// SNSPublisher publishes change events as JSON messages to an SNS topic.
+type SNSPublisher struct {
+	Client   SNSPublishAPI
+	TopicARN string
+}
+
+// Publish sends event to the topic.
+func (p *SNSPublisher) Publish(ctx context.Context, event ChangeEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = p.Client.Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(p.TopicARN),
+		Message:  aws.String(string(b)),
+	})
+	return err
+}
+
+// SQSSendAPI is the method of the SQS client used by SQSPublisher.
+type SQSSendAPI interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+}
+
+// SQSPublisher sends change events as JSON messages to an SQS queue.
+type SQSPublisher struct {
+	Client   SQSSendAPI
+	QueueURL string
+}
+
+// Publish sends event to the queue.
+func (p *SQSPublisher) Publish(ctx context.Context, event ChangeEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = p.Client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(p.QueueURL),
+		MessageBody: aws.String(string(b)),
+	})
+	return err
+}
+
+// EventBridgePutAPI is the method of the EventBridge
+// client used by EventBridgePublisher.
+type EventBridgePutAPI interface {
+	PutEvents(ctx context.Context, params *eventbridge.PutEventsInput, optFns ...func(*eventbridge.Options)) (*eventbridge.PutEventsOutput, error)
+}
+
+// EventBridgePublisher puts change events on an EventBridge bus with
+// the source "s3store" and detail type "Certificate Storage Change".
+// An empty EventBusName uses the default bus.
+type EventBridgePublisher struct {
+	Client       EventBridgePutAPI
+	EventBusName string
+}
+
+// Publish puts event on the bus.
+func (p *EventBridgePublisher) Publish(ctx context.Context, event ChangeEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	entry := ebtypes.PutEventsRequestEntry{
+		Source:     aws.String("s3store"),
+		DetailType: aws.String("Certificate Storage Change"),
+		Detail:     aws.String(string(b)),
+		Time:       aws.Time(event.Time),
+	}
+	if p.EventBusName != "" {
+		entry.EventBusName = aws.String(p.EventBusName)
+	}
+	out, err := p.Client.PutEvents(ctx, &eventbridge.PutEventsInput{
+		Entries: []ebtypes.PutEventsRequestEntry{entry},
+	})
+	if err != nil {
+		return err
+	}
+	if out.FailedEntryCount > 0 && len(out.Entries) > 0 {
+		return fmt.Errorf("putting event: %s: %s",
+			aws.ToString(out.Entries[0].ErrorCode), aws.ToString(out.Entries[0].ErrorMessage))
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package s3store_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+// publisherFunc is a Publisher calling itself.
+type publisherFunc func(ctx context.Context, event s3store.ChangeEvent) error
+
+func (f publisherFunc) Publish(ctx context.Context, event s3store.ChangeEvent) error {
+	return f(ctx, event)
+}
+
+func TestWithPublisher(t *testing.T) {
+	ctx := context.Background()
+	const crt = "certificates/acme/example.com/example.com.crt"
+	var events []s3store.ChangeEvent
+	record := publisherFunc(func(_ context.Context, event s3store.ChangeEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	failing := publisherFunc(func(context.Context, s3store.ChangeEvent) error {
+		return errors.New("topic not found")
+	})
+	logs := captureLog(t)
+	s, _ := newTestStore(t, s3store.WithPublisher(failing), s3store.WithPublisher(record))
+
+	mustStore(t, s, crt, "certificates-old/acme/example.com/example.com.crt", "other")
+	if err := s.Lock(ctx, "certificates/example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Unlock(ctx, "certificates/example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, crt); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, "certificates/missing"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, e := range events {
+		if e.Bucket != testBucket || e.Prefix != "certmagic" || e.Time.IsZero() {
+			t.Errorf("event = %+v", e)
+		}
+		got = append(got, e.Op+" "+e.Key)
+	}
+	want := []string{"store " + crt, "delete " + crt, "delete certificates/missing"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("published %q, want %q", got, want)
+	}
+	if !strings.Contains(logs.String(), "topic not found") {
+		t.Errorf("publishing failure not logged: %s", logs)
+	}
+}
+
+// fakeSNS records the messages published to it.
+type fakeSNS struct {
+	inputs []*sns.PublishInput
+}
+
+func (f *fakeSNS) Publish(_ context.Context, params *sns.PublishInput, _ ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	f.inputs = append(f.inputs, params)
+	return &sns.PublishOutput{}, nil
+}
+
+// fakeSQS records the messages sent to it.
+type fakeSQS struct {
+	inputs []*sqs.SendMessageInput
+}
+
+func (f *fakeSQS) SendMessage(_ context.Context, params *sqs.SendMessageInput, _ ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	f.inputs = append(f.inputs, params)
+	return &sqs.SendMessageOutput{}, nil
+}
+
+// fakeEventBridge records the events put on it, reporting
+// every entry as failed with code if it is set.
+type fakeEventBridge struct {
+	inputs []*eventbridge.PutEventsInput
+	code   string
+}
+
+func (f *fakeEventBridge) PutEvents(_ context.Context, params *eventbridge.PutEventsInput, _ ...func(*eventbridge.Options)) (*eventbridge.PutEventsOutput, error) {
+	f.inputs = append(f.inputs, params)
+	if f.code == "" {
+		return &eventbridge.PutEventsOutput{Entries: []ebtypes.PutEventsResultEntry{{EventId: aws.String("1")}}}, nil
+	}
+	return &eventbridge.PutEventsOutput{
+		FailedEntryCount: 1,
+		Entries:          []ebtypes.PutEventsResultEntry{{ErrorCode: aws.String(f.code), ErrorMessage: aws.String("denied")}},
+	}, nil
+}
+
+func TestPublishers(t *testing.T) {
+	ctx := context.Background()
+	event := s3store.ChangeEvent{
+		Time:   time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Op:     "store",
+		Key:    "certificates/acme/example.com/example.com.crt",
+		Bucket: testBucket,
+		Prefix: "certmagic",
+	}
+	decode := func(msg *string) s3store.ChangeEvent {
+		t.Helper()
+		var got s3store.ChangeEvent
+		if err := json.Unmarshal([]byte(aws.ToString(msg)), &got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	topic := &fakeSNS{}
+	p := &s3store.SNSPublisher{Client: topic, TopicARN: "arn:aws:sns:us-east-1:123456789012:certs"}
+	if err := p.Publish(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+	if len(topic.inputs) != 1 || aws.ToString(topic.inputs[0].TopicArn) != p.TopicARN || decode(topic.inputs[0].Message) != event {
+		t.Errorf("SNS inputs = %+v", topic.inputs)
+	}
+
+	queue := &fakeSQS{}
+	q := &s3store.SQSPublisher{Client: queue, QueueURL: "https://sqs.us-east-1.amazonaws.com/123456789012/certs"}
+	if err := q.Publish(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+	if len(queue.inputs) != 1 || aws.ToString(queue.inputs[0].QueueUrl) != q.QueueURL || decode(queue.inputs[0].MessageBody) != event {
+		t.Errorf("SQS inputs = %+v", queue.inputs)
+	}
+
+	bus := &fakeEventBridge{}
+	for _, name := range []string{"", "certs"} {
+		bus.inputs = nil
+		e := &s3store.EventBridgePublisher{Client: bus, EventBusName: name}
+		if err := e.Publish(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+		if len(bus.inputs) != 1 || len(bus.inputs[0].Entries) != 1 {
+			t.Fatalf("EventBridge inputs = %+v", bus.inputs)
+		}
+		entry := bus.inputs[0].Entries[0]
+		if aws.ToString(entry.Source) != "s3store" || aws.ToString(entry.DetailType) != "Certificate Storage Change" ||
+			!aws.ToTime(entry.Time).Equal(event.Time) || decode(entry.Detail) != event {
+			t.Errorf("EventBridge entry = %+v", entry)
+		}
+		if (entry.EventBusName == nil) != (name == "") || aws.ToString(entry.EventBusName) != name {
+			t.Errorf("EventBusName = %v, want %q", entry.EventBusName, name)
+		}
+	}
+	bus.code = "AccessDeniedException"
+	if err := (&s3store.EventBridgePublisher{Client: bus}).Publish(ctx, event); err == nil || !strings.Contains(err.Error(), bus.code) {
+		t.Fatalf("Publish with a failed entry = %v, want its error code", err)
+	}
+}
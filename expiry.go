@@ -0,0 +1,112 @@
+package s3store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ExpiringCertificate is a stored certificate reported by ExpiryReport.
+type ExpiringCertificate struct {
+	Key      string
+	Names    []string
+	NotAfter time.Time
+}
+
+// ExpiryReport scans the certificates stored under certificates/ and
+// returns those expiring within the given window, including already
+// expired ones, soonest first. It lets operators catch renewal failures
+// independently of certmagic's own maintenance, e.g. from a cron job
+// exporting the result with WriteExpiryMetrics. Unparseable certificates
+// are logged and skipped; Audit reports them. Certificates deleted
+// during the scan are skipped, and those that can't be loaded are
+// reported in the error, which is returned along with the report for
+// all other certificates.
+func (s *S3Store) ExpiryReport(ctx context.Context, within time.Duration) ([]ExpiringCertificate, error) {
+	var keys []string
+	err := s.eachObject(ctx, certificatesDir, func(obj types.Object) error {
+		if key := s.keyName(aws.ToString(obj.Key)); strings.HasSuffix(key, ".crt") {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(within)
+	var expiring []ExpiringCertificate
+	var errs []error
+	for key, result := range s.LoadMany(ctx, keys) {
+		if errors.Is(result.Err, ErrNotFound) {
+			continue
+		}
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+			continue
+		}
+		leaf, err := parseLeaf(result.Value)
+		if err != nil {
+			log.Printf("[WARNING][%s] Skipping unparseable certificate '%s': %v", s, s.logKey(key), err)
+			continue
+		}
+		if leaf.NotAfter.After(deadline) {
+			continue
+		}
+		names := leaf.DNSNames
+		for _, ip := range leaf.IPAddresses {
+			names = append(names, ip.String())
+		}
+		if len(names) == 0 {
+			if name := firstName(leaf); name != "" {
+				names = []string{name}
+			}
+		}
+		expiring = append(expiring, ExpiringCertificate{Key: key, Names: names, NotAfter: leaf.NotAfter})
+	}
+	sort.Slice(expiring, func(i, j int) bool {
+		if !expiring[i].NotAfter.Equal(expiring[j].NotAfter) {
+			return expiring[i].NotAfter.Before(expiring[j].NotAfter)
+		}
+		return expiring[i].Key < expiring[j].Key
+	})
+	return expiring, errors.Join(errs...)
+}
+
+// WriteExpiryMetrics writes certs to w as a Prometheus gauge in the text
+// exposition format, one s3store_certificate_expiry_timestamp_seconds
+// sample per certificate, labelled with its key and first name. The
+// output can be served from a metrics handler or written to a file for
+// node_exporter's textfile collector, without the store depending on a
+// Prometheus client library.
+func WriteExpiryMetrics(w io.Writer, certs []ExpiringCertificate) error {
+	const metric = "s3store_certificate_expiry_timestamp_seconds"
+	_, err := fmt.Fprintf(w, "# HELP %s Expiry time of stored certificates in seconds since the Unix epoch.\n# TYPE %s gauge\n", metric, metric)
+	if err != nil {
+		return err
+	}
+	for _, c := range certs {
+		var name string
+		if len(c.Names) > 0 {
+			name = c.Names[0]
+		}
+		_, err := fmt.Fprintf(w, "%s{key=\"%s\",name=\"%s\"} %d\n",
+			metric, escapeLabel(c.Key), escapeLabel(name), c.NotAfter.Unix())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// escapeLabel escapes a Prometheus label value.
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
@@ -0,0 +1,97 @@
+package s3store_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+// newCert returns a self-signed PEM certificate for name expiring
+// after validity, along with its PEM private key.
+func newCert(t *testing.T, name string, validity time.Duration) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{name},
+		NotAfter:     time.Now().Add(validity),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// failingGet fails every GetObject of a name containing substr.
+type failingGet struct {
+	*memstore.Client
+	substr string
+}
+
+func (f failingGet) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if strings.Contains(aws.ToString(params.Key), f.substr) {
+		return nil, errors.New("connection reset")
+	}
+	return f.Client.GetObject(ctx, params, optFns...)
+}
+
+func TestExpiryReport(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	s := newTestStoreOn(t, mem)
+	for name, validity := range map[string]time.Duration{
+		"soon.com":    24 * time.Hour,
+		"expired.com": -time.Hour,
+		"later.com":   90 * 24 * time.Hour,
+		"broken.com":  time.Hour,
+	} {
+		crt, _ := newCert(t, name, validity)
+		if err := s.Store(ctx, "certificates/acme/"+name+"/"+name+".crt", crt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Store(ctx, "certificates/acme/junk.com/junk.com.crt", []byte("junk")); err != nil {
+		t.Fatal(err)
+	}
+
+	s = s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(failingGet{mem, "broken.com"}))
+	certs, err := s.ExpiryReport(ctx, 7*24*time.Hour)
+	if err == nil || !strings.Contains(err.Error(), "broken.com") {
+		t.Errorf("ExpiryReport error = %v, want the unreadable certificate reported", err)
+	}
+	if len(certs) != 2 || certs[0].Names[0] != "expired.com" || certs[1].Names[0] != "soon.com" {
+		t.Fatalf("ExpiryReport = %+v, want expired.com and soon.com", certs)
+	}
+
+	var buf bytes.Buffer
+	if err := s3store.WriteExpiryMetrics(&buf, certs); err != nil {
+		t.Fatal(err)
+	}
+	want := `s3store_certificate_expiry_timestamp_seconds{key="certificates/acme/soon.com/soon.com.crt",name="soon.com"}`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("metrics lack %s:\n%s", want, buf.String())
+	}
+}
@@ -0,0 +1,30 @@
+package s3store
+
+import (
+	"testing"
+	"time"
+)
+
+// SetDeadlockThreshold shortens how long Lock waits before reporting
+// a deadlock for the duration of the test.
+func SetDeadlockThreshold(t *testing.T, d time.Duration) {
+	prev := deadlockThreshold
+	deadlockThreshold = d
+	t.Cleanup(func() { deadlockThreshold = prev })
+}
+
+// SetStaleLockDuration shortens how long locks last without being
+// renewed for the duration of the test.
+func SetStaleLockDuration(t *testing.T, d time.Duration) {
+	prev := staleLockDuration
+	staleLockDuration = d
+	t.Cleanup(func() { staleLockDuration = prev })
+}
+
+// SetFailoverCooldown shortens how long reads are served from the
+// failover bucket for the duration of the test.
+func SetFailoverCooldown(t *testing.T, d time.Duration) {
+	prev := failoverCooldown
+	failoverCooldown = d
+	t.Cleanup(func() { failoverCooldown = prev })
+}
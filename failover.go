@@ -0,0 +1,97 @@
+package s3store
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// failoverCooldown is how long reads are served from the
+// secondary bucket before the primary is tried again.
+var failoverCooldown = 30 * time.Second
+
+// WithFailover retries Load, Exists and List against a secondary bucket,
+// typically a replica in another region, when the primary is
+// unreachable. After a failure the primary is considered unhealthy and
+// reads go straight to the secondary; it is tried again once
+// failoverCooldown has passed, failing back automatically when it
+// responds. Writes always go to the primary.
+func WithFailover(bucketName, region string) Option {
+	return func(s *S3Store) {
+		s.failoverBucket = bucketName
+		s.failoverRegion = region
+	}
+}
+
+// failover tracks the health of the primary bucket.
+type failover struct {
+	secondary *S3Store
+
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+func (f *failover) primaryHealthy() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return time.Now().After(f.downUntil)
+}
+
+func (f *failover) markDown() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.downUntil = time.Now().Add(failoverCooldown)
+}
+
+func (f *failover) markUp() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.downUntil = time.Time{}
+}
+
+// withFailover runs the read op against s, or against the failover
+// bucket while s is unhealthy or if op fails because s is unreachable.
+func (s *S3Store) withFailover(ctx context.Context, op func(st *S3Store) error) error {
+	f := s.failover
+	if f == nil {
+		return op(s)
+	}
+	if f.primaryHealthy() {
+		err := op(s)
+		if !isUnavailable(ctx, err) {
+			f.markUp()
+			return err
+		}
+		log.Printf("[WARNING][%s] Primary bucket unavailable, failing over to %s: %v",
+			s, aws.ToString(f.secondary.bucket), err)
+		f.markDown()
+	}
+	return op(f.secondary)
+}
+
+// isUnavailable reports whether err indicates that the bucket could
+// not be reached: the request couldn't be sent, timed out or was
+// answered with a server error. Definitive answers such as a missing
+// key or denied access, and failures of the store itself such as an
+// unencodable value, are not.
+func isUnavailable(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var re *awshttp.ResponseError
+	if errors.As(err, &re) && re.HTTPStatusCode() != 0 {
+		return re.HTTPStatusCode() >= http.StatusInternalServerError
+	}
+	var sendErr *smithyhttp.RequestSendError
+	var netErr net.Error
+	return errors.As(err, &sendErr) || errors.As(err, &netErr) ||
+		errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrCircuitOpen)
+}
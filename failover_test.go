@@ -0,0 +1,89 @@
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+// bucketOutage fails every GetObject on bucket with err while it is set.
+type bucketOutage struct {
+	*memstore.Client
+	bucket string
+	err    *error
+}
+
+func (o bucketOutage) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if *o.err != nil && aws.ToString(params.Bucket) == o.bucket {
+		return nil, *o.err
+	}
+	return o.Client.GetObject(ctx, params, optFns...)
+}
+
+func TestFailover(t *testing.T) {
+	s3store.SetFailoverCooldown(t, 50*time.Millisecond)
+	ctx := context.Background()
+	mem := memstore.New(testBucket, "secondary")
+	if err := newTestStoreOn(t, mem).Store(ctx, "k", []byte("primary")); err != nil {
+		t.Fatal(err)
+	}
+	secondary := s3store.NewS3Store("secondary", "us-east-1", s3store.WithS3API(mem))
+	if err := secondary.Store(ctx, "k", []byte("secondary")); err != nil {
+		t.Fatal(err)
+	}
+	var outage error
+	s := s3store.NewS3Store(testBucket, "us-east-1",
+		s3store.WithS3API(bucketOutage{mem, testBucket, &outage}),
+		s3store.WithFailover("secondary", ""))
+	load := func() string {
+		t.Helper()
+		v, err := s.Load(ctx, "k")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(v)
+	}
+
+	for _, err := range []error{
+		&smithyhttp.RequestSendError{Err: errors.New("connection refused")},
+		responseError(http.StatusServiceUnavailable, "ServiceUnavailable"),
+	} {
+		outage = err
+		if v := load(); v != "secondary" {
+			t.Fatalf("Load after %v = %q, want the secondary's value", err, v)
+		}
+		// The primary isn't tried again until the cooldown has passed,
+		// after which reads fail back to it.
+		outage = nil
+		if v := load(); v != "secondary" {
+			t.Fatalf("Load during cooldown = %q, want the secondary's value", v)
+		}
+		time.Sleep(60 * time.Millisecond)
+		if v := load(); v != "primary" {
+			t.Fatalf("Load after cooldown = %q, want the primary's value", v)
+		}
+		if v := load(); v != "primary" {
+			t.Fatalf("Load after failing back = %q, want the primary's value", v)
+		}
+	}
+
+	// Definitive answers are returned rather than failed over.
+	for _, err := range []error{
+		responseError(http.StatusNotFound, "NoSuchKey"),
+		responseError(http.StatusForbidden, "AccessDenied"),
+		errors.New("decoding failed"),
+	} {
+		outage = err
+		if v, got := s.Load(ctx, "k"); got == nil {
+			t.Fatalf("Load with %v = %q, want the primary's error", err, v)
+		}
+	}
+}
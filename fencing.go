@@ -0,0 +1,159 @@
+package s3store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// CheckFencingToken returns ErrLockStale if the lock for key has been
+// acquired again since token was issued by LockWithToken.
+func (s *S3Store) CheckFencingToken(ctx context.Context, key string, token uint64) error {
+	current, _, err := s.readFencingToken(ctx, s.fenceFileName(key))
+	if err != nil {
+		return s.opError("check fencing token", key, err)
+	}
+	if current > token {
+		return fmt.Errorf("fencing token %d for %s superseded by %d: %w",
+			token, s.logKey(key), current, ErrLockStale)
+	}
+	return nil
+}
+
+// fenceLock issues the next fencing token for the lock on key, which
+// has just been acquired, and records it in the lock file. The lock is
+// released again if that fails.
+func (s *S3Store) fenceLock(ctx context.Context, key, lockFile string) (uint64, error) {
+	token, err := s.nextFencingToken(ctx, key)
+	if err == nil {
+		err = s.writeLockFile(ctx, lockFile, s.newLockInfo(token), "")
+	}
+	if err != nil {
+		s.deleteLockFile(lockFile)
+		return 0, s.opError("lock", key, fmt.Errorf("issuing fencing token: %w", err))
+	}
+	return token, nil
+}
+
+// nextFencingToken increments the lock's fencing token, using a
+// conditional write so that concurrent increments can't return the
+// same token.
+func (s *S3Store) nextFencingToken(ctx context.Context, key string) (uint64, error) {
+	name := s.fenceFileName(key)
+	for {
+		current, etag, err := s.readFencingToken(ctx, name)
+		if err != nil {
+			return 0, err
+		}
+		input := &s3.PutObjectInput{
+			Bucket: s.bucket,
+			Key:    aws.String(name),
+			Body:   strings.NewReader(strconv.FormatUint(current+1, 10)),
+			ACL:    s.acl,
+		}
+		if etag == "" {
+			input.IfNoneMatch = aws.String("*")
+		} else {
+			input.IfMatch = aws.String(etag)
+		}
+		_, err = s.putObject(ctx, input)
+		switch {
+		case err == nil:
+			return current + 1, nil
+		case isPreconditionFailed(err):
+			// raced with another holder; read the new token and retry
+		default:
+			return 0, err
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// readFencingToken returns the last token issued for a lock and the
+// ETag of the object holding it, or zero and no ETag if none has been.
+func (s *S3Store) readFencingToken(ctx context.Context, name string) (uint64, string, error) {
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: s.bucket,
+		Key:    aws.String(name),
+	})
+	if s.errNoSuchKey(err) {
+		return 0, "", nil
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	defer result.Body.Close()
+	b, err := io.ReadAll(result.Body)
+	if err != nil {
+		return 0, "", err
+	}
+	token, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("parsing fencing token: %w", err)
+	}
+	return token, aws.ToString(result.ETag), nil
+}
+
+// checkLockToken returns ErrLockStale unless lockFile still
+// records token, i.e. the lock hasn't been taken over.
+func (s *S3Store) checkLockToken(ctx context.Context, lockFile string, token uint64) error {
+	rec, err := s.readLockRecord(ctx, lockFile)
+	if s.errNoSuchKey(err) || err == nil && rec.Token != token {
+		return ErrLockStale
+	}
+	return err
+}
+
+// renewLock extends the expiry of lockFile, which must still record
+// token.
+func (s *S3Store) renewLock(ctx context.Context, lockFile string, token uint64) error {
+	rec, err := s.readLockRecord(ctx, lockFile)
+	if s.errNoSuchKey(err) || err == nil && rec.Token != token {
+		err = ErrLockStale
+	}
+	if err == nil {
+		info := rec.LockInfo
+		info.ExpiresAt = time.Now().UTC().Add(staleLockDuration)
+		err = s.writeLockFile(ctx, lockFile, info, rec.etag)
+		if isPreconditionFailed(err) {
+			err = ErrLockStale
+		}
+	}
+	switch {
+	case err == nil:
+		s.lockState.renewed(lockFile)
+	case errors.Is(err, ErrLockStale):
+		s.lockState.released(lockFile)
+	}
+	return err
+}
+
+// fenceFileName returns the object name holding the fencing token for
+// the lock on key. It outlives the lock file so tokens keep increasing.
+func (s *S3Store) fenceFileName(key string) string {
+	return path.Join(s.lockDir(), StorageKeys.Safe(key)+".fence")
+}
+
+// isPreconditionFailed reports whether err is a 412 Precondition Failed
+// response to a conditional request, or the 409 Conflict S3 returns
+// when a concurrent conditional write wins the race.
+func isPreconditionFailed(err error) bool {
+	var re *awshttp.ResponseError
+	if !errors.As(err, &re) {
+		return false
+	}
+	return re.HTTPStatusCode() == http.StatusPreconditionFailed ||
+		re.HTTPStatusCode() == http.StatusConflict
+}
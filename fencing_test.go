@@ -0,0 +1,103 @@
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+func TestFencingTokens(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	a := newTestStoreOn(t, mem)
+	b := newTestStoreOn(t, mem)
+
+	t1, err := a.LockWithToken(ctx, "site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.CheckFencingToken(ctx, "site", t1); err != nil {
+		t.Fatalf("current token rejected: %v", err)
+	}
+	if err := a.Unlock(ctx, "site"); err != nil {
+		t.Fatal(err)
+	}
+	t2, err := b.LockWithToken(ctx, "site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if t2 <= t1 {
+		t.Fatalf("token %d issued after %d", t2, t1)
+	}
+	if err := a.CheckFencingToken(ctx, "site", t1); !errors.Is(err, s3store.ErrLockStale) {
+		t.Fatalf("superseded token: %v, want ErrLockStale", err)
+	}
+	if err := b.Unlock(ctx, "site"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tokens are per lock.
+	other, err := a.LockWithToken(ctx, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other >= t2 {
+		t.Fatalf("first token of another lock = %d, want less than %d", other, t2)
+	}
+}
+
+func TestRenewLocks(t *testing.T) {
+	s3store.SetStaleLockDuration(t, 200*time.Millisecond)
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	a := newTestStoreOn(t, mem)
+	b := newTestStoreOn(t, mem)
+	abandoning := newTestStoreOn(t, mem)
+	if err := a.Lock(ctx, "renewed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := abandoning.Lock(ctx, "abandoned"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Keep renewing well past the stale lock duration.
+	for range 10 {
+		time.Sleep(50 * time.Millisecond)
+		if err := a.RenewLocks(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tryLock := func(key string) error {
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		return b.Lock(ctx, key)
+	}
+	if err := tryLock("renewed"); err == nil {
+		t.Fatal("renewed lock was taken over")
+	}
+	if err := tryLock("abandoned"); err != nil {
+		t.Fatalf("Lock of abandoned lock: %v", err)
+	}
+	if err := abandoning.RenewLocks(ctx); !errors.Is(err, s3store.ErrLockStale) {
+		t.Fatalf("RenewLocks of taken over lock = %v, want ErrLockStale", err)
+	}
+	if err := b.Unlock(ctx, "abandoned"); err != nil {
+		t.Fatalf("Unlock by the new holder: %v", err)
+	}
+
+	// Released locks are no longer renewed.
+	if err := a.Unlock(ctx, "renewed"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if err := a.RenewLocks(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if a.Exists(ctx, "locks/renewed.lock") {
+		t.Fatal("RenewLocks recreated a released lock")
+	}
+}
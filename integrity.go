@@ -0,0 +1,145 @@
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	cm "github.com/caddyserver/certmagic"
+)
+
+// IntegrityFinding is a corrupt or inconsistent object found by Audit.
+type IntegrityFinding struct {
+	Key string
+	// Problem is "unreadable" if the object couldn't be loaded,
+	// "truncated" if it is empty or ends in the middle of a PEM block,
+	// "unparseable" if it isn't a valid certificate, private key or JSON
+	// document, "mismatched" if a private key doesn't belong to its
+	// certificate and "missing" if a certificate lacks its private key
+	// or the other way around.
+	Problem string
+	// Detail describes the problem.
+	Detail string
+}
+
+func (f IntegrityFinding) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.Key, f.Problem, f.Detail)
+}
+
+// Audit walks the certificates certmagic stored under certificates/,
+// parses every certificate, private key and metadata document and
+// checks that each private key belongs to its certificate, so that
+// silent corruption is caught before it breaks TLS. It returns one
+// finding per problem; an error is only returned if the certificates
+// could not be listed. The objects are loaded concurrently like with
+// LoadMany.
+func (s *S3Store) Audit(ctx context.Context) ([]IntegrityFinding, error) {
+	var keys []string
+	err := s.eachObject(ctx, certificatesDir, func(obj types.Object) error {
+		keys = append(keys, s.keyName(aws.ToString(obj.Key)))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := s.LoadMany(ctx, keys)
+
+	// Group each certificate with its private key by
+	// the key they share without their extension.
+	certs := make(map[string]*x509.Certificate)
+	privs := make(map[string]crypto.Signer)
+	var findings []IntegrityFinding
+	report := func(key, problem string, err error) {
+		findings = append(findings, IntegrityFinding{key, problem, err.Error()})
+	}
+	for _, key := range slices.Sorted(maps.Keys(results)) {
+		value, err := results[key].Value, results[key].Err
+		if err != nil {
+			report(key, "unreadable", err)
+			continue
+		}
+		base, ext := strings.TrimSuffix(key, path.Ext(key)), path.Ext(key)
+		switch ext {
+		case ".crt", ".key":
+			if err := checkPEM(value); err != nil {
+				report(key, "truncated", err)
+				continue
+			}
+		case ".json":
+			if len(value) == 0 {
+				report(key, "truncated", errors.New("empty object"))
+			} else if !json.Valid(value) {
+				report(key, "unparseable", errors.New("invalid JSON"))
+			}
+			continue
+		default:
+			continue
+		}
+		if ext == ".crt" {
+			leaf, err := parseLeaf(value)
+			if err != nil {
+				report(key, "unparseable", err)
+				continue
+			}
+			certs[base] = leaf
+		} else {
+			priv, err := cm.PEMDecodePrivateKey(value)
+			if err != nil {
+				report(key, "unparseable", err)
+				continue
+			}
+			privs[base] = priv
+		}
+	}
+
+	for _, base := range slices.Sorted(maps.Keys(certs)) {
+		priv, ok := privs[base]
+		if !ok {
+			// A stored but broken key was reported above.
+			if _, stored := results[base+".key"]; !stored {
+				report(base+".crt", "missing", errors.New("no private key stored"))
+			}
+			continue
+		}
+		pub, ok := priv.Public().(interface{ Equal(crypto.PublicKey) bool })
+		if !ok || !pub.Equal(certs[base].PublicKey) {
+			report(base+".key", "mismatched", errors.New("private key doesn't match the certificate's public key"))
+		}
+	}
+	for _, base := range slices.Sorted(maps.Keys(privs)) {
+		if _, stored := results[base+".crt"]; !stored {
+			report(base+".key", "missing", errors.New("no certificate stored"))
+		}
+	}
+	return findings, nil
+}
+
+// checkPEM reports an error if data is empty or
+// ends in the middle of a PEM block.
+func checkPEM(data []byte) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return errors.New("empty object")
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+	}
+	if bytes.Contains(data, []byte("-----BEGIN")) {
+		return errors.New("PEM block not terminated")
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package s3store_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+func TestAudit(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	s := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(failingGet{mem, "broken.com"}))
+	site := func(name string) string { return "certificates/acme/" + name + "/" + name }
+	store := func(key string, value []byte) {
+		t.Helper()
+		if err := s.Store(ctx, key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	crt, key := newCert(t, "good.com", time.Hour)
+	store(site("good.com")+".crt", crt)
+	store(site("good.com")+".key", key)
+	store(site("good.com")+".json", []byte(`{"sans":["good.com"]}`))
+	other, _ := newCert(t, "mismatched.com", time.Hour)
+	store(site("mismatched.com")+".crt", other)
+	store(site("mismatched.com")+".key", key)
+	store(site("truncated.com")+".crt", crt[:len(crt)/2])
+	store(site("nokey.com")+".crt", crt)
+	store(site("badjson.com")+".json", []byte("{"))
+	store(site("broken.com")+".crt", crt)
+
+	findings, err := s.Audit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type finding struct{ key, problem string }
+	var got []finding
+	for _, f := range findings {
+		got = append(got, finding{f.Key, f.Problem})
+	}
+	want := []finding{
+		{site("badjson.com") + ".json", "unparseable"},
+		{site("broken.com") + ".crt", "unreadable"},
+		{site("truncated.com") + ".crt", "truncated"},
+		{site("mismatched.com") + ".key", "mismatched"},
+		{site("nokey.com") + ".crt", "missing"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Audit = %v, want %v", findings, want)
+	}
+}
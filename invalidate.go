@@ -0,0 +1,120 @@
+package s3store
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// invalidationRetryDelay is how long ListenForInvalidations waits
+// before polling again after a failed receive.
+const invalidationRetryDelay = 5 * time.Second
+
+// SQSReceiveAPI is the subset of the SQS client's methods used by
+// ListenForInvalidations.
+type SQSReceiveAPI interface {
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+}
+
+// ListenForInvalidations keeps the cache coherent with writes made by
+// other nodes sharing the bucket, by removing the keys named in the
+// messages of the SQS queue at queueURL from the cache. It understands
+// the change events sent by SQSPublisher, also when delivered through
+// an SNS topic, and S3 event notifications. Each node needs its own
+// queue, e.g. subscribed to a topic the other nodes publish to, as every
+// message is consumed by one receiver only. Note that publishers only
+// send events for keys under certificates/.
+//
+// ListenForInvalidations blocks until ctx is done, so it is usually run
+// in its own goroutine. Receive errors are logged and retried.
+func (s *S3Store) ListenForInvalidations(ctx context.Context, client SQSReceiveAPI, queueURL string) error {
+	for ctx.Err() == nil {
+		out, err := client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(queueURL),
+			MaxNumberOfMessages: 10,
+			WaitTimeSeconds:     20,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			log.Printf("[ERROR][%s] Receiving cache invalidations: %v", s, err)
+			select {
+			case <-time.After(invalidationRetryDelay):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		for _, msg := range out.Messages {
+			for _, key := range s.invalidatedKeys(aws.ToString(msg.Body)) {
+				if s.cache != nil {
+					s.cache.remove(key)
+				}
+			}
+			_, err := client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+				QueueUrl:      aws.String(queueURL),
+				ReceiptHandle: msg.ReceiptHandle,
+			})
+			if err != nil && ctx.Err() == nil {
+				log.Printf("[ERROR][%s] Deleting cache invalidation message: %v", s, err)
+			}
+		}
+	}
+	return nil
+}
+
+// invalidationMessage holds the fields of the message formats
+// ListenForInvalidations understands.
+type invalidationMessage struct {
+	ChangeEvent
+
+	// An SNS notification wrapping another message.
+	Type    string `json:"Type"`
+	Message string `json:"Message"`
+
+	// An S3 event notification.
+	Records []struct {
+		S3 struct {
+			Bucket struct {
+				Name string `json:"name"`
+			} `json:"bucket"`
+			Object struct {
+				Key string `json:"key"`
+			} `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+}
+
+// invalidatedKeys returns the keys of s a message body reports
+// as changed.
+func (s *S3Store) invalidatedKeys(body string) []string {
+	var m invalidationMessage
+	if err := json.Unmarshal([]byte(body), &m); err != nil {
+		log.Printf("[WARNING][%s] Ignoring malformed cache invalidation message: %v", s, err)
+		return nil
+	}
+	if m.Type == "Notification" {
+		return s.invalidatedKeys(m.Message)
+	}
+	bucket := aws.ToString(s.bucket)
+	var keys []string
+	if m.Key != "" && m.Bucket == bucket && m.Prefix == s.prefix {
+		keys = append(keys, m.Key)
+	}
+	for _, r := range m.Records {
+		// Object keys in S3 event notifications are URL-encoded.
+		name, err := url.QueryUnescape(r.S3.Object.Key)
+		if err != nil || r.S3.Bucket.Name != bucket || !strings.HasPrefix(name, s.prefix+"/") {
+			continue
+		}
+		keys = append(keys, s.keyName(name))
+	}
+	return keys
+}
@@ -0,0 +1,106 @@
+package s3store_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+// queue is an SQS queue that delivers the messages in bodies once and
+// closes deleted after all of them have been deleted.
+type queue struct {
+	bodies  []string
+	deleted chan struct{}
+	pending int
+}
+
+func (q *queue) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	if len(q.bodies) == 0 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	out := &sqs.ReceiveMessageOutput{}
+	for i, body := range q.bodies {
+		out.Messages = append(out.Messages, sqstypes.Message{
+			Body:          aws.String(body),
+			ReceiptHandle: aws.String(fmt.Sprint(i)),
+		})
+	}
+	q.pending, q.bodies = len(q.bodies), nil
+	return out, nil
+}
+
+func (q *queue) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	if q.pending--; q.pending == 0 {
+		close(q.deleted)
+	}
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func TestListenForInvalidations(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	s := newTestStoreOn(t, mem, s3store.WithCache(10))
+	other := newTestStoreOn(t, mem)
+	keys := []string{"certificates/a", "certificates/b", "certificates/c", "certificates/d"}
+	mustStore(t, s, keys...)
+	for _, key := range keys {
+		if _, err := s.Load(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		if err := other.Store(ctx, key, []byte("changed")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	event := func(key, bucket string) string {
+		b, _ := json.Marshal(s3store.ChangeEvent{Op: "store", Key: key, Bucket: bucket, Prefix: s.Prefix()})
+		return string(b)
+	}
+	sns, _ := json.Marshal(map[string]string{"Type": "Notification", "Message": event("certificates/b", testBucket)})
+	s3Event := fmt.Sprintf(`{"Records":[{"s3":{"bucket":{"name":%q},"object":{"key":%q}}}]}`,
+		testBucket, url.QueryEscape(s.Filename(ctx, "certificates/c")))
+	q := &queue{
+		bodies: []string{
+			event("certificates/a", testBucket),
+			string(sns),
+			s3Event,
+			event("certificates/d", "other-bucket"),
+			"not JSON",
+		},
+		deleted: make(chan struct{}),
+	}
+
+	lctx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- s.ListenForInvalidations(lctx, q, "https://sqs.example.test/queue") }()
+	select {
+	case <-q.deleted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("messages weren't deleted")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("ListenForInvalidations = %v", err)
+	}
+
+	for key, want := range map[string]string{
+		"certificates/a": "changed",
+		"certificates/b": "changed",
+		"certificates/c": "changed",
+		"certificates/d": "certificates/d",
+	} {
+		if v, err := s.Load(ctx, key); err != nil || string(v) != want {
+			t.Errorf("Load(%s) = %q, %v, want %q", key, v, err, want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package s3store
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// WithInventory configures an S3 Inventory report of the store's bucket
+// as the data source for ListInventory, so full scans of very large
+// deployments read a handful of report files instead of issuing
+// millions of LIST requests. bucket is the report's destination bucket
+// and prefix its location within it, i.e.
+// "<destination prefix>/<source bucket>/<configuration ID>". Only CSV
+// reports are supported, and they must include the Size and
+// LastModifiedDate fields to be used beyond plain key listings.
+func WithInventory(bucket, prefix string) Option {
+	return func(s *S3Store) {
+		s.inventoryBucket = bucket
+		s.inventoryPrefix = strings.Trim(prefix, "/")
+	}
+}
+
+// inventoryManifest is the manifest.json describing one S3 Inventory
+// report.
+type inventoryManifest struct {
+	SourceBucket string `json:"sourceBucket"`
+	FileFormat   string `json:"fileFormat"`
+	FileSchema   string `json:"fileSchema"`
+	Files        []struct {
+		Key string `json:"key"`
+	} `json:"files"`
+}
+
+// ListInventory returns the keys under prefix, recursively, as recorded
+// by the latest inventory report configured with WithInventory. Reports
+// are produced daily or weekly, so keys written or deleted since are not
+// reflected.
+func (s *S3Store) ListInventory(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	prefix = strings.Trim(prefix, "/")
+	err := s.eachInventoryObject(ctx, func(obj types.Object) error {
+		key := s.keyName(aws.ToString(obj.Key))
+		if prefix == "" || strings.HasPrefix(key, prefix+"/") {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	return keys, s.opError("list inventory", prefix, err)
+}
+
+// eachInventoryObject calls fn for every object under the store's
+// prefix listed by the latest inventory report.
+func (s *S3Store) eachInventoryObject(ctx context.Context, fn func(obj types.Object) error) error {
+	if s.inventoryBucket == "" {
+		return errors.New("no inventory configured")
+	}
+	m, err := s.latestInventoryManifest(ctx)
+	if err != nil {
+		return err
+	}
+	if m.FileFormat != "CSV" {
+		return fmt.Errorf("unsupported inventory format %q", m.FileFormat)
+	}
+	if m.SourceBucket != aws.ToString(s.bucket) {
+		return fmt.Errorf("inventory is of bucket %q", m.SourceBucket)
+	}
+	fields := strings.Split(m.FileSchema, ",")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	for _, f := range m.Files {
+		if err := s.readInventoryFile(ctx, f.Key, fields, fn); err != nil {
+			return fmt.Errorf("reading inventory file %s: %w", f.Key, err)
+		}
+	}
+	return nil
+}
+
+// latestInventoryManifest reads the manifest of the newest report. Each
+// report lives in a directory named after its timestamp, which sorts
+// chronologically and, starting with a digit, before the report's data
+// and hive directories.
+func (s *S3Store) latestInventoryManifest(ctx context.Context) (*inventoryManifest, error) {
+	var latest string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.inventoryBucket),
+		Prefix:    aws.String(s.inventoryPrefix + "/"),
+		Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing inventory reports: %w", err)
+		}
+		for _, p := range page.CommonPrefixes {
+			dir := aws.ToString(p.Prefix)
+			base := path.Base(dir)
+			if base != "" && base[0] >= '0' && base[0] <= '9' && dir > latest {
+				latest = dir
+			}
+		}
+	}
+	if latest == "" {
+		return nil, errors.New("no inventory report found")
+	}
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.inventoryBucket),
+		Key:    aws.String(latest + "manifest.json"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading inventory manifest: %w", err)
+	}
+	defer result.Body.Close()
+	var m inventoryManifest
+	if err := json.NewDecoder(result.Body).Decode(&m); err != nil {
+		return nil, fmt.Errorf("parsing inventory manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// readInventoryFile calls fn for every object under the store's prefix
+// in the gzipped CSV inventory file name, whose columns are fields.
+func (s *S3Store) readInventoryFile(ctx context.Context, name string, fields []string, fn func(obj types.Object) error) error {
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.inventoryBucket),
+		Key:    aws.String(name),
+	})
+	if err != nil {
+		return err
+	}
+	defer result.Body.Close()
+	zr, err := gzip.NewReader(result.Body)
+	if err != nil {
+		return err
+	}
+	r := csv.NewReader(zr)
+	r.FieldsPerRecord = len(fields)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var obj types.Object
+		for i, field := range fields {
+			switch v := record[i]; field {
+			case "Key":
+				// Inventory reports URL-encode object keys.
+				name, err := url.QueryUnescape(v)
+				if err != nil {
+					return err
+				}
+				obj.Key = aws.String(name)
+			case "Size":
+				if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+					obj.Size = aws.Int64(n)
+				}
+			case "LastModifiedDate":
+				if t, err := time.Parse(time.RFC3339, v); err == nil {
+					obj.LastModified = aws.Time(t)
+				}
+			case "ETag":
+				obj.ETag = aws.String(v)
+			}
+		}
+		if strings.HasPrefix(aws.ToString(obj.Key), s.prefix+"/") {
+			if err := fn(obj); err != nil {
+				return err
+			}
+		}
+	}
+}
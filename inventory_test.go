@@ -0,0 +1,65 @@
+package s3store_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+func TestListInventory(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket, "inventory")
+	put := func(key, value string) {
+		t.Helper()
+		_, err := mem.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String("inventory"),
+			Key:    aws.String(key),
+			Body:   bytes.NewReader([]byte(value)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	gzipped := func(csv string) string {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(csv))
+		zw.Close()
+		return buf.String()
+	}
+	const report = "reports/" + testBucket + "/all/"
+	put(report+"data/old.csv.gz", gzipped(`"test-bucket","certmagic/certificates/gone.crt","1","2026-09-01T00:00:00.000Z"`+"\n"))
+	put(report+"data/new.csv.gz", gzipped(
+		`"test-bucket","certmagic/certificates/a%20b/a%20b.crt","12","2026-10-01T00:00:00.000Z"`+"\n"+
+			`"test-bucket","certmagic/ocsp/a","3","2026-10-01T00:00:00.000Z"`+"\n"+
+			`"test-bucket","other/x","1","2026-10-01T00:00:00.000Z"`+"\n"))
+	put(report+"2026-09-01T01-00Z/manifest.json",
+		`{"sourceBucket":"test-bucket","fileFormat":"CSV","fileSchema":"Bucket, Key, Size, LastModifiedDate","files":[{"key":"`+report+`data/old.csv.gz"}]}`)
+	put(report+"2026-10-01T01-00Z/manifest.json",
+		`{"sourceBucket":"test-bucket","fileFormat":"CSV","fileSchema":"Bucket, Key, Size, LastModifiedDate","files":[{"key":"`+report+`data/new.csv.gz"}]}`)
+
+	s := newTestStoreOn(t, mem, s3store.WithInventory("inventory", report))
+	keys, err := s.ListInventory(ctx, "")
+	if want := []string{"certificates/a b/a b.crt", "ocsp/a"}; err != nil || !reflect.DeepEqual(keys, want) {
+		t.Fatalf("ListInventory = %q, %v, want %q", keys, err, want)
+	}
+	keys, err = s.ListInventory(ctx, "certificates")
+	if want := []string{"certificates/a b/a b.crt"}; err != nil || !reflect.DeepEqual(keys, want) {
+		t.Fatalf("ListInventory of certificates = %q, %v, want %q", keys, err, want)
+	}
+
+	if _, err := newTestStoreOn(t, mem).ListInventory(ctx, ""); err == nil {
+		t.Fatal("ListInventory without an inventory succeeded")
+	}
+	other := s3store.NewS3Store("other", "us-east-1", s3store.WithS3API(mem), s3store.WithInventory("inventory", report))
+	if _, err := other.ListInventory(ctx, ""); err == nil {
+		t.Fatal("ListInventory of another bucket's report succeeded")
+	}
+}
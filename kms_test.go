@@ -0,0 +1,37 @@
+package s3store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+func TestTenantKMSKeys(t *testing.T) {
+	ctx := context.Background()
+	api := &recordingInputs{Client: memstore.New(testBucket), puts: make(map[string]*s3.PutObjectInput)}
+	s := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(api),
+		s3store.WithKMSKey("default-key"), s3store.WithTenantKMSKey("a", "a-key"))
+	mustStore(t, s, "k")
+	for _, id := range []string{"a", "b"} {
+		if err := s.ForTenant(id).Store(ctx, "k", []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[string]string{
+		"k":           "default-key",
+		"tenants/a/k": "a-key",
+		"tenants/b/k": "default-key",
+	}
+	for key, want := range tests {
+		in := api.puts[s.Filename(ctx, key)]
+		if in.ServerSideEncryption != types.ServerSideEncryptionAwsKms || aws.ToString(in.SSEKMSKeyId) != want {
+			t.Errorf("%s encrypted with %q under %q, want %q", key, in.ServerSideEncryption, aws.ToString(in.SSEKMSKeyId), want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package s3store
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	cm "github.com/caddyserver/certmagic"
+)
+
+// WithLegacyKeys makes Load, Exists and Stat fall back to the
+// backslash-separated object names that earlier versions wrote when
+// running on Windows, makes listings include such names mapped back to
+// keys and makes Delete remove them too, so that data stored by them
+// stays readable and can be migrated. Lock waits for locks held by
+// earlier versions under their legacy names.
+func WithLegacyKeys() Option {
+	return func(s *S3Store) {
+		s.legacyKeys = true
+	}
+}
+
+// legacyName returns name as earlier versions wrote it on Windows.
+func legacyName(name string) string {
+	return strings.ReplaceAll(name, "/", `\`)
+}
+
+// legacyTokenPrefix marks ListPage continuation tokens
+// for the legacy namespace.
+const legacyTokenPrefix = "legacy:"
+
+// objectNames returns name and, with legacy keys enabled and if it
+// differs, its legacy name, for operations covering objects stored
+// under either.
+func (s *S3Store) objectNames(name string) []string {
+	if legacy := legacyName(name); s.legacyKeys && legacy != name {
+		return []string{name, legacy}
+	}
+	return []string{name}
+}
+
+// walkNames is like walkNamed, but also walks the legacy
+// namespace of namePrefix with legacy keys enabled.
+func (s *S3Store) walkNames(ctx context.Context, namePrefix string, fn func(obj types.Object) error) error {
+	for _, p := range s.objectNames(namePrefix) {
+		if err := s.walkNamed(ctx, p, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statObject is like statName, but falls back to
+// the legacy name if the object doesn't exist.
+func (s *S3Store) statObject(ctx context.Context, name string) (cm.KeyInfo, error) {
+	info, err := s.statName(ctx, name)
+	if !s.legacyKeys || !s.errNoSuchKey(err) {
+		return info, err
+	}
+	if info, lerr := s.statName(ctx, legacyName(name)); lerr == nil {
+		return info, nil
+	}
+	return info, err
+}
+
+// legacyLockHeld reports whether an earlier version holds
+// a lock that isn't stale under the legacy name of lockFile.
+func (s *S3Store) legacyLockHeld(ctx context.Context, lockFile string) bool {
+	if !s.legacyKeys {
+		return false
+	}
+	rec, err := s.readLockRecord(ctx, legacyName(lockFile))
+	return err == nil && !rec.stale()
+}
+
+// getObject fetches the object described by input, verifying its
+// checksum if enabled and falling back to its legacy name if it
+// doesn't exist.
+func (s *S3Store) getObject(ctx context.Context, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	get := func(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+		if s.checksums {
+			return s.getObjectWithChecksum(ctx, input)
+		}
+		return s.client.GetObject(ctx, input)
+	}
+	result, err := get(input)
+	s.restoreArchived(ctx, aws.ToString(input.Key), err)
+	if !s.legacyKeys || !s.errNoSuchKey(err) {
+		return result, err
+	}
+	legacy := *input
+	legacy.Key = aws.String(legacyName(aws.ToString(input.Key)))
+	if result, lerr := get(&legacy); lerr == nil {
+		return result, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,101 @@
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+// putLegacy stores value under the backslash-separated
+// object name earlier versions wrote on Windows.
+func putLegacy(t *testing.T, api s3store.S3API, name, value string) {
+	t.Helper()
+	_, err := api.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket: aws.String(testBucket),
+		Key:    aws.String(strings.ReplaceAll(name, "/", `\`)),
+		Body:   strings.NewReader(value),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLegacyKeys(t *testing.T) {
+	ctx := context.Background()
+	s, mem := newTestStore(t, s3store.WithLegacyKeys())
+	putLegacy(t, mem, "certmagic/certificates/acme/a.com/a.com.crt", "legacy")
+	mustStore(t, s, "certificates/acme/b.com/b.com.crt")
+
+	if v, err := s.Load(ctx, "certificates/acme/a.com/a.com.crt"); err != nil || string(v) != "legacy" {
+		t.Fatalf("Load = %q, %v", v, err)
+	}
+	if info, err := s.Stat(ctx, "certificates/acme/a.com/a.com.crt"); err != nil || info.Size != int64(len("legacy")) {
+		t.Fatalf("Stat = %+v, %v", info, err)
+	}
+
+	want := []string{"certificates/acme/a.com/a.com.crt", "certificates/acme/b.com/b.com.crt"}
+	keys, err := s.List(ctx, "certificates", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(keys)
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("List = %q, want %q", keys, want)
+	}
+	keys = nil
+	for key, err := range s.Iter(ctx, "certificates") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("Iter = %q, want %q", keys, want)
+	}
+	keys = nil
+	for token := ""; ; {
+		var page []string
+		page, token, err = s.ListPage(ctx, "certificates", token, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, page...)
+		if token == "" {
+			break
+		}
+	}
+	slices.Sort(keys)
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("ListPage = %q, want %q", keys, want)
+	}
+
+	if err := s.Delete(ctx, "certificates/acme/a.com/a.com.crt"); err != nil {
+		t.Fatal(err)
+	}
+	if s.Exists(ctx, "certificates/acme/a.com/a.com.crt") {
+		t.Fatal("legacy object wasn't deleted")
+	}
+}
+
+func TestLegacyLockHeld(t *testing.T) {
+	s, mem := newTestStore(t, s3store.WithLegacyKeys())
+	putLegacy(t, mem, "certmagic/locks/issue_a.com.lock", "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Lock(ctx, "issue_a.com"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Lock of legacy lock = %v, want context.DeadlineExceeded", err)
+	}
+	if locks, _ := s.Locks(context.Background()); len(locks) != 0 {
+		t.Fatalf("lock file left behind: %v", locks)
+	}
+}
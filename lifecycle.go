@@ -0,0 +1,132 @@
+package s3store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// lifecycleRulePrefix marks the lifecycle rules managed by
+// EnsureLifecycleRules so that other rules on the bucket are kept.
+const lifecycleRulePrefix = "s3store-"
+
+const (
+	// defaultTrashExpiration is used when soft delete has no retention.
+	defaultTrashExpiration = 30 * 24 * time.Hour
+
+	// multipartAbortDays is how long incomplete multipart
+	// uploads are kept before S3 aborts them.
+	multipartAbortDays = 7
+
+	// ocspTransitionDays is the age after which OCSP staples
+	// are moved to infrequent access storage.
+	ocspTransitionDays = 30
+)
+
+// EnsureLifecycleRules installs the recommended lifecycle rules for the
+// store's prefix on the bucket: trashed objects expire after the soft
+// delete retention, incomplete multipart uploads are aborted, and old
+// OCSP staples are transitioned to infrequent access storage. Rules
+// previously installed by EnsureLifecycleRules for the same prefix are
+// replaced; any other rules on the bucket, including those of other
+// stores, are left as they are.
+func (s *S3Store) EnsureLifecycleRules(ctx context.Context) error {
+	managed := s.lifecycleRules()
+	ours := make(map[string]bool, len(managed))
+	for _, rule := range managed {
+		ours[aws.ToString(rule.ID)] = true
+	}
+
+	var rules []types.LifecycleRule
+	out, err := s.client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: s.bucket,
+	})
+	var ae smithy.APIError
+	switch {
+	case err == nil:
+		// Match whole IDs: the rules of certmagic-staging
+		// share the ID prefix of those of certmagic.
+		for _, rule := range out.Rules {
+			if !ours[aws.ToString(rule.ID)] {
+				rules = append(rules, rule)
+			}
+		}
+	case errors.As(err, &ae) && ae.ErrorCode() == "NoSuchLifecycleConfiguration":
+	default:
+		return fmt.Errorf("getting bucket lifecycle configuration: %w", err)
+	}
+
+	rules = append(rules, managed...)
+	_, err = s.client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 s.bucket,
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{Rules: rules},
+	})
+	if err != nil {
+		return fmt.Errorf("putting bucket lifecycle configuration: %w", err)
+	}
+	return nil
+}
+
+// lifecycleRules returns the rules managed by EnsureLifecycleRules.
+func (s *S3Store) lifecycleRules() []types.LifecycleRule {
+	retention := s.trashRetention
+	if retention <= 0 {
+		retention = defaultTrashExpiration
+	}
+	trashDays := int32(math.Ceil(retention.Hours() / 24))
+
+	return []types.LifecycleRule{
+		{
+			ID:         aws.String(s.lifecycleRuleID("expire-trash")),
+			Status:     types.ExpirationStatusEnabled,
+			Filter:     &types.LifecycleRuleFilter{Prefix: aws.String(s.lifecyclePrefix(trashDir))},
+			Expiration: &types.LifecycleExpiration{Days: aws.Int32(trashDays)},
+		},
+		{
+			ID:     aws.String(s.lifecycleRuleID("abort-multipart")),
+			Status: types.ExpirationStatusEnabled,
+			Filter: &types.LifecycleRuleFilter{Prefix: aws.String(s.lifecyclePrefix(""))},
+			AbortIncompleteMultipartUpload: &types.AbortIncompleteMultipartUpload{
+				DaysAfterInitiation: aws.Int32(multipartAbortDays),
+			},
+		},
+		{
+			ID:     aws.String(s.lifecycleRuleID("transition-ocsp")),
+			Status: types.ExpirationStatusEnabled,
+			Filter: &types.LifecycleRuleFilter{Prefix: aws.String(s.lifecyclePrefix(ocspDir))},
+			Transitions: []types.Transition{{
+				Days:         aws.Int32(ocspTransitionDays),
+				StorageClass: types.TransitionStorageClassStandardIa,
+			}},
+		},
+	}
+}
+
+// lifecycleRuleID names a managed rule, scoped to the store's prefix
+// so that stores sharing a bucket don't replace each other's rules.
+func (s *S3Store) lifecycleRuleID(name string) string {
+	id := lifecycleRulePrefix
+	if s.prefix != "" {
+		id += strings.ReplaceAll(s.prefix, "/", "-") + "-"
+	}
+	return id + name
+}
+
+// lifecyclePrefix returns the object name prefix for dir, with a
+// trailing slash so that rules don't match sibling directories.
+func (s *S3Store) lifecyclePrefix(dir string) string {
+	p := path.Join(s.prefix, dir)
+	if p == "" || p == "." {
+		return ""
+	}
+	return p + "/"
+}
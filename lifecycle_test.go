@@ -0,0 +1,91 @@
+package s3store_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+func TestEnsureLifecycleRules(t *testing.T) {
+	ctx := context.Background()
+	s, mem := newTestStore(t, s3store.WithPrefix("caddy"), s3store.WithSoftDelete(36*time.Hour))
+	_, err := mem.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket: aws.String(testBucket),
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{Rules: []types.LifecycleRule{{
+			ID:     aws.String("logs"),
+			Status: types.ExpirationStatusEnabled,
+		}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := newTestStoreOn(t, mem, s3store.WithPrefix("other"))
+	if err := other.EnsureLifecycleRules(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Installing the rules again replaces them instead of adding more.
+	for range 2 {
+		if err := s.EnsureLifecycleRules(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := mem.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{Bucket: aws.String(testBucket)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := make(map[string]types.LifecycleRule)
+	var ids []string
+	for _, rule := range out.Rules {
+		ids = append(ids, aws.ToString(rule.ID))
+		rules[aws.ToString(rule.ID)] = rule
+	}
+	want := []string{
+		"logs",
+		"s3store-other-expire-trash", "s3store-other-abort-multipart", "s3store-other-transition-ocsp",
+		"s3store-caddy-expire-trash", "s3store-caddy-abort-multipart", "s3store-caddy-transition-ocsp",
+	}
+	if !reflect.DeepEqual(ids, want) {
+		t.Fatalf("rules = %q, want %q", ids, want)
+	}
+	trash := rules["s3store-caddy-expire-trash"]
+	if aws.ToString(trash.Filter.Prefix) != "caddy/trash/" || aws.ToInt32(trash.Expiration.Days) != 2 {
+		t.Errorf("trash rule expires %s after %d days", aws.ToString(trash.Filter.Prefix), aws.ToInt32(trash.Expiration.Days))
+	}
+	if p := aws.ToString(rules["s3store-caddy-transition-ocsp"].Filter.Prefix); p != "caddy/ocsp/" {
+		t.Errorf("OCSP rule prefix = %s", p)
+	}
+}
+
+func TestEnsureLifecycleRulesSharedBucket(t *testing.T) {
+	ctx := context.Background()
+	s, mem := newTestStore(t)
+	staging := newTestStoreOn(t, mem, s3store.WithPrefix("certmagic-staging"))
+	if err := staging.EnsureLifecycleRules(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.EnsureLifecycleRules(ctx); err != nil {
+		t.Fatal(err)
+	}
+	out, err := mem.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{Bucket: aws.String(testBucket)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, rule := range out.Rules {
+		ids = append(ids, aws.ToString(rule.ID))
+	}
+	want := []string{
+		"s3store-certmagic-staging-expire-trash", "s3store-certmagic-staging-abort-multipart", "s3store-certmagic-staging-transition-ocsp",
+		"s3store-certmagic-expire-trash", "s3store-certmagic-abort-multipart", "s3store-certmagic-transition-ocsp",
+	}
+	if !reflect.DeepEqual(ids, want) {
+		t.Fatalf("rules = %q, want both stores' rules", ids)
+	}
+}
@@ -0,0 +1,41 @@
+package s3store
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// WithMaxConcurrency bounds the number of S3 requests the store has in
+// flight at once to n, protecting both S3 request rate limits and the
+// host's file descriptors when thousands of certificates are maintained.
+// Requests beyond the limit wait for a free slot or for their context to
+// be cancelled. The limit is enforced by the s3 client, so it does not
+// apply to a custom S3API.
+func WithMaxConcurrency(n int) Option {
+	return func(s *S3Store) {
+		sem := make(chan struct{}, n)
+		s.clientOpts = append(s.clientOpts, func(o *s3.Options) {
+			o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+				return stack.Initialize.Add(concurrencyLimit(sem), middleware.Before)
+			})
+		})
+	}
+}
+
+// concurrencyLimit returns a middleware holding a slot of sem
+// for the duration of each request.
+func concurrencyLimit(sem chan struct{}) middleware.InitializeMiddleware {
+	return middleware.InitializeMiddlewareFunc("S3StoreConcurrencyLimit", func(
+		ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler,
+	) (middleware.InitializeOutput, middleware.Metadata, error) {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return middleware.InitializeOutput{}, middleware.Metadata{}, ctx.Err()
+		}
+		defer func() { <-sem }()
+		return next.HandleInitialize(ctx, in)
+	})
+}
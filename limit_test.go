@@ -0,0 +1,42 @@
+package s3store_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+func TestMaxConcurrency(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeS3(t)
+	s := s3store.NewS3StoreForTesting(f.URL, "certs", s3store.WithMaxConcurrency(2))
+
+	var inFlight, peak atomic.Int32
+	f.handle = func(*http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Store(ctx, fmt.Sprint(i), []byte("v")); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if p := peak.Load(); p != 2 {
+		t.Fatalf("peak of %d concurrent requests, want 2", p)
+	}
+}
@@ -0,0 +1,252 @@
+package s3store
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// maxListSplitDepth limits how many directory levels a parallel
+// listing descends to find enough sub-prefixes to fan out over.
+const maxListSplitDepth = 3
+
+// WithParallelListing makes List and the other APIs enumerating keys
+// fan out over up to n sub-prefixes at once instead of paging through
+// the whole prefix sequentially. The sub-prefixes are discovered with
+// delimited listings, descending up to three directory levels until
+// there are at least n of them; combined with WithKeySharding this
+// splits certificates/ into 256 concurrently listed shards. List
+// returns the keys sorted.
+func WithParallelListing(n int) Option {
+	return func(s *S3Store) {
+		s.listConcurrency = n
+	}
+}
+
+// walkNamed calls fn for every object whose name starts with namePrefix,
+// listing sub-prefixes concurrently if parallel listing is enabled. Calls
+// to fn are serialized but not ordered.
+func (s *S3Store) walkNamed(ctx context.Context, namePrefix string, fn func(obj types.Object) error) error {
+	if s.listConcurrency <= 1 {
+		return s.eachObjectNamed(ctx, namePrefix, fn)
+	}
+	var mu sync.Mutex
+	serialFn := func(obj types.Object) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return fn(obj)
+	}
+	prefixes := []string{namePrefix}
+	for depth := 0; depth < maxListSplitDepth && len(prefixes) < s.listConcurrency; depth++ {
+		var next []string
+		for _, p := range prefixes {
+			sub, err := s.splitPrefix(ctx, p, serialFn)
+			if err != nil {
+				return err
+			}
+			next = append(next, sub...)
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		prefixes = next
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sem := make(chan struct{}, s.listConcurrency)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for _, p := range prefixes {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := s.eachObjectNamed(ctx, p, serialFn); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// splitPrefix lists namePrefix one directory level deep, calling fn for
+// the objects directly at that level and returning the sub-prefixes
+// below it.
+func (s *S3Store) splitPrefix(ctx context.Context, namePrefix string, fn func(obj types.Object) error) ([]string, error) {
+	var prefixes []string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket:    s.bucket,
+		Prefix:    aws.String(namePrefix),
+		Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			if err := fn(obj); err != nil {
+				return nil, err
+			}
+		}
+		for _, p := range page.CommonPrefixes {
+			prefixes = append(prefixes, aws.ToString(p.Prefix))
+		}
+	}
+	return prefixes, nil
+}
+
+// ListPage returns up to max keys under prefix, recursively, starting
+// at the continuation token returned by the previous call, or at the
+// beginning if token is empty. The returned token is empty once the
+// last page has been returned. A page may hold fewer than max keys even
+// if more follow. Tokens are opaque and only valid for the same prefix.
+// With legacy keys enabled the legacy names are listed after the current
+// ones, and keys stored under both are returned twice.
+func (s *S3Store) ListPage(ctx context.Context, prefix, token string, max int) ([]string, string, error) {
+	prefix = strings.Trim(prefix, "/")
+	namePrefix := s.Filename(ctx, prefix)
+	if namePrefix != "" {
+		namePrefix += "/"
+	}
+	names := s.objectNames(namePrefix)
+	ns := 0
+	if rest, ok := strings.CutPrefix(token, legacyTokenPrefix); ok && len(names) > 1 {
+		ns, token = 1, rest
+	}
+	input := &s3.ListObjectsV2Input{
+		Bucket: s.bucket,
+		Prefix: aws.String(names[ns]),
+	}
+	if token != "" {
+		input.ContinuationToken = aws.String(token)
+	}
+	if max > 0 {
+		input.MaxKeys = aws.Int32(int32(min(max, 1000)))
+	}
+	page, err := s.client.ListObjectsV2(ctx, input)
+	if err != nil {
+		return nil, "", s.opError("list", prefix, err)
+	}
+	keys := make([]string, 0, len(page.Contents))
+	for _, obj := range page.Contents {
+		key := s.keyName(aws.ToString(obj.Key))
+		if (prefix == "" || strings.HasPrefix(key, prefix+"/")) && !auditLogged(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	if !aws.ToBool(page.IsTruncated) {
+		if ns+1 < len(names) {
+			return keys, legacyTokenPrefix, nil
+		}
+		return keys, "", nil
+	}
+	next := aws.ToString(page.NextContinuationToken)
+	if ns > 0 {
+		next = legacyTokenPrefix + next
+	}
+	return keys, next, nil
+}
+
+// errStopIter stops a listing once an iterator's consumer is done.
+var errStopIter = errors.New("iteration stopped")
+
+// Iter returns an iterator over the keys under prefix, recursively,
+// yielding each key as its listing page arrives instead of collecting
+// them all first. A listing error is yielded once with an empty key and
+// ends the iteration.
+func (s *S3Store) Iter(ctx context.Context, prefix string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		prefix := strings.Trim(prefix, "/")
+		namePrefix := s.Filename(ctx, prefix)
+		if namePrefix != "" {
+			namePrefix += "/"
+		}
+		// Keys stored under both their current and legacy
+		// names are only yielded once.
+		names := s.objectNames(namePrefix)
+		seen := make(map[string]bool)
+		var err error
+		for _, p := range names {
+			err = s.eachObjectNamed(ctx, p, func(obj types.Object) error {
+				key := s.keyName(aws.ToString(obj.Key))
+				if prefix != "" && !strings.HasPrefix(key, prefix+"/") || auditLogged(key, prefix) {
+					return nil
+				}
+				if len(names) > 1 {
+					if seen[key] {
+						return nil
+					}
+					seen[key] = true
+				}
+				if !yield(key, nil) {
+					return errStopIter
+				}
+				return nil
+			})
+			if err != nil {
+				break
+			}
+		}
+		if err != nil && !errors.Is(err, errStopIter) {
+			yield("", s.opError("list", prefix, err))
+		}
+	}
+}
+
+// PrefixStats summarizes the objects stored under a prefix.
+type PrefixStats struct {
+	Count int
+	Size  int64
+	// Oldest and Newest are the earliest and latest modification
+	// times; both are zero if the prefix is empty.
+	Oldest time.Time
+	Newest time.Time
+}
+
+// StatPrefix returns the number, total size and modification time range
+// of the objects under prefix, recursively, e.g. for capacity dashboards
+// or as a sanity check before a migration.
+func (s *S3Store) StatPrefix(ctx context.Context, prefix string) (PrefixStats, error) {
+	var stats PrefixStats
+	prefix = strings.Trim(prefix, "/")
+	namePrefix := s.Filename(ctx, prefix)
+	if namePrefix != "" {
+		namePrefix += "/"
+	}
+	err := s.walkNames(ctx, namePrefix, func(obj types.Object) error {
+		if key := s.keyName(aws.ToString(obj.Key)); prefix != "" && !strings.HasPrefix(key, prefix+"/") {
+			return nil
+		}
+		modified := aws.ToTime(obj.LastModified)
+		if stats.Count == 0 || modified.Before(stats.Oldest) {
+			stats.Oldest = modified
+		}
+		if modified.After(stats.Newest) {
+			stats.Newest = modified
+		}
+		stats.Count++
+		stats.Size += aws.ToInt64(obj.Size)
+		return nil
+	})
+	if err != nil {
+		return PrefixStats{}, s.opError("stat prefix", prefix, err)
+	}
+	return stats, nil
+}
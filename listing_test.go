@@ -0,0 +1,163 @@
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+func TestListRelativeToPrefix(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t, s3store.WithPrefix("/caddy/prod/"))
+	mustStore(t, s, "certificates/a.crt", "certificates/sub/b.crt")
+
+	keys, err := s.List(ctx, "certificates", false)
+	if want := []string{"certificates/a.crt", "certificates/sub"}; err != nil || !reflect.DeepEqual(keys, want) {
+		t.Fatalf("List = %q, %v, want %q", keys, err, want)
+	}
+	keys, err = s.List(ctx, "", true)
+	if want := []string{"certificates/a.crt", "certificates/sub/b.crt"}; err != nil || !reflect.DeepEqual(keys, want) {
+		t.Fatalf("recursive List = %q, %v, want %q", keys, err, want)
+	}
+}
+
+func TestParallelListing(t *testing.T) {
+	ctx := context.Background()
+	var want []string
+	for _, dir := range []string{"a", "b", "c/x", "c/y", "d/e/f"} {
+		for i := range 3 {
+			want = append(want, fmt.Sprintf("certificates/%s/%d", dir, i))
+		}
+	}
+	want = append(want, "certificates/top")
+	slices.Sort(want)
+
+	for _, opts := range [][]s3store.Option{
+		{s3store.WithParallelListing(4)},
+		{s3store.WithParallelListing(100)},
+		{s3store.WithParallelListing(8), s3store.WithKeySharding(1)},
+	} {
+		s, _ := newTestStore(t, opts...)
+		mustStore(t, s, want...)
+		mustStore(t, s, "ocsp/x")
+		keys, err := s.List(ctx, "certificates", true)
+		if err != nil || !reflect.DeepEqual(keys, want) {
+			t.Errorf("parallel List = %q, %v, want %q", keys, err, want)
+		}
+	}
+}
+
+func TestListModifiedSince(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	mustStore(t, s, "certificates/old", "certificates/changed")
+	since := time.Now()
+	time.Sleep(time.Millisecond)
+	mustStore(t, s, "certificates/changed", "certificates/new", "ocsp/new")
+
+	keys, err := s.ListModifiedSince(ctx, "certificates", since)
+	if want := []string{"certificates/changed", "certificates/new"}; err != nil || !reflect.DeepEqual(keys, want) {
+		t.Fatalf("ListModifiedSince = %q, %v, want %q", keys, err, want)
+	}
+}
+
+func TestListPage(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	mustStore(t, s, "a/1", "a/2", "a/3", "a/4", "a/5", "ab/1", "b/1")
+
+	var pages [][]string
+	token := ""
+	for {
+		keys, next, err := s.ListPage(ctx, "a", token, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, keys)
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	want := [][]string{{"a/1", "a/2"}, {"a/3", "a/4"}, {"a/5"}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Fatalf("pages = %q, want %q", pages, want)
+	}
+}
+
+// failingList fails every listing.
+type failingList struct {
+	*memstore.Client
+}
+
+func (failingList) ListObjectsV2(context.Context, *s3.ListObjectsV2Input, ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	return nil, errors.New("listing failed")
+}
+
+func TestIter(t *testing.T) {
+	ctx := context.Background()
+	s, mem := newTestStore(t)
+	mustStore(t, s, "a/1", "a/2", "a/3", "b/1")
+
+	var keys []string
+	for key, err := range s.Iter(ctx, "a") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	if want := []string{"a/1", "a/2", "a/3"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("Iter = %q, want %q", keys, want)
+	}
+
+	n := 0
+	for range s.Iter(ctx, "") {
+		if n++; n == 2 {
+			break
+		}
+	}
+	if n != 2 {
+		t.Fatalf("iteration continued after break")
+	}
+
+	broken := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(failingList{mem}))
+	var errs []error
+	for key, err := range broken.Iter(ctx, "a") {
+		if key != "" {
+			t.Errorf("Iter yielded %s from a failed listing", key)
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || errs[0] == nil {
+		t.Fatalf("Iter of a failed listing yielded %v, want one error", errs)
+	}
+}
+
+func TestStatPrefix(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	before := time.Now()
+	mustStore(t, s, "a/1", "a/22", "a/b/333", "ab")
+
+	stats, err := s.StatPrefix(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Count != 3 || stats.Size != int64(len("a/1")+len("a/22")+len("a/b/333")) {
+		t.Errorf("StatPrefix = %+v, want 3 objects of 14 bytes", stats)
+	}
+	if stats.Oldest.Before(before) || stats.Newest.Before(stats.Oldest) || time.Since(stats.Newest) > time.Minute {
+		t.Errorf("StatPrefix ages = %v to %v", stats.Oldest, stats.Newest)
+	}
+	if stats, err := s.StatPrefix(ctx, "missing"); err != nil || stats != (s3store.PrefixStats{}) {
+		t.Errorf("StatPrefix of an empty prefix = %+v, %v", stats, err)
+	}
+}
@@ -0,0 +1,105 @@
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// LockInfo is the metadata written into a lock file, describing the
+// lock's holder. Lock files written by older versions hold none of it.
+type LockInfo struct {
+	Hostname   string    `json:"hostname,omitempty"`
+	PID        int       `json:"pid,omitempty"`
+	InstanceID string    `json:"instance_id,omitempty"`
+	Token      uint64    `json:"token,omitempty"`
+	AcquiredAt time.Time `json:"acquired_at,omitzero"`
+	ExpiresAt  time.Time `json:"expires_at,omitzero"`
+}
+
+// lockRecord is a lock file as read from S3.
+type lockRecord struct {
+	LockInfo
+	modified time.Time
+	etag     string
+}
+
+// stale reports whether the lock has expired. Lock files without an
+// expiry are stale once they are older than the stale lock duration.
+func (r lockRecord) stale() bool {
+	if !r.ExpiresAt.IsZero() {
+		return time.Now().After(r.ExpiresAt)
+	}
+	return time.Since(r.modified) > staleLockDuration
+}
+
+// newLockInfo describes a lock acquired now by this process.
+func (s *S3Store) newLockInfo(token uint64) LockInfo {
+	hostname, _ := os.Hostname()
+	now := time.Now().UTC()
+	return LockInfo{
+		Hostname:   hostname,
+		PID:        os.Getpid(),
+		InstanceID: s.lockState.instanceID,
+		Token:      token,
+		AcquiredAt: now,
+		ExpiresAt:  now.Add(staleLockDuration),
+	}
+}
+
+// readLockRecord reads the lock file with the given object name.
+func (s *S3Store) readLockRecord(ctx context.Context, lockFile string) (lockRecord, error) {
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: s.bucket,
+		Key:    aws.String(lockFile),
+	})
+	if err != nil {
+		return lockRecord{}, err
+	}
+	defer result.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(result.Body, 4096))
+	if err != nil {
+		return lockRecord{}, err
+	}
+	rec := lockRecord{
+		modified: aws.ToTime(result.LastModified),
+		etag:     aws.ToString(result.ETag),
+	}
+	// Lock files written by older versions aren't JSON;
+	// their metadata is simply left empty.
+	_ = json.Unmarshal(b, &rec.LockInfo)
+	return rec, nil
+}
+
+// writeLockFile writes info into the lock file with the given object
+// name. If etag is "*" the write only succeeds if the lock file doesn't
+// exist, and if it is another non-empty value only if the lock file is
+// unchanged.
+func (s *S3Store) writeLockFile(ctx context.Context, lockFile string, info LockInfo, etag string) error {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	input := &s3.PutObjectInput{
+		Bucket:      s.bucket,
+		Key:         aws.String(lockFile),
+		Body:        bytes.NewReader(b),
+		ContentType: aws.String("application/json"),
+		ACL:         s.acl,
+	}
+	switch etag {
+	case "":
+	case "*":
+		input.IfNoneMatch = aws.String(etag)
+	default:
+		input.IfMatch = aws.String(etag)
+	}
+	_, err = s.putObject(ctx, input)
+	return err
+}
@@ -0,0 +1,58 @@
+package s3store_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+func TestLockFileMetadata(t *testing.T) {
+	ctx := context.Background()
+	s, mem := newTestStore(t)
+	token, err := s.LockWithToken(ctx, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := mem.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(testBucket),
+		Key:    aws.String("certmagic/locks/k.lock"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(out.Body)
+	var info s3store.LockInfo
+	if err := json.Unmarshal(b, &info); err != nil {
+		t.Fatalf("lock file %q isn't JSON: %v", b, err)
+	}
+	host, _ := os.Hostname()
+	if info.Hostname != host || info.PID != os.Getpid() || info.InstanceID == "" || info.Token != token {
+		t.Errorf("lock file = %+v", info)
+	}
+	if time.Since(info.AcquiredAt) > time.Minute || info.ExpiresAt.Before(time.Now()) {
+		t.Errorf("lock acquired at %v, expiring at %v", info.AcquiredAt, info.ExpiresAt)
+	}
+
+	// Lock files without metadata, as written by older
+	// versions, are still honored until they go stale.
+	other := newTestStoreOn(t, mem)
+	_, err = mem.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(testBucket),
+		Key:    aws.String("certmagic/locks/old.lock"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := other.Lock(tctx, "old"); err == nil {
+		t.Fatal("Lock took a fresh lock file without metadata")
+	}
+}
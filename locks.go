@@ -0,0 +1,75 @@
+package s3store
+
+import (
+	"context"
+	"log"
+	"path"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// CleanStaleLocks removes every lock file that has expired, so that
+// locks left behind by crashed nodes don't block issuance until a
+// waiter happens to steal them. Lock files renewed or taken over since
+// they were found stale are left alone. It returns the number of locks
+// removed, or in dry-run mode that would have been removed.
+func (s *S3Store) CleanStaleLocks(ctx context.Context) (int, error) {
+	locks, err := s.Locks(ctx)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, l := range locks {
+		// Lock names are already escaped, so they
+		// can't be passed to lockFileName.
+		lockFile := path.Join(s.lockDir(), l.Key+".lock")
+		rec, err := s.readLockRecord(ctx, lockFile)
+		if s.errNoSuchKey(err) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		if !rec.stale() {
+			continue
+		}
+		if s.skipDryRun("unlock", l.Key) {
+			removed++
+			continue
+		}
+		log.Printf("[INFO][%s] Removing stale lock '%s' (%s old)",
+			s, l.Key, time.Since(l.Modified).Round(time.Second))
+		_, err = s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket:  s.bucket,
+			Key:     aws.String(lockFile),
+			IfMatch: aws.String(rec.etag),
+		})
+		if isPreconditionFailed(err) || s.errNoSuchKey(err) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// SweepStaleLocks calls CleanStaleLocks every interval until ctx is
+// cancelled. It is meant to be run in its own goroutine.
+func (s *S3Store) SweepStaleLocks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.CleanStaleLocks(ctx); err != nil {
+				log.Printf("[ERROR][%s] Cleaning stale locks: %v", s, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package s3store_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+// putLock writes a lock file for name expiring at expires.
+func putLock(t *testing.T, api s3store.S3API, name string, expires time.Time) {
+	t.Helper()
+	b, _ := json.Marshal(s3store.LockInfo{ExpiresAt: expires})
+	_, err := api.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket: aws.String(testBucket),
+		Key:    aws.String("certmagic/locks/" + name + ".lock"),
+		Body:   strings.NewReader(string(b)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCleanStaleLocks(t *testing.T) {
+	ctx := context.Background()
+	s, mem := newTestStore(t)
+	putLock(t, mem, "stale", time.Now().Add(-time.Minute))
+	putLock(t, mem, "live", time.Now().Add(time.Minute))
+
+	n, err := s.CleanStaleLocks(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("CleanStaleLocks = %d, %v, want 1 lock removed", n, err)
+	}
+	locks, err := s.Locks(ctx)
+	if err != nil || len(locks) != 1 || locks[0].Key != "live" {
+		t.Fatalf("Locks = %v, %v, want only the live lock", locks, err)
+	}
+	if err := s.ForceUnlock(ctx, locks[0].Key); err != nil {
+		t.Fatal(err)
+	}
+	if locks, _ := s.Locks(ctx); len(locks) != 0 {
+		t.Fatalf("ForceUnlock left %v", locks)
+	}
+}
+
+// renewing renews every lock file right after it was read.
+type renewing struct {
+	*memstore.Client
+	t *testing.T
+}
+
+func (r renewing) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	out, err := r.Client.GetObject(ctx, params, optFns...)
+	if name, ok := strings.CutSuffix(aws.ToString(params.Key), ".lock"); ok && err == nil {
+		putLock(r.t, r.Client, strings.TrimPrefix(name, "certmagic/locks/"), time.Now().Add(time.Minute))
+	}
+	return out, err
+}
+
+func TestCleanStaleLocksKeepsRenewedLock(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	putLock(t, mem, "renewed", time.Now().Add(-time.Minute))
+	s := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(renewing{mem, t}))
+
+	n, err := s.CleanStaleLocks(ctx)
+	if err != nil || n != 0 {
+		t.Fatalf("CleanStaleLocks = %d, %v, want no locks removed", n, err)
+	}
+	if locks, _ := s.Locks(ctx); len(locks) != 1 {
+		t.Fatalf("renewed lock was removed")
+	}
+}
+
+func TestSweepStaleLocks(t *testing.T) {
+	s, mem := newTestStore(t)
+	putLock(t, mem, "stale", time.Now().Add(-time.Minute))
+	if err := s.Lock(context.Background(), "live"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.SweepStaleLocks(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Exists(context.Background(), "locks/stale.lock") {
+		if time.Now().After(deadline) {
+			t.Fatal("stale lock not removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SweepStaleLocks didn't return after its context was cancelled")
+	}
+	if !s.Exists(context.Background(), "locks/live.lock") {
+		t.Fatal("SweepStaleLocks removed a held lock")
+	}
+}
@@ -0,0 +1,184 @@
+package s3store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LockStats describes the contention on a lock as seen by this process.
+type LockStats struct {
+	Key string
+	// Acquisitions counts the successful Lock calls.
+	Acquisitions int
+	// TotalWait and MaxWait are the total and longest time
+	// successful Lock calls waited for the lock.
+	TotalWait time.Duration
+	MaxWait   time.Duration
+	// Steals counts the stale locks removed to obtain the lock.
+	Steals int
+	// Waiters is the number of Lock calls currently waiting.
+	Waiters int
+}
+
+// LockMetrics returns the contention statistics of every lock this
+// process has tried to obtain through the store or copies of it, so
+// operators can tell when issuance is serialized behind a contended
+// lock.
+func (s *S3Store) LockMetrics() []LockStats {
+	return s.lockState.metrics()
+}
+
+// Close releases every lock this process holds through the store or
+// copies of it, such as sub-stores. It is meant to be called on
+// shutdown, so that a restart doesn't leave locks behind that block
+// other instances until they become stale.
+func (s *S3Store) Close(ctx context.Context) error {
+	var errs []error
+	for _, h := range s.lockState.heldLocks() {
+		if err := h.store.Unlock(ctx, h.key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// lockState tracks this process's use of the store's locks, by lock
+// file name. It is shared by copies of a store, such as sub-stores.
+type lockState struct {
+	instanceID string
+
+	mu    sync.Mutex
+	stats map[string]*LockStats
+	held  map[string]heldLock
+}
+
+// heldLock is a lock acquired by this process.
+type heldLock struct {
+	store   *S3Store
+	key     string
+	token   uint64
+	expires time.Time
+}
+
+func newLockState() *lockState {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return &lockState{
+		instanceID: hex.EncodeToString(id),
+		stats:      make(map[string]*LockStats),
+		held:       make(map[string]heldLock),
+	}
+}
+
+// acquired records that s holds the lock on key stored in name,
+// with the given fencing token.
+func (l *lockState) acquired(s *S3Store, key, name string, token uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.held[name] = heldLock{
+		store:   s,
+		key:     key,
+		token:   token,
+		expires: time.Now().Add(staleLockDuration),
+	}
+}
+
+// holding returns the lock stored in name if this process holds it.
+func (l *lockState) holding(name string) (heldLock, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.held[name]
+	return h, ok
+}
+
+// renewed extends the expiry of the lock stored in name.
+func (l *lockState) renewed(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if h, ok := l.held[name]; ok {
+		h.expires = time.Now().Add(staleLockDuration)
+		l.held[name] = h
+	}
+}
+
+// released records that the lock stored in name is no longer held.
+func (l *lockState) released(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.held, name)
+}
+
+func (l *lockState) heldLocks() []heldLock {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	held := make([]heldLock, 0, len(l.held))
+	for _, h := range l.held {
+		held = append(held, h)
+	}
+	return held
+}
+
+// get returns the stats for the lock file name. l.mu must be held.
+func (l *lockState) get(key, name string) *LockStats {
+	st, ok := l.stats[name]
+	if !ok {
+		st = &LockStats{Key: key}
+		l.stats[name] = st
+	}
+	return st
+}
+
+// wait registers a waiter for the lock on key stored in the lock file
+// name and returns a function unregistering it, which records the wait
+// if the lock was acquired.
+func (l *lockState) wait(key, name string) func(acquired bool) {
+	start := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.get(key, name).Waiters++
+	return func(acquired bool) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		st := l.get(key, name)
+		st.Waiters--
+		if acquired {
+			waited := time.Since(start)
+			st.Acquisitions++
+			st.TotalWait += waited
+			st.MaxWait = max(st.MaxWait, waited)
+		}
+	}
+}
+
+// stolen records the removal of a stale lock.
+func (l *lockState) stolen(key, name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.get(key, name).Steals++
+}
+
+// waiting returns the number of waiters for the lock file name.
+func (l *lockState) waiting(name string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if st, ok := l.stats[name]; ok {
+		return st.Waiters
+	}
+	return 0
+}
+
+func (l *lockState) metrics() []LockStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := make([]LockStats, 0, len(l.stats))
+	for _, st := range l.stats {
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Key < stats[j].Key })
+	return stats
+}
@@ -0,0 +1,199 @@
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+func TestLockMetrics(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	a := newTestStoreOn(t, mem)
+	b := newTestStoreOn(t, mem)
+
+	putLock(t, mem, "stale", time.Now().Add(-time.Minute))
+	if err := a.Lock(ctx, "stale"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Lock(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	acquired := make(chan error, 1)
+	go func() { acquired <- b.Lock(ctx, "k") }()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if stats := b.LockMetrics(); len(stats) == 1 && stats[0].Waiters == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("LockMetrics = %+v, want a waiter", b.LockMetrics())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := a.Unlock(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-acquired; err != nil {
+		t.Fatal(err)
+	}
+
+	stats := a.LockMetrics()
+	if len(stats) != 2 || stats[0].Key != "k" || stats[1].Key != "stale" {
+		t.Fatalf("LockMetrics = %+v, want stats for k and stale", stats)
+	}
+	if st := stats[1]; st.Acquisitions != 1 || st.Steals != 1 || st.Waiters != 0 {
+		t.Errorf("stats of the stolen lock = %+v", st)
+	}
+	st := b.LockMetrics()[0]
+	if st.Acquisitions != 1 || st.Waiters != 0 || st.MaxWait < 100*time.Millisecond || st.TotalWait != st.MaxWait {
+		t.Errorf("stats of the contended lock = %+v", st)
+	}
+}
+
+func TestClose(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	sub := s.SubStore("sub")
+	if err := s.Lock(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.Lock(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if locks, _ := s.Locks(ctx); len(locks) != 0 {
+		t.Fatalf("Close left %v", locks)
+	}
+	if locks, _ := sub.Locks(ctx); len(locks) != 0 {
+		t.Fatalf("Close left the sub-store's %v", locks)
+	}
+}
+
+func TestUnlockTakenOverLock(t *testing.T) {
+	ctx := context.Background()
+	s, mem := newTestStore(t)
+	if err := s.Lock(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	// Someone else steals the lock, e.g. while this process was paused.
+	putLock(t, mem, "k", time.Now().Add(time.Minute))
+
+	if err := s.Unlock(ctx, "k"); !errors.Is(err, s3store.ErrLockStale) {
+		t.Fatalf("Unlock of a taken over lock = %v, want ErrLockStale", err)
+	}
+	if locks, _ := s.Locks(ctx); len(locks) != 1 {
+		t.Fatal("Unlock removed the new holder's lock")
+	}
+	if err := s.Unlock(ctx, "k"); !errors.Is(err, s3store.ErrLockNotHeld) {
+		t.Fatalf("second Unlock = %v, want ErrLockNotHeld", err)
+	}
+}
+
+func TestStealPolicy(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	putLock(t, mem, "k", time.Now().Add(-time.Minute))
+
+	never := newTestStoreOn(t, mem, s3store.WithStealPolicy(s3store.NeverSteal))
+	if err := never.Lock(ctx, "k"); !errors.Is(err, s3store.ErrLockStale) {
+		t.Fatalf("Lock of a stale lock with NeverSteal = %v, want ErrLockStale", err)
+	}
+
+	patient := newTestStoreOn(t, mem, s3store.WithStealPolicy(s3store.StealAfter(2, 500*time.Millisecond)))
+	start := time.Now()
+	if err := patient.Lock(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 500*time.Millisecond {
+		t.Fatalf("stale lock stolen after %v, want at least 500ms", waited)
+	}
+	if st := patient.LockMetrics()[0]; st.Steals != 1 {
+		t.Fatalf("LockMetrics = %+v, want 1 steal", st)
+	}
+}
+
+// stealStep runs hooks around the lock file calls of one store, so a
+// test can interleave the stores stealing the same stale lock.
+type stealStep struct {
+	*memstore.Client
+	afterGet, beforeDelete, afterPut func()
+}
+
+func (s stealStep) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	out, err := s.Client.GetObject(ctx, params, optFns...)
+	if strings.HasSuffix(aws.ToString(params.Key), ".lock") && s.afterGet != nil {
+		s.afterGet()
+	}
+	return out, err
+}
+
+func (s stealStep) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	if strings.HasSuffix(aws.ToString(params.Key), ".lock") && s.beforeDelete != nil {
+		s.beforeDelete()
+	}
+	return s.Client.DeleteObject(ctx, params, optFns...)
+}
+
+func (s stealStep) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	out, err := s.Client.PutObject(ctx, params, optFns...)
+	if strings.HasSuffix(aws.ToString(params.Key), ".lock") && err == nil && s.afterPut != nil {
+		s.afterPut()
+	}
+	return out, err
+}
+
+func TestConcurrentSteal(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	putLock(t, mem, "k", time.Now().Add(-time.Minute))
+
+	// Both stores see the same stale lock, a steals it first and b
+	// only tries to remove it once a holds the new lock.
+	bRead, aLocked := make(chan struct{}), make(chan struct{})
+	var bReadOnce, aLockedOnce sync.Once
+	a := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(stealStep{
+		Client:       mem,
+		beforeDelete: func() { <-bRead },
+		afterPut:     func() { aLockedOnce.Do(func() { close(aLocked) }) },
+	}))
+	b := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(stealStep{
+		Client:       mem,
+		afterGet:     func() { bReadOnce.Do(func() { close(bRead) }) },
+		beforeDelete: func() { <-aLocked },
+	}))
+
+	errs := make(chan error, 2)
+	for _, s := range []*s3store.S3Store{a, b} {
+		go func() {
+			tctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+			defer cancel()
+			errs <- s.Lock(tctx, "k")
+		}()
+	}
+	var held int
+	for range 2 {
+		if err := <-errs; err == nil {
+			held++
+		} else if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatal(err)
+		}
+	}
+	if held != 1 {
+		t.Fatalf("%d stores hold the lock after stealing it concurrently, want 1", held)
+	}
+	if err := a.Unlock(ctx, "k"); err != nil {
+		t.Fatalf("Unlock by the first thief: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package s3store
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	cm "github.com/caddyserver/certmagic"
+)
+
+// ocspDir is the directory certmagic stores OCSP staples in.
+const ocspDir = "ocsp"
+
+// CleanupResult lists the keys removed by CleanExpired.
+type CleanupResult struct {
+	// Certificates holds the certificate keys whose certificate,
+	// private key and metadata were removed.
+	Certificates []string
+	// OCSPStaples holds the removed OCSP staple keys.
+	OCSPStaples []string
+}
+
+// CleanExpired deletes certificates that expired more than grace ago,
+// along with their private keys and metadata, and OCSP staples that do
+// not belong to any remaining certificate. It keeps buckets of large
+// deployments from growing without bound and is only run when called.
+func (s *S3Store) CleanExpired(ctx context.Context, grace time.Duration) (CleanupResult, error) {
+	var result CleanupResult
+	live := make(map[string]bool)
+
+	var certKeys []string
+	err := s.eachObjectNamed(ctx, s.Filename(ctx, certificatesDir)+"/", func(obj types.Object) error {
+		if key := s.keyName(aws.ToString(obj.Key)); strings.HasSuffix(key, ".crt") {
+			certKeys = append(certKeys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	for _, key := range certKeys {
+		bundle, err := s.Load(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			// deleted since it was listed
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		leaf, err := parseLeaf(bundle)
+		if err != nil {
+			log.Printf("[WARNING][%s] Skipping unparseable certificate '%s': %v", s, s.logKey(key), err)
+			continue
+		}
+		if time.Since(leaf.NotAfter) <= grace {
+			var cert cm.Certificate
+			if name := firstName(leaf); name != "" {
+				cert.Names = []string{name}
+			}
+			live[cm.StorageKeys.OCSPStaple(&cert, bundle)] = true
+			continue
+		}
+		base := strings.TrimSuffix(key, ".crt")
+		if err := s.DeleteMany(ctx, []string{key, base + ".key", base + ".json"}); err != nil {
+			return result, err
+		}
+		result.Certificates = append(result.Certificates, key)
+	}
+
+	var staples []string
+	err = s.eachObjectNamed(ctx, s.Filename(ctx, ocspDir)+"/", func(obj types.Object) error {
+		if key := s.keyName(aws.ToString(obj.Key)); !live[key] {
+			staples = append(staples, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	if len(staples) > 0 {
+		if err := s.DeleteMany(ctx, staples); err != nil {
+			return result, err
+		}
+		result.OCSPStaples = staples
+	}
+	return result, nil
+}
+
+// RunMaintenance calls CleanExpired every interval until
+// ctx is cancelled. It is meant to be run in its own goroutine.
+func (s *S3Store) RunMaintenance(ctx context.Context, interval, grace time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			result, err := s.CleanExpired(ctx, grace)
+			if err != nil {
+				log.Printf("[ERROR][%s] Cleaning expired certificates: %v", s, err)
+				continue
+			}
+			log.Printf("[INFO][%s] Removed %d expired certificates and %d OCSP staples",
+				s, len(result.Certificates), len(result.OCSPStaples))
+		}
+	}
+}
+
+// parseLeaf parses the first certificate of a PEM bundle.
+func parseLeaf(bundle []byte) (*x509.Certificate, error) {
+	for {
+		var block *pem.Block
+		block, bundle = pem.Decode(bundle)
+		if block == nil {
+			return nil, errors.New("no certificate found in PEM data")
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
+
+// firstName returns the first subject name of cert in the order
+// certmagic uses when naming the certificate's OCSP staple.
+func firstName(cert *x509.Certificate) string {
+	switch {
+	case cert.Subject.CommonName != "":
+		return strings.ToLower(cert.Subject.CommonName)
+	case len(cert.DNSNames) > 0:
+		return strings.ToLower(cert.DNSNames[0])
+	case len(cert.IPAddresses) > 0:
+		return cert.IPAddresses[0].String()
+	case len(cert.EmailAddresses) > 0:
+		return strings.ToLower(cert.EmailAddresses[0])
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	}
+	return ""
+}
@@ -0,0 +1,110 @@
+package s3store_test
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/caddyserver/certmagic"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+// vanishing reports every object whose name contains substr as
+// missing when read, as if it was deleted after being listed.
+type vanishing struct {
+	*memstore.Client
+	substr string
+}
+
+func (v vanishing) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if strings.Contains(aws.ToString(params.Key), v.substr) {
+		return nil, responseError(http.StatusNotFound, "NoSuchKey")
+	}
+	return v.Client.GetObject(ctx, params, optFns...)
+}
+
+// storeSite stores a certificate for name expiring after validity along
+// with its private key, metadata and OCSP staple, returning the keys of
+// the certificate and the staple.
+func storeSite(t *testing.T, s *s3store.S3Store, dir, name string, validity time.Duration) (crt, staple string) {
+	t.Helper()
+	certPEM, keyPEM := newCert(t, name, validity)
+	base := dir + "/acme/" + name + "/" + name
+	staple = certmagic.StorageKeys.OCSPStaple(&certmagic.Certificate{Names: []string{name}}, certPEM)
+	for key, value := range map[string][]byte{
+		base + ".crt":  certPEM,
+		base + ".key":  keyPEM,
+		base + ".json": []byte("{}"),
+		staple:         []byte("staple"),
+	} {
+		if err := s.Store(context.Background(), key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return base + ".crt", staple
+}
+
+func TestCleanExpired(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	s := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(vanishing{mem, "gone.com"}))
+	expired, expiredStaple := storeSite(t, s, "certificates", "expired.com", -48*time.Hour)
+	grace, graceStaple := storeSite(t, s, "certificates", "grace.com", -time.Hour)
+	live, liveStaple := storeSite(t, s, "certificates", "live.com", 30*24*time.Hour)
+	_, goneStaple := storeSite(t, s, "certificates", "gone.com", -48*time.Hour)
+	old, oldStaple := storeSite(t, s, "certificates-old", "old.com", -48*time.Hour)
+	mustStore(t, s, "ocsp/orphan")
+
+	result, err := s.CleanExpired(ctx, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Certificates, []string{expired}) {
+		t.Errorf("removed certificates = %q, want %q", result.Certificates, expired)
+	}
+	// Staples are kept only for certificates that remain.
+	staples := []string{expiredStaple, goneStaple, oldStaple, "ocsp/orphan"}
+	sort.Strings(staples)
+	if !reflect.DeepEqual(result.OCSPStaples, staples) {
+		t.Errorf("removed staples = %q, want %q", result.OCSPStaples, staples)
+	}
+
+	base := strings.TrimSuffix(expired, ".crt")
+	for _, key := range []string{expired, base + ".key", base + ".json", expiredStaple, "ocsp/orphan"} {
+		if s.Exists(ctx, key) {
+			t.Errorf("%s not removed", key)
+		}
+	}
+	for _, key := range []string{grace, graceStaple, live, liveStaple, old} {
+		if !s.Exists(ctx, key) {
+			t.Errorf("%s removed", key)
+		}
+	}
+}
+
+func TestRunMaintenance(t *testing.T) {
+	s, _ := newTestStore(t)
+	expired, _ := storeSite(t, s, "certificates", "expired.com", -48*time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.RunMaintenance(ctx, 10*time.Millisecond, time.Hour)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Exists(context.Background(), expired) {
+		if time.Now().After(deadline) {
+			t.Fatal("expired certificate not removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,750 @@
+// Package memstore provides an in-memory implementation of the s3
+// client methods used by s3store, so that the store's semantics and code
+// built on it can be tested hermetically:
+//
+//	store := s3store.NewS3Store("bucket", "", s3store.WithS3API(memstore.New("bucket")))
+package memstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+// Client keeps buckets and their objects in memory. It is safe for
+// concurrent use.
+type Client struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	uploads   map[string]*upload
+	uploadID  int
+	versionID int
+}
+
+type bucket struct {
+	objects      map[string]*object
+	versions     map[string][]*object
+	versioning   bool
+	lifecycle    []types.LifecycleRule
+	encryption   *types.ServerSideEncryptionConfiguration
+	publicAccess *types.PublicAccessBlockConfiguration
+}
+
+type object struct {
+	key          string
+	versionID    string
+	deleteMarker bool
+	data         []byte
+	etag         string
+	modified     time.Time
+	metadata     map[string]string
+	contentType  *string
+	cacheControl *string
+	storageClass types.StorageClass
+	tagging      *string
+	checksum     *string
+}
+
+type upload struct {
+	bucket string
+	key    string
+	input  *s3.CreateMultipartUploadInput
+	parts  map[int32][]byte
+}
+
+// New returns a Client holding the given empty buckets.
+func New(buckets ...string) *Client {
+	c := &Client{
+		buckets: make(map[string]*bucket),
+		uploads: make(map[string]*upload),
+	}
+	for _, b := range buckets {
+		c.buckets[b] = newBucket()
+	}
+	return c
+}
+
+func newBucket() *bucket {
+	return &bucket{
+		objects:  make(map[string]*object),
+		versions: make(map[string][]*object),
+	}
+}
+
+// put makes obj the current version of its key.
+func (c *Client) put(b *bucket, obj *object) {
+	obj.versionID = "null"
+	if b.versioning {
+		c.versionID++
+		obj.versionID = strconv.Itoa(c.versionID)
+		b.versions[obj.key] = append(b.versions[obj.key], obj)
+	} else {
+		b.versions[obj.key] = []*object{obj}
+	}
+	b.objects[obj.key] = obj
+}
+
+// remove deletes key, leaving a delete marker on versioned buckets.
+func (c *Client) remove(b *bucket, key string) {
+	delete(b.objects, key)
+	if !b.versioning {
+		delete(b.versions, key)
+		return
+	}
+	if _, ok := b.versions[key]; !ok {
+		return
+	}
+	c.versionID++
+	b.versions[key] = append(b.versions[key], &object{
+		key:          key,
+		versionID:    strconv.Itoa(c.versionID),
+		deleteMarker: true,
+		modified:     time.Now(),
+	})
+}
+
+// lookup returns the current object at key, or the given version of it.
+func (b *bucket) lookup(key string, versionID *string) (*object, bool) {
+	if versionID == nil {
+		obj, ok := b.objects[key]
+		return obj, ok
+	}
+	for _, obj := range b.versions[key] {
+		if obj.versionID == aws.ToString(versionID) && !obj.deleteMarker {
+			return obj, true
+		}
+	}
+	return nil, false
+}
+
+// responseError builds an error shaped like the ones returned
+// by the SDK for an HTTP error response.
+func responseError(status int, err error) error {
+	return &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: status, Header: http.Header{}}},
+			Err:      err,
+		},
+	}
+}
+
+func apiError(status int, code, message string) error {
+	return responseError(status, &smithy.GenericAPIError{Code: code, Message: message})
+}
+
+func (c *Client) bucket(name *string) (*bucket, error) {
+	b, ok := c.buckets[aws.ToString(name)]
+	if !ok {
+		return nil, responseError(http.StatusNotFound, &types.NoSuchBucket{Message: name})
+	}
+	return b, nil
+}
+
+func etagOf(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// GetObject returns the object's data, honoring If-Match and If-None-Match.
+func (c *Client) GetObject(_ context.Context, params *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := c.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := b.lookup(aws.ToString(params.Key), params.VersionId)
+	if !ok {
+		return nil, responseError(http.StatusNotFound, &types.NoSuchKey{})
+	}
+	if params.IfMatch != nil && aws.ToString(params.IfMatch) != obj.etag {
+		return nil, apiError(http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+	}
+	if params.IfNoneMatch != nil && aws.ToString(params.IfNoneMatch) == obj.etag {
+		return nil, apiError(http.StatusNotModified, "NotModified", "Not Modified")
+	}
+	out := &s3.GetObjectOutput{
+		Body:          io.NopCloser(bytes.NewReader(obj.data)),
+		ContentLength: aws.Int64(int64(len(obj.data))),
+		ETag:          aws.String(obj.etag),
+		LastModified:  aws.Time(obj.modified),
+		Metadata:      obj.metadata,
+		ContentType:   obj.contentType,
+		CacheControl:  obj.cacheControl,
+		VersionId:     aws.String(obj.versionID),
+	}
+	if params.ChecksumMode == types.ChecksumModeEnabled {
+		out.ChecksumSHA256 = obj.checksum
+	}
+	return out, nil
+}
+
+// PutObject stores an object, verifying any Content-MD5 or SHA-256
+// checksum sent and honoring If-Match and If-None-Match.
+func (c *Client) PutObject(_ context.Context, params *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	var data []byte
+	if params.Body != nil {
+		var err error
+		if data, err = io.ReadAll(params.Body); err != nil {
+			return nil, err
+		}
+	}
+	if params.ContentMD5 != nil {
+		sum := md5.Sum(data)
+		if base64.StdEncoding.EncodeToString(sum[:]) != aws.ToString(params.ContentMD5) {
+			return nil, apiError(http.StatusBadRequest, "BadDigest", "The Content-MD5 you specified did not match what we received")
+		}
+	}
+	if params.ChecksumSHA256 != nil {
+		sum := sha256.Sum256(data)
+		if base64.StdEncoding.EncodeToString(sum[:]) != aws.ToString(params.ChecksumSHA256) {
+			return nil, apiError(http.StatusBadRequest, "BadDigest", "The SHA256 you specified did not match the calculated checksum")
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := c.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	key := aws.ToString(params.Key)
+	existing, exists := b.objects[key]
+	if aws.ToString(params.IfNoneMatch) == "*" && exists {
+		return nil, apiError(http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+	}
+	if params.IfMatch != nil && (!exists || existing.etag != aws.ToString(params.IfMatch)) {
+		return nil, apiError(http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+	}
+	obj := &object{
+		key:          key,
+		data:         data,
+		etag:         etagOf(data),
+		modified:     time.Now(),
+		metadata:     params.Metadata,
+		contentType:  params.ContentType,
+		cacheControl: params.CacheControl,
+		storageClass: params.StorageClass,
+		tagging:      params.Tagging,
+		checksum:     params.ChecksumSHA256,
+	}
+	c.put(b, obj)
+	return &s3.PutObjectOutput{ETag: aws.String(obj.etag), ChecksumSHA256: obj.checksum, VersionId: aws.String(obj.versionID)}, nil
+}
+
+// HeadObject returns the object's attributes.
+func (c *Client) HeadObject(_ context.Context, params *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := c.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := b.lookup(aws.ToString(params.Key), params.VersionId)
+	if !ok {
+		return nil, responseError(http.StatusNotFound, &types.NotFound{})
+	}
+	return &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(int64(len(obj.data))),
+		ETag:          aws.String(obj.etag),
+		LastModified:  aws.Time(obj.modified),
+		Metadata:      obj.metadata,
+		ContentType:   obj.contentType,
+		CacheControl:  obj.cacheControl,
+		StorageClass:  obj.storageClass,
+		VersionId:     aws.String(obj.versionID),
+	}, nil
+}
+
+// RestoreObject succeeds for existing objects. Objects are never
+// archived, so there is nothing to restore.
+func (c *Client) RestoreObject(_ context.Context, params *s3.RestoreObjectInput, _ ...func(*s3.Options)) (*s3.RestoreObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := c.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := b.lookup(aws.ToString(params.Key), params.VersionId); !ok {
+		return nil, responseError(http.StatusNotFound, &types.NoSuchKey{})
+	}
+	return &s3.RestoreObjectOutput{}, nil
+}
+
+// DeleteObject removes an object, honoring If-Match. Deleting a missing
+// key succeeds unless If-Match is set.
+func (c *Client) DeleteObject(_ context.Context, params *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := c.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if params.IfMatch != nil {
+		obj, ok := b.objects[aws.ToString(params.Key)]
+		if !ok {
+			return nil, responseError(http.StatusNotFound, &types.NoSuchKey{})
+		}
+		if obj.etag != aws.ToString(params.IfMatch) {
+			return nil, apiError(http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+		}
+	}
+	c.remove(b, aws.ToString(params.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+// DeleteObjects removes several objects at once.
+func (c *Client) DeleteObjects(_ context.Context, params *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := c.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	out := &s3.DeleteObjectsOutput{}
+	for _, id := range params.Delete.Objects {
+		c.remove(b, aws.ToString(id.Key))
+		if !aws.ToBool(params.Delete.Quiet) {
+			out.Deleted = append(out.Deleted, types.DeletedObject{Key: id.Key})
+		}
+	}
+	return out, nil
+}
+
+// CopyObject copies the object named by CopySource
+// ("bucket/key", optionally followed by "?versionId=id"),
+// honoring If-Match and If-None-Match like PutObject.
+func (c *Client) CopyObject(_ context.Context, params *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	source, query, _ := strings.Cut(aws.ToString(params.CopySource), "?")
+	source, err := url.PathUnescape(source)
+	if err != nil {
+		return nil, err
+	}
+	srcBucket, srcKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	var srcVersion *string
+	if v, err := url.ParseQuery(query); err == nil && v.Has("versionId") {
+		srcVersion = aws.String(v.Get("versionId"))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sb, err := c.bucket(aws.String(srcBucket))
+	if err != nil {
+		return nil, err
+	}
+	src, ok := sb.lookup(srcKey, srcVersion)
+	if !ok {
+		return nil, responseError(http.StatusNotFound, &types.NoSuchKey{})
+	}
+	db, err := c.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	existing, exists := db.objects[aws.ToString(params.Key)]
+	if aws.ToString(params.IfNoneMatch) == "*" && exists {
+		return nil, apiError(http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+	}
+	if params.IfMatch != nil && (!exists || existing.etag != aws.ToString(params.IfMatch)) {
+		return nil, apiError(http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+	}
+	dst := *src
+	dst.key = aws.ToString(params.Key)
+	dst.modified = time.Now()
+	c.put(db, &dst)
+	return &s3.CopyObjectOutput{CopyObjectResult: &types.CopyObjectResult{ETag: aws.String(dst.etag)}}, nil
+}
+
+// list returns the objects of bucket under prefix after startAfter in
+// key order, at most maxKeys of them, and whether more remain.
+func (c *Client) list(bucket *string, prefix, delimiter, startAfter string, maxKeys int) ([]types.Object, []types.CommonPrefix, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := c.bucket(bucket)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	// Entries are object keys or, with a delimiter, the common prefixes
+	// rolling up the keys below them.
+	seen := make(map[string]bool)
+	entries := make([]string, 0, len(b.objects))
+	for k := range b.objects {
+		if !strings.HasPrefix(k, prefix) || k <= startAfter {
+			continue
+		}
+		if delimiter != "" && strings.HasSuffix(startAfter, delimiter) && strings.HasPrefix(k, startAfter) {
+			continue
+		}
+		entry := k
+		if delimiter != "" {
+			if i := strings.Index(k[len(prefix):], delimiter); i >= 0 {
+				entry = k[:len(prefix)+i+len(delimiter)]
+			}
+		}
+		if !seen[entry] {
+			seen[entry] = true
+			entries = append(entries, entry)
+		}
+	}
+	sort.Strings(entries)
+	if maxKeys <= 0 {
+		maxKeys = 1000
+	}
+	truncated := len(entries) > maxKeys
+	if truncated {
+		entries = entries[:maxKeys]
+	}
+	var objects []types.Object
+	var prefixes []types.CommonPrefix
+	for _, k := range entries {
+		obj, ok := b.objects[k]
+		if !ok || (delimiter != "" && strings.HasSuffix(k, delimiter) && k != prefix) {
+			prefixes = append(prefixes, types.CommonPrefix{Prefix: aws.String(k)})
+			continue
+		}
+		objects = append(objects, types.Object{
+			Key:          aws.String(k),
+			Size:         aws.Int64(int64(len(obj.data))),
+			ETag:         aws.String(obj.etag),
+			LastModified: aws.Time(obj.modified),
+		})
+	}
+	return objects, prefixes, truncated, nil
+}
+
+// ListObjects lists objects under Prefix.
+func (c *Client) ListObjects(_ context.Context, params *s3.ListObjectsInput, _ ...func(*s3.Options)) (*s3.ListObjectsOutput, error) {
+	objects, prefixes, truncated, err := c.list(params.Bucket, aws.ToString(params.Prefix), aws.ToString(params.Delimiter), aws.ToString(params.Marker), int(aws.ToInt32(params.MaxKeys)))
+	if err != nil {
+		return nil, err
+	}
+	return &s3.ListObjectsOutput{Contents: objects, CommonPrefixes: prefixes, IsTruncated: aws.Bool(truncated), Prefix: params.Prefix}, nil
+}
+
+// ListObjectsV2 lists objects under Prefix, rolled up by Delimiter,
+// using the last key or prefix returned as the continuation token.
+func (c *Client) ListObjectsV2(_ context.Context, params *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	startAfter := aws.ToString(params.StartAfter)
+	if params.ContinuationToken != nil {
+		startAfter = aws.ToString(params.ContinuationToken)
+	}
+	objects, prefixes, truncated, err := c.list(params.Bucket, aws.ToString(params.Prefix), aws.ToString(params.Delimiter), startAfter, int(aws.ToInt32(params.MaxKeys)))
+	if err != nil {
+		return nil, err
+	}
+	out := &s3.ListObjectsV2Output{
+		Contents:       objects,
+		CommonPrefixes: prefixes,
+		IsTruncated:    aws.Bool(truncated),
+		KeyCount:       aws.Int32(int32(len(objects) + len(prefixes))),
+		Prefix:         params.Prefix,
+		Delimiter:      params.Delimiter,
+	}
+	if truncated {
+		last := ""
+		if n := len(objects); n > 0 {
+			last = aws.ToString(objects[n-1].Key)
+		}
+		if n := len(prefixes); n > 0 && aws.ToString(prefixes[n-1].Prefix) > last {
+			last = aws.ToString(prefixes[n-1].Prefix)
+		}
+		out.NextContinuationToken = aws.String(last)
+	}
+	return out, nil
+}
+
+// CreateMultipartUpload starts a multipart upload.
+func (c *Client) CreateMultipartUpload(_ context.Context, params *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.bucket(params.Bucket); err != nil {
+		return nil, err
+	}
+	c.uploadID++
+	id := strconv.Itoa(c.uploadID)
+	c.uploads[id] = &upload{
+		bucket: aws.ToString(params.Bucket),
+		key:    aws.ToString(params.Key),
+		input:  params,
+		parts:  make(map[int32][]byte),
+	}
+	return &s3.CreateMultipartUploadOutput{Bucket: params.Bucket, Key: params.Key, UploadId: aws.String(id)}, nil
+}
+
+// UploadPart stores one part of a multipart upload.
+func (c *Client) UploadPart(_ context.Context, params *s3.UploadPartInput, _ ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	u, ok := c.uploads[aws.ToString(params.UploadId)]
+	if !ok {
+		return nil, responseError(http.StatusNotFound, &types.NoSuchUpload{})
+	}
+	u.parts[aws.ToInt32(params.PartNumber)] = data
+	return &s3.UploadPartOutput{ETag: aws.String(etagOf(data))}, nil
+}
+
+// CompleteMultipartUpload assembles the uploaded parts into an object.
+func (c *Client) CompleteMultipartUpload(_ context.Context, params *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := aws.ToString(params.UploadId)
+	u, ok := c.uploads[id]
+	if !ok {
+		return nil, responseError(http.StatusNotFound, &types.NoSuchUpload{})
+	}
+	delete(c.uploads, id)
+	b, err := c.bucket(aws.String(u.bucket))
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	for _, part := range params.MultipartUpload.Parts {
+		data = append(data, u.parts[aws.ToInt32(part.PartNumber)]...)
+	}
+	etag := fmt.Sprintf(`%s-%d"`, strings.TrimSuffix(etagOf(data), `"`), len(params.MultipartUpload.Parts))
+	c.put(b, &object{
+		key:          u.key,
+		data:         data,
+		etag:         etag,
+		modified:     time.Now(),
+		metadata:     u.input.Metadata,
+		contentType:  u.input.ContentType,
+		cacheControl: u.input.CacheControl,
+		storageClass: u.input.StorageClass,
+		tagging:      u.input.Tagging,
+	})
+	return &s3.CompleteMultipartUploadOutput{Bucket: params.Bucket, Key: params.Key, ETag: aws.String(etag)}, nil
+}
+
+// AbortMultipartUpload discards a multipart upload.
+func (c *Client) AbortMultipartUpload(_ context.Context, params *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.uploads, aws.ToString(params.UploadId))
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+// ListObjectVersions lists all versions and delete markers of the keys
+// under Prefix, newest first per key, in a single page.
+func (c *Client) ListObjectVersions(_ context.Context, params *s3.ListObjectVersionsInput, _ ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := c.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(b.versions))
+	for k := range b.versions {
+		if strings.HasPrefix(k, aws.ToString(params.Prefix)) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	out := &s3.ListObjectVersionsOutput{IsTruncated: aws.Bool(false), Prefix: params.Prefix}
+	for _, k := range keys {
+		versions := b.versions[k]
+		for i := len(versions) - 1; i >= 0; i-- {
+			v := versions[i]
+			latest := aws.Bool(i == len(versions)-1)
+			if v.deleteMarker {
+				out.DeleteMarkers = append(out.DeleteMarkers, types.DeleteMarkerEntry{
+					Key:          aws.String(k),
+					VersionId:    aws.String(v.versionID),
+					IsLatest:     latest,
+					LastModified: aws.Time(v.modified),
+				})
+				continue
+			}
+			out.Versions = append(out.Versions, types.ObjectVersion{
+				Key:          aws.String(k),
+				VersionId:    aws.String(v.versionID),
+				IsLatest:     latest,
+				LastModified: aws.Time(v.modified),
+				ETag:         aws.String(v.etag),
+				Size:         aws.Int64(int64(len(v.data))),
+			})
+		}
+	}
+	return out, nil
+}
+
+// HeadBucket reports whether the bucket exists.
+func (c *Client) HeadBucket(_ context.Context, params *s3.HeadBucketInput, _ ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.buckets[aws.ToString(params.Bucket)]; !ok {
+		return nil, responseError(http.StatusNotFound, &types.NotFound{})
+	}
+	return &s3.HeadBucketOutput{}, nil
+}
+
+// CreateBucket creates an empty bucket.
+func (c *Client) CreateBucket(_ context.Context, params *s3.CreateBucketInput, _ ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name := aws.ToString(params.Bucket)
+	if _, ok := c.buckets[name]; ok {
+		return nil, responseError(http.StatusConflict, &types.BucketAlreadyOwnedByYou{})
+	}
+	c.buckets[name] = newBucket()
+	return &s3.CreateBucketOutput{}, nil
+}
+
+// PutBucketVersioning enables or suspends versioning. While enabled,
+// every write and delete adds a version.
+func (c *Client) PutBucketVersioning(_ context.Context, params *s3.PutBucketVersioningInput, _ ...func(*s3.Options)) (*s3.PutBucketVersioningOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := c.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	b.versioning = params.VersioningConfiguration.Status == types.BucketVersioningStatusEnabled
+	return &s3.PutBucketVersioningOutput{}, nil
+}
+
+// GetBucketVersioning reports whether versioning is enabled.
+func (c *Client) GetBucketVersioning(_ context.Context, params *s3.GetBucketVersioningInput, _ ...func(*s3.Options)) (*s3.GetBucketVersioningOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := c.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	out := &s3.GetBucketVersioningOutput{}
+	if b.versioning {
+		out.Status = types.BucketVersioningStatusEnabled
+	}
+	return out, nil
+}
+
+// PutBucketEncryption stores the default encryption configuration.
+// Objects aren't actually encrypted.
+func (c *Client) PutBucketEncryption(_ context.Context, params *s3.PutBucketEncryptionInput, _ ...func(*s3.Options)) (*s3.PutBucketEncryptionOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := c.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	b.encryption = params.ServerSideEncryptionConfiguration
+	return &s3.PutBucketEncryptionOutput{}, nil
+}
+
+// GetBucketEncryption returns the configuration last put on the bucket.
+func (c *Client) GetBucketEncryption(_ context.Context, params *s3.GetBucketEncryptionInput, _ ...func(*s3.Options)) (*s3.GetBucketEncryptionOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := c.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if b.encryption == nil {
+		return nil, apiError(http.StatusNotFound, "ServerSideEncryptionConfigurationNotFoundError", "The server side encryption configuration was not found")
+	}
+	return &s3.GetBucketEncryptionOutput{ServerSideEncryptionConfiguration: b.encryption}, nil
+}
+
+// PutPublicAccessBlock stores the bucket's Block Public Access settings.
+func (c *Client) PutPublicAccessBlock(_ context.Context, params *s3.PutPublicAccessBlockInput, _ ...func(*s3.Options)) (*s3.PutPublicAccessBlockOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := c.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	b.publicAccess = params.PublicAccessBlockConfiguration
+	return &s3.PutPublicAccessBlockOutput{}, nil
+}
+
+// GetPublicAccessBlock returns the settings last put on the bucket.
+func (c *Client) GetPublicAccessBlock(_ context.Context, params *s3.GetPublicAccessBlockInput, _ ...func(*s3.Options)) (*s3.GetPublicAccessBlockOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := c.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if b.publicAccess == nil {
+		return nil, apiError(http.StatusNotFound, "NoSuchPublicAccessBlockConfiguration", "The public access block configuration was not found")
+	}
+	return &s3.GetPublicAccessBlockOutput{PublicAccessBlockConfiguration: b.publicAccess}, nil
+}
+
+// GetBucketPolicy fails with NoSuchBucketPolicy,
+// as bucket policies aren't supported.
+func (c *Client) GetBucketPolicy(_ context.Context, params *s3.GetBucketPolicyInput, _ ...func(*s3.Options)) (*s3.GetBucketPolicyOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.bucket(params.Bucket); err != nil {
+		return nil, err
+	}
+	return nil, apiError(http.StatusNotFound, "NoSuchBucketPolicy", "The bucket policy does not exist")
+}
+
+// GetBucketPolicyStatus fails with NoSuchBucketPolicy,
+// as bucket policies aren't supported.
+func (c *Client) GetBucketPolicyStatus(_ context.Context, params *s3.GetBucketPolicyStatusInput, _ ...func(*s3.Options)) (*s3.GetBucketPolicyStatusOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.bucket(params.Bucket); err != nil {
+		return nil, err
+	}
+	return nil, apiError(http.StatusNotFound, "NoSuchBucketPolicy", "The bucket policy does not exist")
+}
+
+// GetBucketLifecycleConfiguration returns the rules last put on the bucket.
+func (c *Client) GetBucketLifecycleConfiguration(_ context.Context, params *s3.GetBucketLifecycleConfigurationInput, _ ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := c.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if b.lifecycle == nil {
+		return nil, apiError(http.StatusNotFound, "NoSuchLifecycleConfiguration", "The lifecycle configuration does not exist")
+	}
+	return &s3.GetBucketLifecycleConfigurationOutput{Rules: b.lifecycle}, nil
+}
+
+// PutBucketLifecycleConfiguration stores the rules; they aren't applied.
+func (c *Client) PutBucketLifecycleConfiguration(_ context.Context, params *s3.PutBucketLifecycleConfigurationInput, _ ...func(*s3.Options)) (*s3.PutBucketLifecycleConfigurationOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, err := c.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	b.lifecycle = []types.LifecycleRule{}
+	if params.LifecycleConfiguration != nil {
+		b.lifecycle = append(b.lifecycle, params.LifecycleConfiguration.Rules...)
+	}
+	return &s3.PutBucketLifecycleConfigurationOutput{}, nil
+}
+
+// Interface guard
+var _ s3store.S3API = (*Client)(nil)
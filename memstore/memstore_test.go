@@ -0,0 +1,251 @@
+package memstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func statusOf(err error) int {
+	var re *awshttp.ResponseError
+	if errors.As(err, &re) {
+		return re.HTTPStatusCode()
+	}
+	return 0
+}
+
+func put(t *testing.T, c *Client, key, value string) string {
+	t.Helper()
+	out, err := c.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket: aws.String("b"),
+		Key:    aws.String(key),
+		Body:   strings.NewReader(value),
+	})
+	if err != nil {
+		t.Fatalf("put %s: %v", key, err)
+	}
+	return aws.ToString(out.ETag)
+}
+
+func TestPutObjectConditional(t *testing.T) {
+	ctx := context.Background()
+	c := New("b")
+	etag := put(t, c, "k", "one")
+
+	_, err := c.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String("b"),
+		Key:         aws.String("k"),
+		Body:        strings.NewReader("two"),
+		IfNoneMatch: aws.String("*"),
+	})
+	if statusOf(err) != http.StatusPreconditionFailed {
+		t.Fatalf("If-None-Match on existing key: got %v, want 412", err)
+	}
+
+	_, err = c.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:  aws.String("b"),
+		Key:     aws.String("k"),
+		Body:    strings.NewReader("two"),
+		IfMatch: aws.String(`"stale"`),
+	})
+	if statusOf(err) != http.StatusPreconditionFailed {
+		t.Fatalf("If-Match with wrong ETag: got %v, want 412", err)
+	}
+
+	_, err = c.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:  aws.String("b"),
+		Key:     aws.String("k"),
+		Body:    strings.NewReader("two"),
+		IfMatch: aws.String(etag),
+	})
+	if err != nil {
+		t.Fatalf("If-Match with current ETag: %v", err)
+	}
+
+	_, err = c.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:  aws.String("b"),
+		Key:     aws.String("missing"),
+		Body:    strings.NewReader("x"),
+		IfMatch: aws.String(etag),
+	})
+	if statusOf(err) != http.StatusPreconditionFailed {
+		t.Fatalf("If-Match on missing key: got %v, want 412", err)
+	}
+}
+
+func TestGetObject(t *testing.T) {
+	ctx := context.Background()
+	c := New("b")
+	etag := put(t, c, "k", "value")
+
+	out, err := c.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("b"), Key: aws.String("k")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(out.Body)
+	if string(b) != "value" || aws.ToString(out.ETag) != etag {
+		t.Fatalf("got %q with ETag %s, want %q with ETag %s", b, aws.ToString(out.ETag), "value", etag)
+	}
+
+	_, err = c.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("b"), Key: aws.String("k"), IfNoneMatch: aws.String(etag)})
+	if statusOf(err) != http.StatusNotModified {
+		t.Fatalf("If-None-Match with current ETag: got %v, want 304", err)
+	}
+
+	_, err = c.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("b"), Key: aws.String("missing")})
+	var nsk *types.NoSuchKey
+	if !errors.As(err, &nsk) {
+		t.Fatalf("missing key: got %v, want NoSuchKey", err)
+	}
+
+	_, err = c.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("nope"), Key: aws.String("k")})
+	var nsb *types.NoSuchBucket
+	if !errors.As(err, &nsb) {
+		t.Fatalf("missing bucket: got %v, want NoSuchBucket", err)
+	}
+}
+
+func TestListObjectsV2(t *testing.T) {
+	ctx := context.Background()
+	c := New("b")
+	for _, k := range []string{"p/a", "p/b", "p/c/1", "p/c/2", "p/d", "q/x"} {
+		put(t, c, k, k)
+	}
+
+	out, err := c.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:    aws.String("b"),
+		Prefix:    aws.String("p/"),
+		Delimiter: aws.String("/"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys, prefixes []string
+	for _, obj := range out.Contents {
+		keys = append(keys, aws.ToString(obj.Key))
+	}
+	for _, p := range out.CommonPrefixes {
+		prefixes = append(prefixes, aws.ToString(p.Prefix))
+	}
+	if got := strings.Join(keys, ","); got != "p/a,p/b,p/d" {
+		t.Errorf("keys = %s", got)
+	}
+	if got := strings.Join(prefixes, ","); got != "p/c/" {
+		t.Errorf("common prefixes = %s", got)
+	}
+
+	var all []string
+	paginator := s3.NewListObjectsV2Paginator(c, &s3.ListObjectsV2Input{
+		Bucket:  aws.String("b"),
+		Prefix:  aws.String("p/"),
+		MaxKeys: aws.Int32(2),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Contents) > 2 {
+			t.Fatalf("page of %d keys exceeds MaxKeys", len(page.Contents))
+		}
+		for _, obj := range page.Contents {
+			all = append(all, aws.ToString(obj.Key))
+		}
+	}
+	if got := strings.Join(all, ","); got != "p/a,p/b,p/c/1,p/c/2,p/d" {
+		t.Errorf("paginated keys = %s", got)
+	}
+}
+
+func TestCopyObject(t *testing.T) {
+	ctx := context.Background()
+	c := New("a", "b")
+	put(t, c, "dir/k 1", "value")
+	_, err := c.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String("a"),
+		Key:        aws.String("copy"),
+		CopySource: aws.String("b/dir/k%201"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := c.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("a"), Key: aws.String("copy")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(out.Body); string(b) != "value" {
+		t.Fatalf("copied value = %q", b)
+	}
+	_, err = c.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:      aws.String("a"),
+		Key:         aws.String("copy"),
+		CopySource:  aws.String("b/dir/k%201"),
+		IfNoneMatch: aws.String("*"),
+	})
+	if statusOf(err) != http.StatusPreconditionFailed {
+		t.Fatalf("If-None-Match on existing key: got %v, want 412", err)
+	}
+}
+
+func TestVersioning(t *testing.T) {
+	ctx := context.Background()
+	c := New("b")
+	_, err := c.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket:                  aws.String("b"),
+		VersioningConfiguration: &types.VersioningConfiguration{Status: types.BucketVersioningStatusEnabled},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	put(t, c, "k", "one")
+	put(t, c, "k", "two")
+	if _, err := c.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("b"), Key: aws.String("k")}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := c.ListObjectVersions(ctx, &s3.ListObjectVersionsInput{Bucket: aws.String("b"), Prefix: aws.String("k")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Versions) != 2 || len(out.DeleteMarkers) != 1 || !aws.ToBool(out.DeleteMarkers[0].IsLatest) {
+		t.Fatalf("got %d versions and %d delete markers, want 2 and a latest delete marker",
+			len(out.Versions), len(out.DeleteMarkers))
+	}
+	first := out.Versions[len(out.Versions)-1].VersionId
+	got, err := c.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("b"), Key: aws.String("k"), VersionId: first})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(got.Body); string(b) != "one" {
+		t.Fatalf("first version = %q", b)
+	}
+}
+
+func TestDeleteObjectIfMatch(t *testing.T) {
+	ctx := context.Background()
+	c := New("b")
+	etag := put(t, c, "k", "one")
+
+	_, err := c.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("b"), Key: aws.String("k"), IfMatch: aws.String(`"stale"`)})
+	if statusOf(err) != http.StatusPreconditionFailed {
+		t.Fatalf("If-Match with wrong ETag: got %v, want 412", err)
+	}
+	if _, err := c.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("b"), Key: aws.String("k"), IfMatch: aws.String(etag)}); err != nil {
+		t.Fatalf("If-Match with current ETag: %v", err)
+	}
+	_, err = c.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("b"), Key: aws.String("k"), IfMatch: aws.String(etag)})
+	var nsk *types.NoSuchKey
+	if !errors.As(err, &nsk) {
+		t.Fatalf("If-Match on missing key: got %v, want NoSuchKey", err)
+	}
+	if _, err := c.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("b"), Key: aws.String("k")}); err != nil {
+		t.Fatalf("unconditional delete of missing key: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package s3store
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	cm "github.com/caddyserver/certmagic"
+)
+
+// KeyInfoExtended holds the information returned by Stat along with the
+// user-defined metadata stored on the object.
+type KeyInfoExtended struct {
+	cm.KeyInfo
+	Metadata map[string]string
+}
+
+// WithMetadata attaches the given user-defined metadata to every object
+// written by Store. S3 stores it as x-amz-meta-* headers, so keys should
+// be lower case.
+func WithMetadata(metadata map[string]string) Option {
+	return func(s *S3Store) {
+		if s.metadata == nil {
+			s.metadata = make(map[string]string, len(metadata))
+		}
+		for k, v := range metadata {
+			s.metadata[k] = v
+		}
+	}
+}
+
+// WithContentType sets the Content-Type header of stored objects,
+// e.g. "application/x-pem-file".
+func WithContentType(contentType string) Option {
+	return func(s *S3Store) {
+		s.contentType = contentType
+	}
+}
+
+// WithCacheControl sets the Cache-Control header of stored objects,
+// for buckets that are fronted by a CDN or caching proxy.
+func WithCacheControl(cacheControl string) Option {
+	return func(s *S3Store) {
+		s.cacheControl = cacheControl
+	}
+}
+
+// WithACL sets a canned ACL on every object the store writes,
+// including lock files and copies. Use
+// types.ObjectCannedACLBucketOwnerFullControl when writing to a bucket
+// owned by another account that doesn't enforce bucket owner ownership,
+// so that the bucket owner can read and manage the certificates.
+func WithACL(acl types.ObjectCannedACL) Option {
+	return func(s *S3Store) {
+		s.acl = acl
+	}
+}
+
+// StatExtended returns information about key, including its metadata.
+func (s *S3Store) StatExtended(ctx context.Context, key string) (KeyInfoExtended, error) {
+	input := &s3.HeadObjectInput{
+		Bucket: s.bucket,
+		Key:    aws.String(s.Filename(ctx, key)),
+	}
+	result, err := s.client.HeadObject(ctx, input)
+	if err != nil {
+		return KeyInfoExtended{}, s.opError("stat", key, err)
+	}
+
+	return KeyInfoExtended{
+		KeyInfo: cm.KeyInfo{
+			Key:        key,
+			Size:       aws.ToInt64(result.ContentLength),
+			Modified:   aws.ToTime(result.LastModified),
+			IsTerminal: true,
+		},
+		Metadata: result.Metadata,
+	}, nil
+}
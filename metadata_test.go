@@ -0,0 +1,94 @@
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+// recordingACLs records the canned ACL of every object written.
+type recordingACLs struct {
+	*memstore.Client
+	acls map[string]types.ObjectCannedACL
+}
+
+func (r *recordingACLs) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	r.acls[aws.ToString(params.Key)] = params.ACL
+	return r.Client.PutObject(ctx, params, optFns...)
+}
+
+func (r *recordingACLs) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	r.acls[aws.ToString(params.Key)] = params.ACL
+	return r.Client.CopyObject(ctx, params, optFns...)
+}
+
+func TestACL(t *testing.T) {
+	ctx := context.Background()
+	api := &recordingACLs{Client: memstore.New(testBucket), acls: make(map[string]types.ObjectCannedACL)}
+	s := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(api),
+		s3store.WithACL(types.ObjectCannedACLBucketOwnerFullControl))
+	mustStore(t, s, "k")
+	if err := s.Copy(ctx, "k", "copy"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Lock(ctx, "l"); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Unlock(ctx, "l")
+
+	if len(api.acls) != 4 {
+		t.Fatalf("objects written = %v, want the key, its copy, the lock file and its fencing token", api.acls)
+	}
+	for name, acl := range api.acls {
+		if acl != types.ObjectCannedACLBucketOwnerFullControl {
+			t.Errorf("%s written with ACL %q", name, acl)
+		}
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t,
+		s3store.WithMetadata(map[string]string{"owner": "edge", "env": "staging"}),
+		s3store.WithMetadata(map[string]string{"env": "production"}))
+	mustStore(t, s, "certificates/site.crt")
+
+	info, err := s.StatExtended(ctx, "certificates/site.crt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"owner": "edge", "env": "production"}; !reflect.DeepEqual(info.Metadata, want) {
+		t.Errorf("Metadata = %v, want %v", info.Metadata, want)
+	}
+	if info.Key != "certificates/site.crt" || info.Size != int64(len("certificates/site.crt")) ||
+		info.Modified.IsZero() || !info.IsTerminal {
+		t.Errorf("KeyInfo = %+v", info.KeyInfo)
+	}
+	if _, err := s.StatExtended(ctx, "missing"); !errors.Is(err, s3store.ErrNotFound) {
+		t.Fatalf("StatExtended of missing key = %v, want ErrNotFound", err)
+	}
+}
+
+func TestContentHeaders(t *testing.T) {
+	api := &recordingInputs{Client: memstore.New(testBucket), puts: make(map[string]*s3.PutObjectInput)}
+	s := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(api),
+		s3store.WithContentType("application/x-pem-file"), s3store.WithCacheControl("no-store"))
+	mustStore(t, s, "site.crt")
+	put := api.puts["certmagic/site.crt"]
+	if aws.ToString(put.ContentType) != "application/x-pem-file" || aws.ToString(put.CacheControl) != "no-store" {
+		t.Errorf("Content-Type = %q, Cache-Control = %q", aws.ToString(put.ContentType), aws.ToString(put.CacheControl))
+	}
+
+	plain := &recordingInputs{Client: memstore.New(testBucket), puts: make(map[string]*s3.PutObjectInput)}
+	mustStore(t, s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(plain)), "site.crt")
+	if put := plain.puts["certmagic/site.crt"]; put.ContentType != nil || put.CacheControl != nil {
+		t.Errorf("headers set without the options: Content-Type = %v, Cache-Control = %v", put.ContentType, put.CacheControl)
+	}
+}
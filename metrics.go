@@ -0,0 +1,166 @@
+package s3store
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// latencyBounds are the upper bounds of the latency histogram buckets,
+// spanning the range of S3 request latencies from cache-hot reads to
+// slow, retried writes.
+var latencyBounds = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// LatencyHistogram is the distribution of the latencies of one
+// operation, e.g. "load" or "lock".
+type LatencyHistogram struct {
+	Op string
+	// Bounds are the inclusive upper bounds of the buckets. Counts has
+	// one more element than Bounds, counting the calls slower than the
+	// last bound.
+	Bounds []time.Duration
+	Counts []uint64
+	// Count and Sum are the number of calls and their total latency.
+	Count uint64
+	Sum   time.Duration
+}
+
+// LatencyMetrics returns the latency histograms of the store's
+// operations (load, store, delete, exists, list, stat, lock and
+// unlock), including calls made through copies of the store such as
+// sub-stores, for exporting p99 latencies to dashboards.
+func (s *S3Store) LatencyMetrics() []LatencyHistogram {
+	return s.metrics.latencies()
+}
+
+// ErrorCategory classifies the failures of store operations.
+type ErrorCategory string
+
+const (
+	ErrorThrottled    ErrorCategory = "throttled"
+	ErrorAccessDenied ErrorCategory = "access_denied"
+	ErrorNotFound     ErrorCategory = "not_found"
+	// ErrorConflict covers failed preconditions: keys that already
+	// exist or were modified concurrently, and held locks.
+	ErrorConflict ErrorCategory = "conflict"
+	ErrorTimeout  ErrorCategory = "timeout"
+	// ErrorNetwork covers requests that couldn't be sent or whose
+	// response couldn't be read.
+	ErrorNetwork ErrorCategory = "network"
+	// ErrorCircuitOpen counts requests rejected by the circuit
+	// breaker without being sent.
+	ErrorCircuitOpen ErrorCategory = "circuit_open"
+	ErrorOther       ErrorCategory = "other"
+)
+
+// ClassifyError returns the category of an error returned by the store.
+func ClassifyError(err error) ErrorCategory {
+	var ne net.Error
+	var se *smithyhttp.RequestSendError
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		return ErrorCircuitOpen
+	case errors.Is(err, ErrThrottled):
+		return ErrorThrottled
+	case errors.Is(err, ErrAccessDenied):
+		return ErrorAccessDenied
+	case errors.Is(err, ErrNotFound):
+		return ErrorNotFound
+	case errors.Is(err, ErrExists), errors.Is(err, ErrModified), errors.Is(err, ErrLockHeld):
+		return ErrorConflict
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &ne) && ne.Timeout():
+		return ErrorTimeout
+	case errors.As(err, &se), errors.As(err, &ne):
+		return ErrorNetwork
+	}
+	return ErrorOther
+}
+
+// ErrorMetrics returns the number of failed operations per category,
+// including those made through copies of the store, so that alerts can
+// fire on e.g. a spike of access denied errors after an IAM change.
+func (s *S3Store) ErrorMetrics() map[ErrorCategory]uint64 {
+	return s.metrics.errorCounts()
+}
+
+// metrics collects the operation statistics of a store. It is shared
+// by copies of a store, such as sub-stores.
+type metrics struct {
+	mu      sync.Mutex
+	latency map[string]*LatencyHistogram
+	errors  map[ErrorCategory]uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		latency: make(map[string]*LatencyHistogram),
+		errors:  make(map[ErrorCategory]uint64),
+	}
+}
+
+// countError records a failed operation.
+func (m *metrics) countError(err error) {
+	category := ClassifyError(err)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors[category]++
+}
+
+func (m *metrics) errorCounts() map[ErrorCategory]uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts := make(map[ErrorCategory]uint64, len(m.errors))
+	for c, n := range m.errors {
+		counts[c] = n
+	}
+	return counts
+}
+
+// observe records a call to op that started at start. It is meant to
+// be deferred at the top of the operation.
+func (m *metrics) observe(op string, start time.Time) {
+	d := time.Since(start)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.latency[op]
+	if !ok {
+		h = &LatencyHistogram{
+			Op:     op,
+			Bounds: latencyBounds,
+			Counts: make([]uint64, len(latencyBounds)+1),
+		}
+		m.latency[op] = h
+	}
+	h.Counts[sort.Search(len(latencyBounds), func(i int) bool { return d <= latencyBounds[i] })]++
+	h.Count++
+	h.Sum += d
+}
+
+func (m *metrics) latencies() []LatencyHistogram {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hs := make([]LatencyHistogram, 0, len(m.latency))
+	for _, h := range m.latency {
+		c := *h
+		c.Counts = append([]uint64(nil), h.Counts...)
+		hs = append(hs, c)
+	}
+	sort.Slice(hs, func(i, j int) bool { return hs[i].Op < hs[j].Op })
+	return hs
+}
@@ -0,0 +1,83 @@
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+func TestLatencyMetrics(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	sub := s.SubStore("sub")
+	mustStore(t, s, "a", "b")
+	mustStore(t, sub, "c")
+	s.Load(ctx, "a")
+	s.Exists(ctx, "missing")
+
+	counts := make(map[string]uint64)
+	for _, h := range s.LatencyMetrics() {
+		if len(h.Counts) != len(h.Bounds)+1 {
+			t.Errorf("%s histogram has %d buckets for %d bounds", h.Op, len(h.Counts), len(h.Bounds))
+		}
+		var n uint64
+		for _, c := range h.Counts {
+			n += c
+		}
+		if n != h.Count {
+			t.Errorf("%s histogram buckets hold %d calls, want %d", h.Op, n, h.Count)
+		}
+		counts[h.Op] = h.Count
+	}
+	if counts["store"] != 3 || counts["load"] != 1 || counts["exists"] != 1 {
+		t.Fatalf("call counts = %v, want 3 stores including the sub-store's, 1 load and 1 exists", counts)
+	}
+}
+
+func TestErrorMetrics(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	mustStore(t, s, "k")
+	s.Load(ctx, "missing")
+	s.Load(ctx, "missing")
+	s.StoreIfNotExists(ctx, "k", nil)
+	locked := s.SubStore("sub")
+	if err := locked.Lock(ctx, "l"); err != nil {
+		t.Fatal(err)
+	}
+	locked.Unlock(ctx, "l")
+	locked.Unlock(ctx, "l")
+
+	want := map[s3store.ErrorCategory]uint64{
+		s3store.ErrorNotFound: 2,
+		s3store.ErrorConflict: 1,
+		s3store.ErrorOther:    1,
+	}
+	if got := s.ErrorMetrics(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ErrorMetrics = %v, want %v", got, want)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := map[error]s3store.ErrorCategory{
+		s3store.ErrCircuitOpen:                               s3store.ErrorCircuitOpen,
+		s3store.ErrThrottled:                                 s3store.ErrorThrottled,
+		s3store.ErrAccessDenied:                              s3store.ErrorAccessDenied,
+		s3store.ErrNotFound:                                  s3store.ErrorNotFound,
+		s3store.ErrModified:                                  s3store.ErrorConflict,
+		&s3store.DeadlockError{}:                             s3store.ErrorConflict,
+		context.DeadlineExceeded:                             s3store.ErrorTimeout,
+		&net.OpError{Op: "dial", Err: errors.New("refused")}: s3store.ErrorNetwork,
+		errors.New("boom"):                                   s3store.ErrorOther,
+	}
+	for err, want := range tests {
+		if got := s3store.ClassifyError(fmt.Errorf("wrapped: %w", err)); got != want {
+			t.Errorf("ClassifyError(%v) = %s, want %s", err, got, want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package s3store
+
+import (
+	"context"
+	"fmt"
+
+	cm "github.com/caddyserver/certmagic"
+)
+
+// MigrateOption configures Migrate.
+type MigrateOption func(*migrateConfig)
+
+type migrateConfig struct {
+	dryRun   bool
+	prefix   string
+	progress func(MigrateProgress)
+}
+
+// MigrateDryRun reports what Migrate would copy without writing anything.
+func MigrateDryRun() MigrateOption {
+	return func(c *migrateConfig) {
+		c.dryRun = true
+	}
+}
+
+// MigratePrefix limits Migrate to the keys under prefix.
+func MigratePrefix(prefix string) MigrateOption {
+	return func(c *migrateConfig) {
+		c.prefix = prefix
+	}
+}
+
+// MigrateProgressFunc registers fn to be called after each key is processed.
+func MigrateProgressFunc(fn func(MigrateProgress)) MigrateOption {
+	return func(c *migrateConfig) {
+		c.progress = fn
+	}
+}
+
+// MigrateProgress describes the key Migrate has just processed.
+type MigrateProgress struct {
+	Key     string
+	Done    int
+	Total   int
+	Skipped bool
+	Err     error
+}
+
+// MigrateResult summarizes a Migrate run.
+type MigrateResult struct {
+	Copied  []string
+	Skipped []string
+	Failed  map[string]error
+}
+
+// Migrate copies every key from src, for example a certmagic FileStorage,
+// to dst, preserving key paths. Keys that already exist in dst with the
+// same size and a modification time no older than the source are
+// skipped, so an interrupted migration can simply be run again to resume.
+// Failures of individual keys are collected in the result; an error is
+// only returned if src could not be listed.
+func Migrate(ctx context.Context, src cm.Storage, dst *S3Store, opts ...MigrateOption) (MigrateResult, error) {
+	var cfg migrateConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	result := MigrateResult{Failed: make(map[string]error)}
+
+	keys, err := src.List(ctx, cfg.prefix, true)
+	if err != nil {
+		return result, fmt.Errorf("listing source: %w", err)
+	}
+
+	for i, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		skipped, err := migrateKey(ctx, src, dst, key, cfg.dryRun)
+		switch {
+		case err != nil:
+			result.Failed[key] = err
+		case skipped:
+			result.Skipped = append(result.Skipped, key)
+		default:
+			result.Copied = append(result.Copied, key)
+		}
+		if cfg.progress != nil {
+			cfg.progress(MigrateProgress{
+				Key:     key,
+				Done:    i + 1,
+				Total:   len(keys),
+				Skipped: skipped,
+				Err:     err,
+			})
+		}
+	}
+	return result, nil
+}
+
+// migrateKey copies a single key, reporting whether it was skipped
+// because it is not a terminal key or is already up to date in dst.
+func migrateKey(ctx context.Context, src cm.Storage, dst *S3Store, key string, dryRun bool) (bool, error) {
+	info, err := src.Stat(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if !info.IsTerminal {
+		return true, nil
+	}
+	if existing, err := dst.StatExtended(ctx, key); err == nil &&
+		existing.Size == info.Size && !existing.Modified.Before(info.Modified) {
+		return true, nil
+	}
+	if dryRun {
+		return false, nil
+	}
+	value, err := src.Load(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	return false, dst.Store(ctx, key, value)
+}
@@ -0,0 +1,78 @@
+package s3store_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	src := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(failingGet{mem, "broken"}), s3store.WithPrefix("src"))
+	dst, _ := newTestStore(t)
+	mustStore(t, src, "a/1", "a/2", "b/1", "broken")
+
+	result, err := s3store.Migrate(ctx, src, dst, s3store.MigrateDryRun())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys, _ := dst.List(ctx, "", true); len(keys) != 0 {
+		t.Fatalf("dry run stored %q", keys)
+	}
+	if want := []string{"a/1", "a/2", "b/1", "broken"}; !reflect.DeepEqual(result.Copied, want) {
+		t.Fatalf("dry run would copy %q, want %q", result.Copied, want)
+	}
+
+	var progress []s3store.MigrateProgress
+	result, err = s3store.Migrate(ctx, src, dst, s3store.MigrateProgressFunc(func(p s3store.MigrateProgress) {
+		progress = append(progress, p)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a/1", "a/2", "b/1"}; !reflect.DeepEqual(result.Copied, want) {
+		t.Fatalf("copied %q, want %q", result.Copied, want)
+	}
+	if len(result.Failed) != 1 || result.Failed["broken"] == nil {
+		t.Fatalf("failed = %v, want the unreadable key", result.Failed)
+	}
+	for _, key := range result.Copied {
+		if v, err := dst.Load(ctx, key); err != nil || string(v) != key {
+			t.Errorf("copied %s = %q, %v", key, v, err)
+		}
+	}
+	if len(progress) != 4 {
+		t.Fatalf("progress reported %d times, want once per key", len(progress))
+	}
+	for i, p := range progress {
+		if p.Done != i+1 || p.Total != 4 || (p.Err != nil) != (p.Key == "broken") {
+			t.Errorf("progress %d = %+v", i, p)
+		}
+	}
+
+	// A second run resumes, copying only what changed.
+	if err := src.Store(ctx, "a/2", []byte("changed")); err != nil {
+		t.Fatal(err)
+	}
+	result, err = s3store.Migrate(ctx, src, dst, s3store.MigratePrefix("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(result.Skipped)
+	if !reflect.DeepEqual(result.Copied, []string{"a/2"}) || !reflect.DeepEqual(result.Skipped, []string{"a/1"}) {
+		t.Fatalf("resumed run copied %q and skipped %q", result.Copied, result.Skipped)
+	}
+	if v, _ := dst.Load(ctx, "a/2"); string(v) != "changed" {
+		t.Fatalf("a/2 = %q after the resumed run", v)
+	}
+
+	broken := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(failingList{mem}))
+	if _, err := s3store.Migrate(ctx, broken, dst); err == nil {
+		t.Fatal("Migrate from an unlistable source succeeded")
+	}
+}
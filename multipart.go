@@ -0,0 +1,39 @@
+package s3store
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// WithMultipartUpload stores values larger than partSize bytes with a
+// multipart upload through the S3 upload manager instead of a single
+// PutObject, uploading up to concurrency parts in parallel. partSize
+// must be at least 5 MiB.
+func WithMultipartUpload(partSize int64, concurrency int) Option {
+	return func(s *S3Store) {
+		s.partSize = partSize
+		s.uploadConcurrency = concurrency
+	}
+}
+
+// useMultipart reports whether a value of size bytes
+// should be stored with a multipart upload.
+func (s *S3Store) useMultipart(size int64) bool {
+	return s.partSize > 0 && size > s.partSize
+}
+
+// upload stores input using the upload manager.
+func (s *S3Store) upload(ctx context.Context, input *s3.PutObjectInput) error {
+	uploader := manager.NewUploader(s.client, func(u *manager.Uploader) {
+		if s.partSize > 0 {
+			u.PartSize = s.partSize
+		}
+		if s.uploadConcurrency > 0 {
+			u.Concurrency = s.uploadConcurrency
+		}
+	})
+	_, err := uploader.Upload(ctx, input)
+	return err
+}
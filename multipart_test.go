@@ -0,0 +1,87 @@
+package s3store_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+// multipartRecorder counts the requests of uploads,
+// failing every part numbered failPart.
+type multipartRecorder struct {
+	*memstore.Client
+	failPart int32
+
+	mu      sync.Mutex
+	puts    int
+	parts   int
+	aborted int
+}
+
+func (m *multipartRecorder) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	m.mu.Lock()
+	m.puts++
+	m.mu.Unlock()
+	return m.Client.PutObject(ctx, params, optFns...)
+}
+
+func (m *multipartRecorder) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	m.mu.Lock()
+	m.parts++
+	m.mu.Unlock()
+	if aws.ToInt32(params.PartNumber) == m.failPart {
+		return nil, errors.New("connection reset")
+	}
+	return m.Client.UploadPart(ctx, params, optFns...)
+}
+
+func (m *multipartRecorder) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	m.mu.Lock()
+	m.aborted++
+	m.mu.Unlock()
+	return m.Client.AbortMultipartUpload(ctx, params, optFns...)
+}
+
+func TestMultipartUpload(t *testing.T) {
+	ctx := context.Background()
+	const partSize = 5 << 20
+	api := &multipartRecorder{Client: memstore.New(testBucket)}
+	s := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(api), s3store.WithMultipartUpload(partSize, 2))
+
+	value := make([]byte, 2*partSize+1)
+	for i := range value {
+		value[i] = byte(i % 251)
+	}
+	if err := s.Store(ctx, "big", value); err != nil {
+		t.Fatal(err)
+	}
+	if api.parts != 3 || api.puts != 0 {
+		t.Fatalf("stored with %d parts and %d puts, want 3 parts", api.parts, api.puts)
+	}
+	if v, err := s.Load(ctx, "big"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Load of multipart object = %d bytes, %v, want the parts reassembled", len(v), err)
+	}
+
+	mustStore(t, s, "small")
+	if api.puts != 1 {
+		t.Fatalf("small value stored with %d puts, want a single PutObject", api.puts)
+	}
+
+	api.failPart = 2
+	if err := s.Store(ctx, "failed", value); err == nil {
+		t.Fatal("Store with a failing part succeeded")
+	}
+	if api.aborted != 1 {
+		t.Fatalf("failed upload aborted %d times, want once", api.aborted)
+	}
+	if s.Exists(ctx, "failed") {
+		t.Fatal("failed upload left an object")
+	}
+}
@@ -0,0 +1,67 @@
+package s3store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+// recordingInputs records the inputs of PutObject and CreateBucket.
+type recordingInputs struct {
+	*memstore.Client
+	puts    map[string]*s3.PutObjectInput
+	created []*s3.CreateBucketInput
+}
+
+func (r *recordingInputs) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	r.puts[aws.ToString(params.Key)] = params
+	return r.Client.PutObject(ctx, params, optFns...)
+}
+
+func (r *recordingInputs) CreateBucket(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+	r.created = append(r.created, params)
+	return r.Client.CreateBucket(ctx, params, optFns...)
+}
+
+func TestObjectLock(t *testing.T) {
+	ctx := context.Background()
+	api := &recordingInputs{Client: memstore.New(), puts: make(map[string]*s3.PutObjectInput)}
+	s := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(api), s3store.WithCreateBucket(),
+		s3store.WithObjectLock("archive/", types.ObjectLockModeGovernance, 24*time.Hour),
+		s3store.WithObjectLock("archive/root/", types.ObjectLockModeCompliance, 365*24*time.Hour))
+	if len(api.created) != 1 || !aws.ToBool(api.created[0].ObjectLockEnabledForBucket) {
+		t.Fatal("bucket created without Object Lock")
+	}
+
+	mustStore(t, s, "archive/a.crt", "archive/root/ca.crt", "certificates/b.crt")
+	tests := []struct {
+		key       string
+		mode      types.ObjectLockMode
+		retention time.Duration
+	}{
+		{"archive/a.crt", types.ObjectLockModeGovernance, 24 * time.Hour},
+		{"archive/root/ca.crt", types.ObjectLockModeCompliance, 365 * 24 * time.Hour},
+		{"certificates/b.crt", "", 0},
+	}
+	for _, tt := range tests {
+		in := api.puts[s.Filename(ctx, tt.key)]
+		if in.ObjectLockMode != tt.mode {
+			t.Errorf("%s stored in mode %q, want %q", tt.key, in.ObjectLockMode, tt.mode)
+		}
+		if tt.retention == 0 {
+			if in.ObjectLockRetainUntilDate != nil {
+				t.Errorf("%s stored with retention", tt.key)
+			}
+			continue
+		}
+		if until := time.Until(aws.ToTime(in.ObjectLockRetainUntilDate)); until > tt.retention || until < tt.retention-time.Minute {
+			t.Errorf("%s retained for %v, want %v", tt.key, until, tt.retention)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package s3store
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// Option configures an S3Store. Options are applied in order by the
+// constructors before the underlying s3 client is built.
+type Option func(*S3Store)
+
+// WithCredentialsProvider sets the credentials provider used to sign
+// requests, replacing the default credential chain. Any
+// aws.CredentialsProvider may be used, e.g. one backed by Vault or one
+// that rotates credentials.
+func WithCredentialsProvider(provider aws.CredentialsProvider) Option {
+	return func(s *S3Store) {
+		s.configOpts = append(s.configOpts, config.WithCredentialsProvider(provider))
+	}
+}
+
+// WithTransferAcceleration sends requests to the bucket's S3 Transfer
+// Acceleration endpoint. Acceleration must be enabled on the bucket.
+func WithTransferAcceleration() Option {
+	return func(s *S3Store) {
+		s.clientOpts = append(s.clientOpts, func(o *s3.Options) {
+			o.UseAccelerate = true
+		})
+	}
+}
+
+// WithDualStack resolves IPv6 capable dual-stack endpoints.
+func WithDualStack() Option {
+	return func(s *S3Store) {
+		s.clientOpts = append(s.clientOpts, func(o *s3.Options) {
+			o.EndpointOptions.UseDualStackEndpoint = aws.DualStackEndpointStateEnabled
+		})
+	}
+}
+
+// WithFIPS resolves FIPS 140-2 validated endpoints.
+func WithFIPS() Option {
+	return func(s *S3Store) {
+		s.clientOpts = append(s.clientOpts, func(o *s3.Options) {
+			o.EndpointOptions.UseFIPSEndpoint = aws.FIPSEndpointStateEnabled
+		})
+	}
+}
+
+// WithRequesterPays sends "x-amz-request-payer: requester" with every
+// request, which is required to access requester pays buckets. Like the
+// other client options it has no effect on a custom S3API.
+func WithRequesterPays() Option {
+	return func(s *S3Store) {
+		s.clientOpts = append(s.clientOpts, func(o *s3.Options) {
+			o.APIOptions = append(o.APIOptions,
+				smithyhttp.SetHeaderValue("X-Amz-Request-Payer", string(types.RequestPayerRequester)))
+		})
+	}
+}
+
+// WithPrefix sets the prefix under which all keys are stored.
+// It defaults to "certmagic". Leading and trailing slashes are ignored.
+func WithPrefix(prefix string) Option {
+	return func(s *S3Store) {
+		s.prefix = strings.Trim(prefix, "/")
+	}
+}
+
+// WithDryRun logs mutating operations (Store, Delete, Lock and Unlock,
+// their bulk variants and maintenance such as CleanStaleLocks and
+// PurgeTrash) instead of executing them. Reads still go to the bucket,
+// which makes it useful for validating migrations and configuration
+// against a production bucket.
+func WithDryRun() Option {
+	return func(s *S3Store) {
+		s.dryRun = true
+	}
+}
+
+// WithEndpoint sends requests to a custom endpoint URL instead of the
+// AWS endpoint resolved for the region, e.g. for S3 compatible services.
+func WithEndpoint(endpoint string) Option {
+	return func(s *S3Store) {
+		s.endpoint = endpoint
+		s.clientOpts = append(s.clientOpts, func(o *s3.Options) {
+			o.BaseEndpoint = aws.String(endpoint)
+		})
+	}
+}
+
+// WithPathStyle addresses the bucket in the request path
+// (https://host/bucket/key) instead of the host name.
+func WithPathStyle() Option {
+	return func(s *S3Store) {
+		s.clientOpts = append(s.clientOpts, func(o *s3.Options) {
+			o.UsePathStyle = true
+		})
+	}
+}
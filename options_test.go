@@ -0,0 +1,110 @@
+package s3store_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+func TestRequesterPays(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeS3(t)
+	s := s3store.NewS3StoreForTesting(f.URL, "certs", s3store.WithRequesterPays())
+
+	var mu sync.Mutex
+	var payers []string
+	f.handle = func(r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		payers = append(payers, r.Header.Get("X-Amz-Request-Payer"))
+	}
+	mustStore(t, s, "k")
+	if _, err := s.Load(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if len(payers) != 2 {
+		t.Fatalf("%d requests, want 2", len(payers))
+	}
+	for _, payer := range payers {
+		if payer != "requester" {
+			t.Fatalf("request sent with x-amz-request-payer %q", payer)
+		}
+	}
+}
+
+// hostRecorder answers every request with an empty
+// success, recording the host it was sent to.
+type hostRecorder struct {
+	mu    sync.Mutex
+	hosts []string
+}
+
+func (h *hostRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	h.mu.Lock()
+	h.hosts = append(h.hosts, req.URL.Host)
+	h.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Etag": {`"etag"`}},
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+// storeHost returns the host a store for the certs bucket in
+// us-east-1 given opts sends a Store to.
+func storeHost(t *testing.T, opts ...s3store.Option) string {
+	t.Helper()
+	h := &hostRecorder{}
+	cfg := aws.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("test", "test", ""),
+		HTTPClient:  &http.Client{Transport: h},
+	}
+	mustStore(t, s3store.NewS3StoreFromConfig(cfg, "certs", opts...), "k")
+	if len(h.hosts) != 1 {
+		t.Fatalf("Store sent %d requests, want 1", len(h.hosts))
+	}
+	return h.hosts[0]
+}
+
+func TestTransferAcceleration(t *testing.T) {
+	if !clientOptions(t, "certs", s3store.WithTransferAcceleration()).UseAccelerate {
+		t.Error("UseAccelerate not set")
+	}
+	if host := storeHost(t, s3store.WithTransferAcceleration()); host != "certs.s3-accelerate.amazonaws.com" {
+		t.Errorf("accelerated Store sent to %s", host)
+	}
+	if host := storeHost(t); host != "certs.s3.us-east-1.amazonaws.com" {
+		t.Errorf("Store sent to %s", host)
+	}
+}
+
+func TestDualStackAndFIPS(t *testing.T) {
+	tests := []struct {
+		opts []s3store.Option
+		host string
+	}{
+		{[]s3store.Option{s3store.WithDualStack()}, "certs.s3.dualstack.us-east-1.amazonaws.com"},
+		{[]s3store.Option{s3store.WithFIPS()}, "certs.s3-fips.us-east-1.amazonaws.com"},
+		{[]s3store.Option{s3store.WithDualStack(), s3store.WithFIPS()}, "certs.s3-fips.dualstack.us-east-1.amazonaws.com"},
+	}
+	for _, tt := range tests {
+		o := clientOptions(t, "certs", tt.opts...)
+		dualStack := o.EndpointOptions.UseDualStackEndpoint == aws.DualStackEndpointStateEnabled
+		fips := o.EndpointOptions.UseFIPSEndpoint == aws.FIPSEndpointStateEnabled
+		if dualStack != strings.Contains(tt.host, "dualstack") || fips != strings.Contains(tt.host, "fips") {
+			t.Errorf("EndpointOptions = %+v, want an endpoint like %s", o.EndpointOptions, tt.host)
+		}
+		if host := storeHost(t, tt.opts...); host != tt.host {
+			t.Errorf("Store sent to %s, want %s", host, tt.host)
+		}
+	}
+}
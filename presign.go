@@ -0,0 +1,69 @@
+package s3store
+
+import (
+	"context"
+	"errors"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// defaultPresignPatterns match the certificates certmagic
+// stores, but not their private keys.
+var defaultPresignPatterns = []string{"certificates/*/*/*.crt"}
+
+// WithPresignPatterns sets the keys PresignGet may produce URLs for to
+// those matching any of patterns, as interpreted by path.Match. By
+// default only certificates can be presigned, so that a private key is
+// never handed out by accident.
+func WithPresignPatterns(patterns ...string) Option {
+	return func(s *S3Store) {
+		s.presignPatterns = patterns
+	}
+}
+
+// PresignGet returns a URL that downloads the value at key without
+// credentials until ttl elapses, e.g. to hand a certificate to another
+// system. It returns ErrPresignDenied if key doesn't match the patterns
+// set by WithPresignPatterns. The URL is signed with the store's
+// credentials, so it stops working earlier if they expire or are
+// revoked, which for temporary credentials may be well before ttl.
+func (s *S3Store) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if !s.presignable(key) {
+		return "", s.kindError("presign", key, ErrPresignDenied, errors.New("key doesn't match the presign patterns"))
+	}
+	client := s.Client()
+	if client == nil {
+		return "", s.opError("presign", key, errors.New("presigning requires an s3 client, not a custom S3API"))
+	}
+	req, err := s3.NewPresignClient(client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: s.bucket,
+		Key:    aws.String(s.Filename(ctx, key)),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", s.opError("presign", key, err)
+	}
+	if !s.allowHTTP && !strings.HasPrefix(req.URL, "https://") {
+		return "", s.opError("presign", key, ErrInsecureTransport)
+	}
+	log.Printf("[INFO][%s] Presigned '%s' for %s", s, s.logKey(key), ttl)
+	return req.URL, nil
+}
+
+// presignable reports whether key may be presigned.
+func (s *S3Store) presignable(key string) bool {
+	patterns := s.presignPatterns
+	if patterns == nil {
+		patterns = defaultPresignPatterns
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
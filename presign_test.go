@@ -0,0 +1,57 @@
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+func TestPresignGet(t *testing.T) {
+	ctx := context.Background()
+	const crt, key = "certificates/acme/example.com/example.com.crt", "certificates/acme/example.com/example.com.key"
+	f := newFakeS3(t)
+	s := s3store.NewS3StoreForTesting(f.URL, "certs")
+	mustStore(t, s, crt, key)
+
+	u, err := s.PresignGet(ctx, crt, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(u, "X-Amz-Expires=3600") {
+		t.Errorf("URL %s doesn't expire after an hour", u)
+	}
+	resp, err := http.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if b, _ := io.ReadAll(resp.Body); string(b) != crt {
+		t.Fatalf("GET of presigned URL = %q, want the certificate", b)
+	}
+
+	if _, err := s.PresignGet(ctx, key, time.Hour); !errors.Is(err, s3store.ErrPresignDenied) {
+		t.Fatalf("PresignGet of private key = %v, want ErrPresignDenied", err)
+	}
+	s = s3store.NewS3StoreForTesting(f.URL, "certs", s3store.WithPresignPatterns("certificates/*/*/*.key"))
+	if _, err := s.PresignGet(ctx, key, time.Hour); err != nil {
+		t.Fatalf("PresignGet of key matching WithPresignPatterns: %v", err)
+	}
+	if _, err := s.PresignGet(ctx, crt, time.Hour); !errors.Is(err, s3store.ErrPresignDenied) {
+		t.Fatalf("PresignGet of key not matching WithPresignPatterns = %v, want ErrPresignDenied", err)
+	}
+
+	s = s3store.NewS3StoreWithCredentials("test", "test", "certs", "us-east-1", s3store.WithEndpoint(f.URL), s3store.WithPathStyle())
+	if _, err := s.PresignGet(ctx, crt, time.Hour); !errors.Is(err, s3store.ErrInsecureTransport) {
+		t.Fatalf("PresignGet of http:// URL = %v, want ErrInsecureTransport", err)
+	}
+	s, _ = newTestStore(t)
+	if _, err := s.PresignGet(ctx, crt, time.Hour); err == nil {
+		t.Fatal("PresignGet with a custom S3API succeeded")
+	}
+}
@@ -0,0 +1,213 @@
+package s3store
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// Provider identifies an S3 compatible service whose deviations from
+// AWS S3 the store adapts to, see WithProviderProfile.
+type Provider string
+
+const (
+	ProviderAWS Provider = "aws"
+	// ProviderR2 is Cloudflare R2. Use R2Endpoint for the endpoint.
+	ProviderR2 Provider = "r2"
+	// ProviderB2 is Backblaze B2. Use B2Endpoint for the endpoint.
+	ProviderB2 Provider = "b2"
+	// ProviderSpaces is DigitalOcean Spaces, see NewSpacesStore.
+	ProviderSpaces Provider = "spaces"
+)
+
+// providerProfile describes what a provider doesn't support
+// compared to AWS S3.
+type providerProfile struct {
+	// region, if set, is the only region the provider accepts
+	// signatures for.
+	region string
+	// checksumsWhenRequired stops the SDK from adding CRC checksums
+	// to requests that don't require them, which not every provider
+	// accepts.
+	checksumsWhenRequired bool
+	noTagging             bool
+	noObjectLock          bool
+	noKMS                 bool
+	noACLs                bool
+	// noConditionalWrites means If-None-Match and If-Match are
+	// ignored on PutObject, so the store has to check them itself.
+	noConditionalWrites bool
+}
+
+var providerProfiles = map[Provider]providerProfile{
+	ProviderAWS: {},
+	ProviderR2: {
+		region:                "auto",
+		checksumsWhenRequired: true,
+		noTagging:             true,
+		noObjectLock:          true,
+		noKMS:                 true,
+		noACLs:                true,
+	},
+	ProviderB2: {
+		checksumsWhenRequired: true,
+		noTagging:             true,
+		noKMS:                 true,
+		noConditionalWrites:   true,
+	},
+	// Spaces selects the datacenter by endpoint and expects requests
+	// to be signed for us-east-1.
+	ProviderSpaces: {
+		region:                "us-east-1",
+		checksumsWhenRequired: true,
+		noObjectLock:          true,
+		noKMS:                 true,
+	},
+}
+
+// R2Endpoint returns the S3 API endpoint of the Cloudflare account
+// accountID, for use with WithEndpoint.
+func R2Endpoint(accountID string) string {
+	return "https://" + accountID + ".r2.cloudflarestorage.com"
+}
+
+// B2Endpoint returns the S3 API endpoint of the Backblaze B2 region,
+// e.g. "us-west-004", for use with WithEndpoint.
+func B2Endpoint(region string) string {
+	return "https://s3." + region + ".backblazeb2.com"
+}
+
+// SpacesEndpoint returns the endpoint of the DigitalOcean Spaces
+// region, e.g. "nyc3", for use with WithEndpoint.
+func SpacesEndpoint(region string) string {
+	return "https://" + region + ".digitaloceanspaces.com"
+}
+
+// NewSpacesStore returns a store for the DigitalOcean Spaces bucket in
+// region, e.g. "nyc3", authenticating with the Spaces access key and
+// secret. It sets the region's endpoint and the ProviderSpaces profile;
+// opts are applied after them.
+func NewSpacesStore(region, bucketName, accessKey, secretKey string, opts ...Option) *S3Store {
+	return NewS3StoreWithCredentials(accessKey, secretKey, bucketName, providerProfiles[ProviderSpaces].region,
+		append([]Option{WithEndpoint(SpacesEndpoint(region)), WithProviderProfile(ProviderSpaces)}, opts...)...)
+}
+
+// WithProviderProfile adapts the store to the quirks of an S3
+// compatible provider. For ProviderR2 requests are signed for the
+// "auto" region, the SDK only sends checksums where R2 requires them,
+// and tagging, Object Lock retention, SSE-KMS and canned ACLs, which R2
+// doesn't implement, are disabled with a warning if configured, rather
+// than failing every write. ProviderB2 does the same for tagging and
+// SSE-KMS, and since B2 ignores conditional request headers on uploads,
+// create-only and compare-and-swap writes check their precondition with
+// a HEAD request first. That narrows, but doesn't close, the window in
+// which two writers both succeed, so Lock fails with ErrLockUnsafe on B2
+// unless WithUnsafeLocking is given. The endpoint still has to be set
+// with WithEndpoint.
+func WithProviderProfile(p Provider) Option {
+	return func(s *S3Store) {
+		s.provider = p
+		profile := providerProfiles[p]
+		s.clientOpts = append(s.clientOpts, func(o *s3.Options) {
+			if profile.region != "" {
+				o.Region = profile.region
+			}
+			if profile.checksumsWhenRequired {
+				o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+				o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+			}
+		})
+	}
+}
+
+// WithUnsafeLocking allows Lock on providers that ignore conditional
+// writes, such as ProviderB2, where lock files are created after
+// checking for them with a HEAD request. Two instances racing for a
+// lock can then both obtain it, so only use this when a single instance
+// uses the bucket at a time.
+func WithUnsafeLocking() Option {
+	return func(s *S3Store) {
+		s.unsafeLocking = true
+	}
+}
+
+// checkLocking returns ErrLockUnsafe if the store's provider can't
+// create lock files atomically and unsafe locking wasn't allowed.
+func (s *S3Store) checkLocking() error {
+	if providerProfiles[s.provider].noConditionalWrites && !s.unsafeLocking {
+		return ErrLockUnsafe
+	}
+	return nil
+}
+
+// applyProviderProfile disables the configured features the
+// store's provider doesn't support.
+func (s *S3Store) applyProviderProfile() {
+	profile, ok := providerProfiles[s.provider]
+	if !ok {
+		if s.provider != "" {
+			log.Printf("[WARNING][%s] Unknown provider profile %q", s, s.provider)
+		}
+		return
+	}
+	if profile.noTagging && (len(s.tags) > 0 || s.tagFunc != nil) {
+		log.Printf("[WARNING][%s] Object tagging is not supported by %s, disabling it", s, s.provider)
+		s.tags, s.tagFunc = nil, nil
+	}
+	if profile.noObjectLock && len(s.objectLock) > 0 {
+		log.Printf("[WARNING][%s] Object Lock is not supported by %s, disabling it", s, s.provider)
+		s.objectLock = nil
+	}
+	if profile.noKMS && (s.kmsKeyID != "" || len(s.tenantKMSKeys) > 0) {
+		log.Printf("[WARNING][%s] SSE-KMS is not supported by %s, disabling it", s, s.provider)
+		s.kmsKeyID, s.tenantKMSKeys = "", nil
+	}
+	if profile.noACLs && s.acl != "" {
+		log.Printf("[WARNING][%s] ACLs are not supported by %s, disabling them", s, s.provider)
+		s.acl = ""
+	}
+}
+
+// putObject uploads input. For providers that ignore conditional
+// headers, If-None-Match and If-Match are checked against the current
+// object first and a failed check is reported like S3 does.
+func (s *S3Store) putObject(ctx context.Context, input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	if !providerProfiles[s.provider].noConditionalWrites || (input.IfNoneMatch == nil && input.IfMatch == nil) {
+		return s.client.PutObject(ctx, input)
+	}
+	head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: input.Bucket,
+		Key:    input.Key,
+	})
+	if err != nil && !s.errNoSuchKey(err) {
+		return nil, err
+	}
+	exists := err == nil
+	if (input.IfNoneMatch != nil && exists) ||
+		(input.IfMatch != nil && (!exists || aws.ToString(head.ETag) != aws.ToString(input.IfMatch))) {
+		return nil, preconditionFailed()
+	}
+	unconditional := *input
+	unconditional.IfNoneMatch, unconditional.IfMatch = nil, nil
+	return s.client.PutObject(ctx, &unconditional)
+}
+
+// preconditionFailed returns the error S3 responds with to a
+// conditional write whose precondition doesn't hold.
+func preconditionFailed() error {
+	return &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{
+				StatusCode: http.StatusPreconditionFailed,
+				Status:     "412 Precondition Failed",
+			}},
+			Err: errors.New("precondition failed"),
+		},
+	}
+}
@@ -0,0 +1,182 @@
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+// b2 behaves like Backblaze B2, ignoring conditional
+// headers on uploads.
+type b2 struct {
+	*memstore.Client
+}
+
+func (b b2) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	unconditional := *params
+	unconditional.IfNoneMatch, unconditional.IfMatch = nil, nil
+	return b.Client.PutObject(ctx, &unconditional, optFns...)
+}
+
+func newB2Store(t *testing.T, mem *memstore.Client, opts ...s3store.Option) *s3store.S3Store {
+	t.Helper()
+	return s3store.NewS3Store(testBucket, "us-west-004",
+		append([]s3store.Option{s3store.WithS3API(b2{mem}), s3store.WithProviderProfile(s3store.ProviderB2)}, opts...)...)
+}
+
+func TestB2ConditionalWrites(t *testing.T) {
+	ctx := context.Background()
+	s := newB2Store(t, memstore.New(testBucket))
+	if err := s.StoreIfNotExists(ctx, "k", []byte("one")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.StoreIfNotExists(ctx, "k", []byte("two")); !errors.Is(err, s3store.ErrExists) {
+		t.Fatalf("StoreIfNotExists of existing key = %v, want ErrExists", err)
+	}
+	_, etag, err := s.LoadWithETag(ctx, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustStore(t, s, "k")
+	if err := s.StoreIfMatch(ctx, "k", []byte("three"), etag); !errors.Is(err, s3store.ErrModified) {
+		t.Fatalf("StoreIfMatch with stale ETag = %v, want ErrModified", err)
+	}
+}
+
+func TestB2LockingRefused(t *testing.T) {
+	ctx := context.Background()
+	s := newB2Store(t, memstore.New(testBucket))
+	if err := s.Lock(ctx, "k"); !errors.Is(err, s3store.ErrLockUnsafe) {
+		t.Fatalf("Lock on B2 = %v, want ErrLockUnsafe", err)
+	}
+	if locks, _ := s.Locks(ctx); len(locks) != 0 {
+		t.Fatalf("refused Lock left lock files %v", locks)
+	}
+}
+
+func TestB2UnsafeLocking(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	a := newB2Store(t, mem, s3store.WithUnsafeLocking())
+	b := newB2Store(t, mem, s3store.WithUnsafeLocking())
+	if err := a.Lock(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	tctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := b.Lock(tctx, "k"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Lock of held lock = %v, want context.DeadlineExceeded", err)
+	}
+	if err := a.Unlock(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestB2Listing(t *testing.T) {
+	ctx := context.Background()
+	s := newB2Store(t, memstore.New(testBucket))
+	mustStore(t, s, "certificates/a/a.crt", "certificates/b/b.crt")
+	keys, err := s.List(ctx, "certificates", false)
+	if err != nil || len(keys) != 2 || keys[0] != "certificates/a" || keys[1] != "certificates/b" {
+		t.Fatalf("List = %q, %v", keys, err)
+	}
+}
+
+// recordingPut records the ACL of every PutObject.
+type recordingPut struct {
+	*memstore.Client
+	acls []types.ObjectCannedACL
+}
+
+func (r *recordingPut) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	r.acls = append(r.acls, params.ACL)
+	return r.Client.PutObject(ctx, params, optFns...)
+}
+
+func TestR2DisablesACLs(t *testing.T) {
+	api := &recordingPut{Client: memstore.New(testBucket)}
+	s := s3store.NewS3Store(testBucket, "auto", s3store.WithS3API(api),
+		s3store.WithProviderProfile(s3store.ProviderR2), s3store.WithACL(types.ObjectCannedACLBucketOwnerFullControl))
+	mustStore(t, s, "k")
+	if len(api.acls) != 1 || api.acls[0] != "" {
+		t.Fatalf("ACLs sent to R2: %q", api.acls)
+	}
+
+	plain := &recordingPut{Client: memstore.New(testBucket)}
+	s = s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(plain), s3store.WithACL(types.ObjectCannedACLBucketOwnerFullControl))
+	mustStore(t, s, "k")
+	if len(plain.acls) != 1 || plain.acls[0] != types.ObjectCannedACLBucketOwnerFullControl {
+		t.Fatalf("ACLs sent to S3: %q", plain.acls)
+	}
+}
+
+func TestNewSpacesStore(t *testing.T) {
+	s := s3store.NewSpacesStore("nyc3", "certs", "key", "secret")
+	if got, want := s.String(), "S3Storage:certs/certmagic@https://nyc3.digitaloceanspaces.com"; got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+
+	f := newFakeS3(t)
+	f.buckets["certs"] = make(map[string][]byte)
+	var headers []http.Header
+	f.handle = func(r *http.Request) { headers = append(headers, r.Header.Clone()) }
+	s = s3store.NewSpacesStore("nyc3", "certs", "key", "secret",
+		s3store.WithEndpoint(f.URL), s3store.WithPathStyle(), s3store.WithAllowHTTP(), s3store.WithKMSKey("k"))
+	mustStore(t, s, "k")
+	if len(headers) != 1 {
+		t.Fatalf("%d requests, want 1", len(headers))
+	}
+	if auth := headers[0].Get("Authorization"); !strings.Contains(auth, "Credential=key/") || !strings.Contains(auth, "/us-east-1/s3/") {
+		t.Errorf("request signed with %q, want the Spaces key for us-east-1", auth)
+	}
+	if sse := headers[0].Get("X-Amz-Server-Side-Encryption"); sse != "" {
+		t.Errorf("SSE-KMS requested from Spaces: %s", sse)
+	}
+}
+
+func TestR2Endpoint(t *testing.T) {
+	tests := []struct {
+		accountID string
+		want      string
+	}{
+		{"023e105f4ecef8ad9ca31a8372d0c353", "https://023e105f4ecef8ad9ca31a8372d0c353.r2.cloudflarestorage.com"},
+		{"abc", "https://abc.r2.cloudflarestorage.com"},
+	}
+	for _, tt := range tests {
+		if got := s3store.R2Endpoint(tt.accountID); got != tt.want {
+			t.Errorf("R2Endpoint(%q) = %s, want %s", tt.accountID, got, tt.want)
+		}
+	}
+	host := storeHost(t, s3store.WithEndpoint(s3store.R2Endpoint("abc")), s3store.WithPathStyle())
+	if host != "abc.r2.cloudflarestorage.com" {
+		t.Errorf("Store sent to %s, want the R2 endpoint", host)
+	}
+}
+
+func TestB2Endpoint(t *testing.T) {
+	tests := []struct {
+		region string
+		want   string
+	}{
+		{"us-west-004", "https://s3.us-west-004.backblazeb2.com"},
+		{"us-east-005", "https://s3.us-east-005.backblazeb2.com"},
+		{"eu-central-003", "https://s3.eu-central-003.backblazeb2.com"},
+	}
+	for _, tt := range tests {
+		if got := s3store.B2Endpoint(tt.region); got != tt.want {
+			t.Errorf("B2Endpoint(%q) = %s, want %s", tt.region, got, tt.want)
+		}
+	}
+	host := storeHost(t, s3store.WithEndpoint(s3store.B2Endpoint("us-west-004")), s3store.WithPathStyle())
+	if host != "s3.us-west-004.backblazeb2.com" {
+		t.Errorf("Store sent to %s, want the B2 endpoint", host)
+	}
+}
@@ -0,0 +1,77 @@
+package s3store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"path"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// defaultRedactPatterns match the private keys certmagic stores:
+// certificate keys and ACME account keys.
+var defaultRedactPatterns = []string{
+	"certificates/*/*/*.key",
+	"acme/*/users/*/*.key",
+}
+
+// WithRedaction replaces key names matching any of patterns, as
+// interpreted by path.Match, with an opaque hash in the store's log
+// messages and errors, and removes object names from the request URLs
+// in errors returned by the s3 client. Without patterns the names of
+// certificate and ACME account private keys are redacted.
+func WithRedaction(patterns ...string) Option {
+	return func(s *S3Store) {
+		if len(patterns) == 0 {
+			patterns = defaultRedactPatterns
+		}
+		s.redactPatterns = patterns
+	}
+}
+
+// logKey returns key as it may appear in logs and errors.
+func (s *S3Store) logKey(key string) string {
+	for _, pattern := range s.redactPatterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			sum := sha256.Sum256([]byte(key))
+			return "[redacted:" + hex.EncodeToString(sum[:6]) + "]"
+		}
+	}
+	return key
+}
+
+// redactURLs is a client option that strips the query string, which
+// may carry a signature, from request URLs in errors. With redaction
+// enabled the path, which holds the object name, is removed as well.
+func (s *S3Store) redactURLs(o *s3.Options) {
+	o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("S3StoreRedactURLs", func(
+			ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler,
+		) (middleware.InitializeOutput, middleware.Metadata, error) {
+			out, md, err := next.HandleInitialize(ctx, in)
+			var ue *url.Error
+			if errors.As(err, &ue) {
+				ue.URL = s.redactURL(ue.URL)
+			}
+			return out, md, err
+		}), middleware.Before)
+	})
+}
+
+func (s *S3Store) redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "[redacted]"
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	if len(s.redactPatterns) > 0 {
+		u.Path = ""
+		u.RawPath = ""
+	}
+	return u.String()
+}
@@ -0,0 +1,35 @@
+package s3store_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+func TestRedactedErrors(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t, s3store.WithRedaction())
+	_, err := s.Load(ctx, "certificates/acme/a.com/a.com.key")
+	if err == nil || strings.Contains(err.Error(), "a.com.key") || !strings.Contains(err.Error(), "redacted") {
+		t.Errorf("Load error = %v, want the key redacted", err)
+	}
+	_, err = s.Load(ctx, "certificates/acme/a.com/a.com.crt")
+	if err == nil || !strings.Contains(err.Error(), "a.com.crt") {
+		t.Errorf("Load error = %v, want the certificate named", err)
+	}
+}
+
+func TestRedactedRequestURLs(t *testing.T) {
+	f := newFakeS3(t)
+	s := s3store.NewS3StoreForTesting(f.URL, "certs", s3store.WithRedaction())
+	f.Close()
+	_, err := s.Load(context.Background(), "certificates/acme/a.com/a.com.key")
+	if err == nil {
+		t.Fatal("Load from a stopped server succeeded")
+	}
+	if strings.Contains(err.Error(), "a.com.key") {
+		t.Fatalf("object name in error: %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package s3store
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Relocate server-side copies every object stored under s's prefix to
+// dst, which may use another prefix, bucket or both, e.g. to move from a
+// "caddy" prefix to "certmagic". Each copy is verified against the source
+// before it counts as copied. With removeSource set the originals are
+// deleted once all objects were copied successfully. dst's client must
+// be able to read from s's bucket. Copies are skipped if dst is in
+// dry-run mode and removals if s is.
+func (s *S3Store) Relocate(ctx context.Context, dst *S3Store, removeSource bool) (MigrateResult, error) {
+	result := MigrateResult{Failed: make(map[string]error)}
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: s.bucket,
+		Prefix: aws.String(s.prefix + "/"),
+	})
+	var copied []string
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return result, err
+		}
+		for _, obj := range page.Contents {
+			name := aws.ToString(obj.Key)
+			key := s.keyName(name)
+			if dst.skipDryRun("copy", key) {
+				result.Copied = append(result.Copied, key)
+				continue
+			}
+			err := dst.copyVerified(ctx, aws.ToString(s.bucket), name, key, aws.ToString(obj.ETag), aws.ToInt64(obj.Size))
+			if err != nil {
+				result.Failed[key] = err
+				continue
+			}
+			result.Copied = append(result.Copied, key)
+			copied = append(copied, name)
+		}
+	}
+	if !removeSource || len(result.Failed) > 0 || s.skipDryRun("delete", s.prefix) {
+		return result, nil
+	}
+	failed, err := s.deleteObjects(ctx, copied)
+	if err != nil {
+		return result, fmt.Errorf("removing source objects: %w", err)
+	}
+	for name, ferr := range failed {
+		result.Failed[s.keyName(name)] = ferr
+	}
+	return result, nil
+}
+
+// Move renames oldKey to newKey by server-side copying it, verifying
+// the copy against the original and then deleting the original, so the
+// value never passes through this process. An existing value at newKey
+// is overwritten, unless it is protected by WithWriteOnce, in which case
+// Move fails with ErrExists and leaves oldKey in place. If deleting
+// oldKey fails, the value is left at both keys and the error says so.
+func (s *S3Store) Move(ctx context.Context, oldKey, newKey string) error {
+	defer s.metrics.observe("move", time.Now())
+	if s.skipDryRun("move", oldKey) {
+		return nil
+	}
+	if err := s.copyKey(ctx, "move", s, oldKey, newKey); err != nil {
+		return err
+	}
+	err := s.deleteKey(ctx, oldKey)
+	s.mutated(ctx, "delete", err, oldKey)
+	if err != nil {
+		return fmt.Errorf("copied %s to %s but removing it failed: %w", s.logKey(oldKey), s.logKey(newKey), err)
+	}
+	return s.replicate(ctx, func(ctx context.Context, r *S3Store) error {
+		return r.Move(ctx, oldKey, newKey)
+	})
+}
+
+// Copy server-side copies the value at srcKey to dstKey, overwriting
+// any value there, and verifies the copy against the original. The
+// value never passes through this process.
+func (s *S3Store) Copy(ctx context.Context, srcKey, dstKey string) error {
+	defer s.metrics.observe("copy", time.Now())
+	if s.skipDryRun("copy", dstKey) {
+		return nil
+	}
+	if err := s.copyKey(ctx, "copy", s, srcKey, dstKey); err != nil {
+		return err
+	}
+	return s.replicate(ctx, func(ctx context.Context, r *S3Store) error {
+		return r.Copy(ctx, srcKey, dstKey)
+	})
+}
+
+// CopyTo is like Copy, but copies to dstKey in dst, which may use
+// another prefix, bucket or both. dst's client must be able to read
+// from s's bucket, and so must the client of dst's replica, if any.
+func (s *S3Store) CopyTo(ctx context.Context, dst *S3Store, srcKey, dstKey string) error {
+	defer dst.metrics.observe("copy", time.Now())
+	if dst.skipDryRun("copy", dstKey) {
+		return nil
+	}
+	if err := s.copyKey(ctx, "copy", dst, srcKey, dstKey); err != nil {
+		return err
+	}
+	return dst.replicate(ctx, func(ctx context.Context, r *S3Store) error {
+		return s.copyKey(ctx, "copy", r, srcKey, dstKey)
+	})
+}
+
+// copyKey server-side copies srcKey to dstKey in dst and verifies the
+// copy, reporting failures as op.
+func (s *S3Store) copyKey(ctx context.Context, op string, dst *S3Store, srcKey, dstKey string) error {
+	srcName := s.Filename(ctx, srcKey)
+	info, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: s.bucket,
+		Key:    aws.String(srcName),
+	})
+	if err != nil {
+		return s.opError(op, srcKey, err)
+	}
+	err = dst.copyVerified(ctx, aws.ToString(s.bucket), srcName, dstKey, aws.ToString(info.ETag), aws.ToInt64(info.ContentLength))
+	if isPreconditionFailed(err) && dst.writeOnce(ctx, dstKey) {
+		err = dst.kindError(op, dstKey, ErrExists, err)
+	}
+	if dst.cache != nil {
+		dst.cache.remove(dstKey)
+	}
+	dst.mutated(ctx, "store", err, dstKey)
+	return dst.opError(op, dstKey, err)
+}
+
+// copyVerified server-side copies srcName in srcBucket to key in s and
+// verifies the copy against the source's ETag and size.
+func (s *S3Store) copyVerified(ctx context.Context, srcBucket, srcName, key, etag string, size int64) error {
+	if err := s.copyObject(ctx, srcBucket, srcName, key); err != nil {
+		return err
+	}
+	return s.verifyCopy(ctx, key, etag, size)
+}
+
+// copyObject server-side copies srcName in srcBucket to key in s.
+// Like Store, it doesn't overwrite keys protected by WithWriteOnce.
+func (s *S3Store) copyObject(ctx context.Context, srcBucket, srcName, key string) error {
+	input := &s3.CopyObjectInput{
+		Bucket:     s.bucket,
+		Key:        aws.String(s.Filename(ctx, key)),
+		CopySource: aws.String(srcBucket + "/" + url.PathEscape(srcName)),
+		ACL:        s.acl,
+	}
+	if s.writeOnce(ctx, key) {
+		input.IfNoneMatch = aws.String("*")
+	}
+	input.ServerSideEncryption, input.SSEKMSKeyId = s.sseKMS()
+	_, err := s.client.CopyObject(ctx, input)
+	return err
+}
+
+// verifyCopy checks that key matches the size and, for objects not
+// uploaded in parts or encrypted with SSE-KMS, the ETag of the object it
+// was copied from.
+func (s *S3Store) verifyCopy(ctx context.Context, key, etag string, size int64) error {
+	info, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: s.bucket,
+		Key:    aws.String(s.Filename(ctx, key)),
+	})
+	if err != nil {
+		return fmt.Errorf("verifying copy: %w", err)
+	}
+	if got := aws.ToInt64(info.ContentLength); got != size {
+		return fmt.Errorf("verifying copy: size %d, expected %d", got, size)
+	}
+	if got := aws.ToString(info.ETag); s.kmsKeyID == "" && !strings.Contains(etag, "-") && got != etag {
+		return fmt.Errorf("verifying copy: etag %s, expected %s", got, etag)
+	}
+	return nil
+}
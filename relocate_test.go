@@ -0,0 +1,62 @@
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+func TestMove(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t, s3store.WithCache(10))
+	mustStore(t, s, "a/1")
+	if _, err := s.Load(ctx, "a/1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Move(ctx, "a/1", "b/1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load(ctx, "a/1"); !errors.Is(err, s3store.ErrNotFound) {
+		t.Fatalf("Load of moved key = %v, want ErrNotFound", err)
+	}
+	if v, err := s.Load(ctx, "b/1"); err != nil || string(v) != "a/1" {
+		t.Fatalf("Load of new key = %q, %v", v, err)
+	}
+	if err := s.Move(ctx, "missing", "b/2"); !errors.Is(err, s3store.ErrNotFound) {
+		t.Fatalf("Move of missing key = %v, want ErrNotFound", err)
+	}
+	if s.Exists(ctx, "b/2") {
+		t.Fatal("Move of missing key created the new key")
+	}
+}
+
+func TestCopy(t *testing.T) {
+	ctx := context.Background()
+	s, mem := newTestStore(t)
+	dst := newTestStoreOn(t, mem, s3store.WithPrefix("other"), s3store.WithCache(10))
+	mustStore(t, s, "a/1")
+	mustStore(t, dst, "x/1")
+	if _, err := dst.Load(ctx, "x/1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Copy(ctx, "a/1", "a/2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CopyTo(ctx, dst, "a/1", "x/1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		s   *s3store.S3Store
+		key string
+	}{{s, "a/1"}, {s, "a/2"}, {dst, "x/1"}} {
+		if v, err := c.s.Load(ctx, c.key); err != nil || string(v) != "a/1" {
+			t.Errorf("Load(%s) from %s = %q, %v, want the copied value", c.key, c.s.Prefix(), v, err)
+		}
+	}
+	if err := s.Copy(ctx, "missing", "a/3"); !errors.Is(err, s3store.ErrNotFound) {
+		t.Fatalf("Copy of missing key = %v, want ErrNotFound", err)
+	}
+}
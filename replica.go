@@ -0,0 +1,76 @@
+package s3store
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// WithReplica mirrors every Store and Delete to a second bucket, so
+// certificate data survives the loss of a bucket or region without
+// relying on S3 replication being set up. The replica is written with
+// the same client configuration and prefix as the primary bucket.
+// Replication is synchronous unless WithAsyncReplication is also given.
+func WithReplica(bucketName, region string) Option {
+	return func(s *S3Store) {
+		s.replicaBucket = bucketName
+		s.replicaRegion = region
+	}
+}
+
+// WithAsyncReplication mirrors writes to the replica in the background.
+// Failures are logged rather than returned to the caller.
+func WithAsyncReplication() Option {
+	return func(s *S3Store) {
+		s.asyncReplication = true
+	}
+}
+
+// derive returns a store for another bucket and region sharing s's
+// settings, for use as a replica or failover target. Stores using a
+// custom S3API share it with the derived store. The derived store has
+// no audit sink, publishers or callbacks, since mutations replicated to
+// it are already reported by s.
+func (s *S3Store) derive(bucketName, region string) *S3Store {
+	d := s.clone()
+	d.bucket = aws.String(bucketName)
+	if region != "" {
+		d.region = region
+	}
+	if client, ok := s.client.(*s3.Client); ok {
+		d.client = s3.New(client.Options(), func(o *s3.Options) {
+			o.Region = d.region
+		})
+	}
+	d.replica = nil
+	d.failover = nil
+	d.auditSink = nil
+	d.publishers = nil
+	d.callbacks = nil
+	d.cache = nil
+	d.metrics = newMetrics()
+	return d
+}
+
+// replicate applies op to the replica, if one is configured. In
+// asynchronous mode op runs in the background and errors are logged.
+func (s *S3Store) replicate(ctx context.Context, op func(ctx context.Context, r *S3Store) error) error {
+	if s.replica == nil {
+		return nil
+	}
+	if s.asyncReplication {
+		go func() {
+			if err := op(context.WithoutCancel(ctx), s.replica); err != nil {
+				log.Printf("[ERROR][%s] Replicating to %s: %v", s, aws.ToString(s.replica.bucket), err)
+			}
+		}()
+		return nil
+	}
+	if err := op(ctx, s.replica); err != nil {
+		return fmt.Errorf("replicating to %s: %w", aws.ToString(s.replica.bucket), err)
+	}
+	return nil
+}
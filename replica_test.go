@@ -0,0 +1,119 @@
+package s3store_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+// recordingSink is an AuditSink keeping the events it receives.
+type recordingSink struct {
+	mu     sync.Mutex
+	events []s3store.AuditEvent
+}
+
+func (r *recordingSink) Record(_ context.Context, event s3store.AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *recordingSink) ops() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ops []string
+	for _, e := range r.events {
+		ops = append(ops, e.Op+" "+e.Key)
+	}
+	return ops
+}
+
+func TestReplicaMirrorsWrites(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket, "replica")
+	sink := &recordingSink{}
+	var called int
+	s := newTestStoreOn(t, mem,
+		s3store.WithReplica("replica", ""),
+		s3store.WithAuditSink(sink),
+		s3store.WithCallback("*", func(context.Context, s3store.ChangeEvent) { called++ }),
+	)
+	replica := s3store.NewS3Store("replica", "us-east-1", s3store.WithS3API(mem))
+
+	mustStore(t, s, "k")
+	if v, err := replica.Load(ctx, "k"); err != nil || string(v) != "k" {
+		t.Fatalf("replica Load = %q, %v", v, err)
+	}
+	if err := s.Delete(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if replica.Exists(ctx, "k") {
+		t.Fatal("delete wasn't replicated")
+	}
+
+	// Replicated mutations are reported once, by the primary store.
+	if got := sink.ops(); len(got) != 2 || got[0] != "store k" || got[1] != "delete k" {
+		t.Errorf("audit events = %q, want one store and one delete", got)
+	}
+	if called != 2 {
+		t.Errorf("callback ran %d times, want 2", called)
+	}
+}
+
+// heldReplica holds every write to bucket until release is closed.
+type heldReplica struct {
+	*memstore.Client
+	bucket  string
+	release chan struct{}
+}
+
+func (h heldReplica) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if aws.ToString(params.Bucket) == h.bucket {
+		<-h.release
+	}
+	return h.Client.PutObject(ctx, params, optFns...)
+}
+
+func TestAsyncReplication(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket, "replica")
+	api := heldReplica{mem, "replica", make(chan struct{})}
+	s := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(api),
+		s3store.WithReplica("replica", ""), s3store.WithAsyncReplication())
+	replica := s3store.NewS3Store("replica", "us-east-1", s3store.WithS3API(mem))
+	eventually := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s not replicated", what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Store returns without waiting for the replica.
+	mustStore(t, s, "k")
+	if !s.Exists(ctx, "k") || replica.Exists(ctx, "k") {
+		t.Fatal("Store waited for the replica")
+	}
+	close(api.release)
+	eventually("store", func() bool { return replica.Exists(ctx, "k") })
+	if err := s.Delete(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	eventually("delete", func() bool { return !replica.Exists(ctx, "k") })
+
+	// Replication failures are logged rather than returned.
+	s = newTestStoreOn(t, memstore.New(testBucket), s3store.WithReplica("missing", ""), s3store.WithAsyncReplication())
+	if err := s.Store(ctx, "k", []byte("v")); err != nil {
+		t.Fatalf("Store with an unreachable replica: %v", err)
+	}
+}
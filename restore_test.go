@@ -0,0 +1,60 @@
+package s3store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+func TestRestoreFromTrash(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t, s3store.WithSoftDelete(time.Hour))
+	mustStore(t, s, "a/k")
+	if err := s.Delete(ctx, "a/k"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Restore(ctx, "a/k"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := s.Load(ctx, "a/k"); err != nil || string(v) != "a/k" {
+		t.Fatalf("Load after Restore = %q, %v", v, err)
+	}
+	if trashed, _ := s.List(ctx, "trash", true); len(trashed) != 0 {
+		t.Fatalf("restored copy left in the trash: %q", trashed)
+	}
+	if err := s.Restore(ctx, "a/k"); err == nil {
+		t.Fatal("second Restore succeeded without a trashed copy or prior version")
+	}
+}
+
+func TestRestoreFromVersions(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStoreOn(t, memstore.New(), s3store.WithCreateBucket())
+	for _, v := range []string{"one", "two"} {
+		if err := s.Store(ctx, "k", []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An overwrite is undone by restoring the preceding version...
+	if err := s.Restore(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := s.Load(ctx, "k"); err != nil || string(v) != "one" {
+		t.Fatalf("Load after restoring an overwrite = %q, %v", v, err)
+	}
+
+	// ...and a deletion by restoring the version before the delete marker.
+	if err := s.Delete(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Restore(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := s.Load(ctx, "k"); err != nil || string(v) != "one" {
+		t.Fatalf("Load after restoring a deletion = %q, %v", v, err)
+	}
+}
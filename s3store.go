@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
-	"path/filepath"
+	"net/http"
+	"path"
+	"sort"
 	"strings"
 	"time"
 
@@ -19,11 +21,9 @@ import (
 	cm "github.com/caddyserver/certmagic"
 )
 
-const lockFileExists = "Lock file for already exists"
-
 // staleLockDuration is the length of time
 // before considering a lock to be stale.
-const staleLockDuration = 2 * time.Hour
+var staleLockDuration = 2 * time.Hour
 
 // fileLockPollInterval is how frequently
 // to check the existence of a lock file
@@ -40,181 +40,671 @@ var StorageKeys cm.KeyBuilder
 // and is safe for concurrent use
 
 type S3Store struct {
-	prefix string
-	bucket *string
-	client *s3.Client
+	prefix   string
+	bucket   *string
+	region   string
+	endpoint string
+	provider Provider
+	client   S3API
+
+	tags     map[string]string
+	tagFunc  func(key string) map[string]string
+	metadata map[string]string
+
+	contentType  string
+	cacheControl string
+	acl          types.ObjectCannedACL
+
+	cache      *cache
+	revalidate bool
+	serveStale bool
+
+	partSize          int64
+	uploadConcurrency int
+
+	checksums  bool
+	contentMD5 bool
+
+	replica          *S3Store
+	replicaBucket    string
+	replicaRegion    string
+	asyncReplication bool
+
+	failover       *failover
+	failoverBucket string
+	failoverRegion string
+	breaker        *circuitBreaker
+
+	createBucket bool
+	dryRun       bool
+
+	objectLock     []objectLockRule
+	storageClasses []storageClassRule
+	shardDepth     int
+	legacyKeys     bool
+	keyEncoder     KeyEncoder
+
+	listConcurrency int
+	inventoryBucket string
+	inventoryPrefix string
+
+	kmsKeyID      string
+	tenantKMSKeys map[string]string
+
+	redactPatterns    []string
+	writeOncePatterns []string
+	presignPatterns   []string
+
+	softDelete     bool
+	trashRetention time.Duration
+
+	auditSink AuditSink
+	identity  *identity
+	lockState *lockState
+	metrics   *metrics
+
+	stealPolicy   StealPolicy
+	unsafeLocking bool
+	publishers    []Publisher
+	callbacks     []callback
+
+	configOpts    []func(*config.LoadOptions) error
+	clientOpts    []func(*s3.Options)
+	transportOpts []func(*http.Transport)
+
+	insecureSkipVerify bool
+	allowHTTP          bool
 }
 
-func NewS3Store(bucketName, region string) *S3Store {
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithRegion(region),
-	)
+func NewS3Store(bucketName, region string, opts ...Option) *S3Store {
+	store, err := buildS3Store(bucketName, region, opts)
 	if err != nil {
 		log.Fatal(err)
 	}
-	client := s3.NewFromConfig(cfg)
-	store := &S3Store{
-		bucket: aws.String(bucketName),
-		client: client,
-		prefix: "certmagic",
+	return store
+}
+
+// buildS3Store is NewS3Store, returning an error if the
+// configuration can't be loaded or the bucket can't be created.
+func buildS3Store(bucketName, region string, opts []Option) (*S3Store, error) {
+	store := newS3Store(bucketName, opts)
+	store.region = region
+	if store.client == nil {
+		cfg, err := config.LoadDefaultConfig(context.TODO(),
+			append([]func(*config.LoadOptions) error{config.WithRegion(region)}, store.configOpts...)...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		store.client = s3.NewFromConfig(cfg, store.clientOpts...)
+	}
+	if err := store.setup(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// NewS3StoreFromConfig builds the s3 client from an existing aws.Config
+// instead of loading the default configuration.
+func NewS3StoreFromConfig(cfg aws.Config, bucketName string, opts ...Option) *S3Store {
+	store := newS3Store(bucketName, opts)
+	store.region = cfg.Region
+	if store.client == nil {
+		store.client = s3.NewFromConfig(cfg, store.clientOpts...)
+	}
+	if err := store.setup(); err != nil {
+		log.Fatal(err)
 	}
 
 	return store
 }
 
-func NewS3StoreWithCredentials(accessKey, secretKey, bucketName, region string) *S3Store {
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKey, secretKey, "")),
-		config.WithRegion(region),
-	)
-	if err != nil {
+// NewS3StoreFromClient uses an already constructed s3 client, so that
+// applications which tune their client (middleware, tracing, endpoints)
+// can share it with the store. Options that configure how the
+// configuration is loaded have no effect here; client options are applied
+// to a copy of the client.
+func NewS3StoreFromClient(client *s3.Client, bucketName string, opts ...Option) *S3Store {
+	store := newS3Store(bucketName, opts)
+	store.region = client.Options().Region
+	if store.client == nil {
+		store.client = client
+		if len(store.clientOpts) > 0 {
+			store.client = s3.New(client.Options(), store.clientOpts...)
+		}
+	}
+	if err := store.setup(); err != nil {
 		log.Fatal(err)
 	}
-	client := s3.NewFromConfig(cfg)
+
+	return store
+}
+
+func newS3Store(bucketName string, opts []Option) *S3Store {
 	store := &S3Store{
-		bucket: aws.String(bucketName),
-		client: client,
-		prefix: "certmagic",
+		bucket:      aws.String(bucketName),
+		prefix:      "certmagic",
+		identity:    &identity{},
+		lockState:   newLockState(),
+		metrics:     newMetrics(),
+		stealPolicy: StealStale,
 	}
-
+	for _, opt := range opts {
+		opt(store)
+	}
+	store.applyProviderProfile()
+	switch {
+	case isMultiRegionAccessPoint(bucketName):
+		store.clientOpts = append(store.clientOpts, useMultiRegionAccessPoint)
+	case isAccessPoint(bucketName):
+		store.clientOpts = append(store.clientOpts, useAccessPoint)
+	}
+	if store.insecureSkipVerify {
+		log.Printf("[WARNING][%s] TLS certificate verification is disabled, connections to S3 can be intercepted. Never use this in production!", store)
+	}
+	if len(store.transportOpts) > 0 {
+		store.clientOpts = append(store.clientOpts, store.useTransportOptions)
+	}
+	if !store.allowHTTP {
+		store.clientOpts = append(store.clientOpts, store.requireTLS)
+	}
+	store.clientOpts = append(store.clientOpts, store.redactURLs)
 	return store
 }
 
+// setup finishes configuring store once its client has been built.
+func (s *S3Store) setup() error {
+	if s.createBucket {
+		if err := s.EnsureBucket(context.TODO()); err != nil {
+			return err
+		}
+	}
+	if s.replicaBucket != "" {
+		s.replica = s.derive(s.replicaBucket, s.replicaRegion)
+	}
+	if s.failoverBucket != "" {
+		s.failover = &failover{secondary: s.derive(s.failoverBucket, s.failoverRegion)}
+	}
+	// The circuit breaker is added last, so that the replica and
+	// failover clients derived above are not guarded by it.
+	if client, ok := s.client.(*s3.Client); ok && s.breaker != nil {
+		s.client = s3.New(client.Options(), s.useCircuitBreaker)
+	}
+	return nil
+}
+
+// skipDryRun logs op on keys and reports true if the store is in
+// dry-run mode, in which case the caller must not perform op.
+func (s *S3Store) skipDryRun(op string, keys ...string) bool {
+	if s.dryRun {
+		logKeys := make([]string, len(keys))
+		for i, key := range keys {
+			logKeys[i] = s.logKey(key)
+		}
+		log.Printf("[INFO][%s] Dry run: skipping %s of '%s'", s, op, strings.Join(logKeys, "', '"))
+	}
+	return s.dryRun
+}
+
+// clone returns a shallow copy of s.
+func (s *S3Store) clone() *S3Store {
+	c := *s
+	return &c
+}
+
+// SubStore returns a store sharing s's client and settings whose keys
+// are stored under prefix within s's prefix, for running several Caddy
+// instances or environments against one bucket. Replica and failover
+// targets are nested the same way, and the sub-store gets its own cache.
+func (s *S3Store) SubStore(prefix string) *S3Store {
+	n := s.clone()
+	n.prefix = path.Join(s.prefix, prefix)
+	if s.cache != nil {
+		n.cache = newCache(s.cache.size)
+	}
+	if s.replica != nil {
+		n.replica = s.replica.SubStore(prefix)
+	}
+	if s.failover != nil {
+		n.failover = &failover{secondary: s.failover.secondary.SubStore(prefix)}
+	}
+	return n
+}
+
+func NewS3StoreWithCredentials(accessKey, secretKey, bucketName, region string, opts ...Option) *S3Store {
+	provider := credentials.NewStaticCredentialsProvider(accessKey, secretKey, "")
+	return NewS3Store(bucketName, region, append([]Option{WithCredentialsProvider(provider)}, opts...)...)
+}
+
 // Exists returns true if key exists in s3
 func (s *S3Store) Exists(ctx context.Context, key string) bool {
+	defer s.metrics.observe("exists", time.Now())
+	var exists bool
+	err := s.withFailover(ctx, func(st *S3Store) (err error) {
+		exists, err = st.exists(ctx, key)
+		return err
+	})
+	if err != nil {
+		return true
+	}
+	return exists
+}
+
+// exists reports whether key exists in s3. Errors other than
+// NoSuchKey are returned so that callers can decide how to treat them.
+func (s *S3Store) exists(ctx context.Context, key string) (bool, error) {
+	if s.cache != nil && !s.revalidate {
+		if _, ok := s.cache.get(key); ok {
+			return true, nil
+		}
+	}
 	input := &s3.GetObjectInput{
 		Bucket: s.bucket,
 		Key:    aws.String(s.Filename(ctx, key)),
 	}
-	_, err := s.client.GetObject(ctx, input)
+	_, err := s.getObject(ctx, input)
 	if err == nil {
-		return true
+		return true, nil
 	}
 	var nsk *types.NoSuchKey
-	return !errors.As(err, &nsk)
+	if errors.As(err, &nsk) {
+		return false, nil
+	}
+	return false, err
 }
 
 // Store saves value at key.
-func (s *S3Store) Store(ctx context.Context, key string, value []byte) error {
-	filename := s.Filename(ctx, key)
-	input := &s3.PutObjectInput{
-		Bucket: s.bucket,
-		Key:    aws.String(filename),
-		Body:   bytes.NewReader(value),
+func (s *S3Store) Store(ctx context.Context, key string, value []byte) (err error) {
+	if s.skipDryRun("store", key) {
+		return nil
+	}
+	defer func() { s.mutated(ctx, "store", err, key) }()
+	return s.put(ctx, key, value, s.putObjectInput(ctx, key, value))
+}
+
+// StoreIfNotExists saves value at key only if key doesn't exist yet,
+// returning ErrExists otherwise. The check is made atomically by S3
+// with If-None-Match, so of several concurrent callers only one
+// succeeds.
+func (s *S3Store) StoreIfNotExists(ctx context.Context, key string, value []byte) (err error) {
+	if s.skipDryRun("store", key) {
+		return nil
+	}
+	defer func() { s.mutated(ctx, "store", err, key) }()
+	input := s.putObjectInput(ctx, key, value)
+	input.IfNoneMatch = aws.String("*")
+	return s.put(ctx, key, value, input)
+}
+
+// put uploads value to key as described by input, then
+// replicates it.
+func (s *S3Store) put(ctx context.Context, key string, value []byte, input *s3.PutObjectInput) (err error) {
+	defer s.metrics.observe("store", time.Now())
+	if s.useMultipart(int64(len(value))) {
+		err = s.upload(ctx, input)
+	} else {
+		_, err = s.putObject(ctx, input)
+	}
+	switch {
+	case !isPreconditionFailed(err):
+	case input.IfNoneMatch != nil:
+		err = s.kindError("store", key, ErrExists, err)
+	case input.IfMatch != nil:
+		err = s.kindError("store", key, ErrModified, err)
+	}
+	if s.cache != nil {
+		s.cache.remove(key)
 	}
-	_, err := s.client.PutObject(ctx, input)
 
 	if err != nil {
-		return err
+		return s.opError("store", key, err)
 	}
-	return nil
+	return s.replicate(ctx, func(ctx context.Context, r *S3Store) error {
+		return r.Store(ctx, key, value)
+	})
+}
+
+// putObjectInput builds the PutObjectInput used to store value at key,
+// including any tags, metadata and headers configured on the store.
+func (s *S3Store) putObjectInput(ctx context.Context, key string, value []byte) *s3.PutObjectInput {
+	input := &s3.PutObjectInput{
+		Bucket:   s.bucket,
+		Key:      aws.String(s.Filename(ctx, key)),
+		Body:     bytes.NewReader(value),
+		Metadata: s.metadata,
+		ACL:      s.acl,
+	}
+	if tagging := s.tagging(key); tagging != "" {
+		input.Tagging = aws.String(tagging)
+	}
+	if s.contentType != "" {
+		input.ContentType = aws.String(s.contentType)
+	}
+	if s.cacheControl != "" {
+		input.CacheControl = aws.String(s.cacheControl)
+	}
+	if s.checksums {
+		s.addChecksum(input, value)
+	}
+	s.addObjectLock(input, key)
+	s.addStorageClass(input, key)
+	s.addWriteOnce(ctx, input, key)
+	input.ServerSideEncryption, input.SSEKMSKeyId = s.sseKMS()
+	if s.contentMD5 && value != nil && !s.useMultipart(int64(len(value))) {
+		input.ContentMD5 = aws.String(md5Checksum(value))
+	}
+	return input
 }
 
 // Load retrieves the value at key.
 func (s *S3Store) Load(ctx context.Context, key string) ([]byte, error) {
+	defer s.metrics.observe("load", time.Now())
+	var e cacheEntry
+	err := s.withFailover(ctx, func(st *S3Store) (err error) {
+		e, err = st.load(ctx, key)
+		return err
+	})
+	return e.value, s.opError("load", key, err)
+}
+
+// LoadWithInfo retrieves the value at key together with information
+// about it, saving the round trip of a Load followed by a Stat.
+func (s *S3Store) LoadWithInfo(ctx context.Context, key string) ([]byte, cm.KeyInfo, error) {
+	defer s.metrics.observe("load", time.Now())
+	var e cacheEntry
+	err := s.withFailover(ctx, func(st *S3Store) (err error) {
+		e, err = st.load(ctx, key)
+		return err
+	})
+	if err != nil {
+		return nil, cm.KeyInfo{}, s.opError("load", key, err)
+	}
+	return e.value, cm.KeyInfo{
+		Key:        key,
+		Modified:   e.modified,
+		Size:       int64(len(e.value)),
+		IsTerminal: true,
+	}, nil
+}
+
+// load retrieves the value at key along with its ETag.
+func (s *S3Store) load(ctx context.Context, key string) (cacheEntry, error) {
+	var cached cacheEntry
+	var hit bool
+	var gen uint64
+	if s.cache != nil {
+		gen = s.cache.generation()
+		cached, hit = s.cache.get(key)
+		if hit && !s.revalidate {
+			return cached, nil
+		}
+	}
 	input := &s3.GetObjectInput{
 		Bucket: s.bucket,
 		Key:    aws.String(s.Filename(ctx, key)),
 	}
-	result, err := s.client.GetObject(ctx, input)
+	if hit && cached.etag != "" {
+		input.IfNoneMatch = aws.String(cached.etag)
+	}
+	result, err := s.getObject(ctx, input)
 	if err != nil {
-		return nil, err
+		if hit && (isNotModified(err) || isCircuitOpen(err)) {
+			return cached, nil
+		}
+		if hit && s.serveStale && isRetryable(ctx, err) {
+			log.Printf("[WARNING][%s] Serving cached %s, revalidation failed: %v", s, s.logKey(key), err)
+			return cached, nil
+		}
+		return cacheEntry{}, err
 	}
+	defer result.Body.Close()
 
 	b, err := ioutil.ReadAll(result.Body)
 	if err != nil {
-		return nil, err
+		return cacheEntry{}, err
+	}
+	if s.checksums {
+		if err := verifyChecksum(key, b, result.ChecksumSHA256); err != nil {
+			return cacheEntry{}, err
+		}
 	}
-	return b, nil
+	entry := cacheEntry{
+		key:      key,
+		value:    b,
+		etag:     aws.ToString(result.ETag),
+		modified: aws.ToTime(result.LastModified),
+	}
+	if s.cache != nil {
+		s.cache.add(entry, gen)
+	}
+	return entry, nil
 }
 
 // Delete deletes the value at key.
-func (s *S3Store) Delete(ctx context.Context, key string) error {
-	input := &s3.DeleteObjectInput{
-		Bucket: s.bucket,
-		Key:    aws.String(s.Filename(ctx, key)),
+func (s *S3Store) Delete(ctx context.Context, key string) (err error) {
+	if s.skipDryRun("delete", key) {
+		return nil
 	}
-	_, err := s.client.DeleteObject(ctx, input)
-	if err != nil {
+	defer s.metrics.observe("delete", time.Now())
+	defer func() { s.mutated(ctx, "delete", err, key) }()
+	if err := s.deleteKey(ctx, key); err != nil {
 		return err
 	}
-	return nil
+	return s.replicate(ctx, func(ctx context.Context, r *S3Store) error {
+		return r.Delete(ctx, key)
+	})
 }
 
-// List returns all keys that match prefix.
-// because s3 has no concept of directories, everything is an explicit path,
-// there is really no such thing as recursive search. This is simply
-// here to fulfill the interface requirements of the List function
+// deleteKey deletes key from the store itself, moving it to the trash
+// first with soft deletes enabled.
+func (s *S3Store) deleteKey(ctx context.Context, key string) error {
+	names := s.objectNames(s.Filename(ctx, key))
+	if s.softDelete {
+		failed := s.moveToTrash(ctx, names)
+		for _, name := range names {
+			if err := failed[name]; err != nil {
+				return fmt.Errorf("moving %s to trash: %w", s.logKey(key), err)
+			}
+		}
+	}
+	var err error
+	for _, name := range names {
+		input := &s3.DeleteObjectInput{
+			Bucket: s.bucket,
+			Key:    aws.String(name),
+		}
+		if _, err = s.client.DeleteObject(ctx, input); err != nil {
+			break
+		}
+	}
+	if s.cache != nil {
+		s.cache.remove(key)
+	}
+	return s.opError("delete", key, err)
+}
+
+// List returns the keys under prefix, relative to the store's prefix
+// like every other key. If recursive is false only the immediate
+// children of prefix are returned, including "directories" that only
+// contain other keys. The audit log is only listed if prefix lies
+// within its directory.
 func (s *S3Store) List(ctx context.Context, prefix string, recursive bool) ([]string, error) {
+	defer s.metrics.observe("list", time.Now())
 	var keys []string
-	prefixPath := s.Filename(ctx, prefix)
-	input := &s3.ListObjectsInput{
-		Bucket: s.bucket,
-		Prefix: aws.String(prefixPath),
-	}
+	err := s.withFailover(ctx, func(st *S3Store) (err error) {
+		keys, err = st.list(ctx, prefix, recursive, nil)
+		return err
+	})
+	return keys, s.opError("list", prefix, err)
+}
 
-	result, err := s.client.ListObjects(ctx, input)
-	if err != nil {
-		return nil, err
+// ListModifiedSince returns the keys under prefix, recursively, whose
+// objects were last modified after t. Sync tools and maintenance jobs
+// can use it to process only what changed since their last run.
+func (s *S3Store) ListModifiedSince(ctx context.Context, prefix string, t time.Time) ([]string, error) {
+	var keys []string
+	err := s.withFailover(ctx, func(st *S3Store) (err error) {
+		keys, err = st.list(ctx, prefix, true, func(obj types.Object) bool {
+			return aws.ToTime(obj.LastModified).After(t)
+		})
+		return err
+	})
+	return keys, s.opError("list", prefix, err)
+}
+
+// list lists the keys under prefix, skipping objects for which keep,
+// if not nil, returns false.
+func (s *S3Store) list(ctx context.Context, prefix string, recursive bool, keep func(obj types.Object) bool) ([]string, error) {
+	var keys []string
+	seen := make(map[string]bool)
+	prefix = strings.Trim(prefix, "/")
+	namePrefix := s.Filename(ctx, prefix)
+	if namePrefix != "" {
+		namePrefix += "/"
 	}
-	for _, k := range result.Contents {
-		if strings.HasPrefix(*k.Key, prefix) {
-			keys = append(keys, *k.Key)
+	err := s.walkNames(ctx, namePrefix, func(obj types.Object) error {
+		if keep != nil && !keep(obj) {
+			return nil
+		}
+		key := s.keyName(aws.ToString(obj.Key))
+		if auditLogged(key, prefix) {
+			return nil
+		}
+		rel := key
+		if prefix != "" {
+			if !strings.HasPrefix(key, prefix+"/") {
+				return nil
+			}
+			rel = strings.TrimPrefix(key, prefix+"/")
+		}
+		if !recursive {
+			if i := strings.Index(rel, "/"); i >= 0 {
+				rel = rel[:i]
+			}
+			key = path.Join(prefix, rel)
+		}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
 		}
+		return nil
+	})
+	if s.listConcurrency > 1 {
+		sort.Strings(keys)
 	}
-	//
-	return keys, nil
+	return keys, err
 }
 
-// Stat returns information about key.
-func (s *S3Store) Stat(ctx context.Context, key string) (cm.KeyInfo, error) {
-	input := &s3.GetObjectInput{
+// eachObject calls fn for every object stored under prefix,
+// fetching the listing one page at a time.
+func (s *S3Store) eachObject(ctx context.Context, prefix string, fn func(obj types.Object) error) error {
+	return s.eachObjectNamed(ctx, s.Filename(ctx, prefix), fn)
+}
+
+// eachObjectNamed calls fn for every object whose name starts with
+// namePrefix, which is used as is rather than as a key.
+func (s *S3Store) eachObjectNamed(ctx context.Context, namePrefix string, fn func(obj types.Object) error) error {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
 		Bucket: s.bucket,
-		Key:    aws.String(key),
+		Prefix: aws.String(namePrefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, obj := range page.Contents {
+			if err := fn(obj); err != nil {
+				return err
+			}
+		}
 	}
-	result, err := s.client.GetObject(ctx, input)
+	return nil
+}
 
+// Stat returns information about key.
+func (s *S3Store) Stat(ctx context.Context, key string) (cm.KeyInfo, error) {
+	defer s.metrics.observe("stat", time.Now())
+	info, err := s.statObject(ctx, s.Filename(ctx, key))
 	if err != nil {
-		return cm.KeyInfo{}, err
+		return cm.KeyInfo{}, s.opError("stat", key, err)
 	}
-
-	return cm.KeyInfo{
-		Key:        key,
-		Size:       result.ContentLength,
-		Modified:   *result.LastModified,
-		IsTerminal: true,
-	}, nil
+	info.Key = key
+	return info, nil
 }
 
-// Filename returns the key as a path on the file
-// system prefixed by S3Storage.Path.
+// Filename returns the object name for key: the key under the
+// store's prefix, always separated by "/" regardless of platform.
 func (s *S3Store) Filename(_ context.Context, key string) string {
-	return filepath.Join(s.prefix, filepath.FromSlash(key))
+	return path.Join(s.prefix, s.shardKey(s.encodeKey(key)))
+}
+
+// keyName is the inverse of Filename, returning the storage key
+// for the object named name.
+func (s *S3Store) keyName(name string) string {
+	if s.legacyKeys {
+		name = strings.ReplaceAll(name, `\`, "/")
+	}
+	return s.decodeKey(s.unshardKey(strings.TrimPrefix(name, s.prefix+"/")))
 }
 
 // Lock obtains a lock named by the given key. It blocks
 // until the lock can be obtained or an error is returned.
 func (s *S3Store) Lock(ctx context.Context, key string) error {
+	_, err := s.LockWithToken(ctx, key)
+	return err
+}
+
+// LockWithToken is like Lock, but also returns the lock's fencing token.
+// Tokens increase with every acquisition of the lock, so systems acted
+// upon while holding it can reject requests carrying an older token than
+// the latest they have seen, or check it with CheckFencingToken, to guard
+// against holders whose lock went stale and was taken over.
+func (s *S3Store) LockWithToken(ctx context.Context, key string) (token uint64, err error) {
+	defer s.metrics.observe("lock", time.Now())
+	if s.skipDryRun("lock", key) {
+		return 0, nil
+	}
+	defer func() { s.mutated(ctx, "lock", err, key) }()
+	if err := s.checkLocking(); err != nil {
+		return 0, s.opError("lock", key, err)
+	}
 	start := time.Now()
 	lockFile := s.lockFileName(key)
+	done := s.lockState.wait(key, lockFile)
+	defer func() { done(err == nil) }()
+	steal := stealCheck{policy: s.stealPolicy}
 
 	for {
 		err := s.createLockFile(ctx, lockFile)
+		if err == nil && s.legacyLockHeld(ctx, lockFile) {
+			// an earlier version holds the lock under its
+			// legacy name; back off and try again
+			s.deleteLockFile(lockFile)
+			select {
+			case <-time.After(fileLockPollInterval):
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+			continue
+		}
 		if err == nil {
 			// got the lock, yay
-			return nil
+			token, err := s.fenceLock(ctx, key, lockFile)
+			if err == nil {
+				s.lockState.acquired(s, key, lockFile, token)
+			}
+			return token, err
 		}
 
-		if err.Error() != lockFileExists {
+		if !errors.Is(err, ErrLockHeld) {
 			// unexpected error
-			fmt.Println(err)
-			return fmt.Errorf("creating lock file: %+v", err)
-
+			return 0, s.opError("lock", key, fmt.Errorf("creating lock file: %w", err))
 		}
 
 		// lock file already exists
 
-		info, err := s.Stat(ctx, lockFile)
+		rec, err := s.readLockRecord(ctx, lockFile)
 		switch {
 		case s.errNoSuchKey(err):
 			// must have just been removed; try again to create it
@@ -222,66 +712,198 @@ func (s *S3Store) Lock(ctx context.Context, key string) error {
 
 		case err != nil:
 			// unexpected error
-			return fmt.Errorf("accessing lock file: %v", err)
+			return 0, s.opError("lock", key, fmt.Errorf("accessing lock file: %w", err))
+
+		case rec.stale() && s.stealPolicy.never:
+			return 0, s.opError("lock", key, fmt.Errorf("stealing is disabled: %w", ErrLockStale))
 
-		case s.fileLockIsStale(info):
+		case rec.stale() && steal.observe(rec.etag):
 			log.Printf("[INFO][%s] Lock for '%s' is stale; removing then retrying: %s",
 				s, key, lockFile)
-			s.deleteLockFile(lockFile)
+			// Only remove the lock file seen stale, so that of several
+			// waiters stealing it none removes the lock another just
+			// created; if it changed, simply try again.
+			_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+				Bucket:  s.bucket,
+				Key:     aws.String(lockFile),
+				IfMatch: aws.String(rec.etag),
+			})
+			if err != nil && !isPreconditionFailed(err) && !s.errNoSuchKey(err) {
+				return 0, s.opError("lock", key, fmt.Errorf("removing stale lock file: %w", err))
+			}
+			if err == nil {
+				s.lockState.stolen(key, lockFile)
+			}
 			continue
 
-		case time.Since(start) > staleLockDuration*2:
+		case time.Since(start) > deadlockThreshold:
 			// should never happen, hopefully
-			return fmt.Errorf("possible deadlock: %s passed trying to obtain lock for %s",
-				time.Since(start), key)
+			return 0, s.deadlock(key, lockFile, start, rec)
 
 		default:
 			// lockfile exists and is not stale;
 			// just wait a moment and try again
-			time.Sleep(fileLockPollInterval)
+			select {
+			case <-time.After(fileLockPollInterval):
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		}
+	}
+}
+
+// Unlock releases the lock for key, which must have been obtained
+// through this store. It returns ErrLockNotHeld if it wasn't, and
+// ErrLockStale without removing the lock if the lock went stale and was
+// taken over by someone else in the meantime.
+func (s *S3Store) Unlock(ctx context.Context, key string) (err error) {
+	defer s.metrics.observe("unlock", time.Now())
+	if s.skipDryRun("unlock", key) {
+		return nil
+	}
+	defer func() { s.mutated(ctx, "unlock", err, key) }()
+	lockFile := s.lockFileName(key)
+	h, ok := s.lockState.holding(lockFile)
+	if !ok {
+		return s.opError("unlock", key, ErrLockNotHeld)
+	}
+	if err := s.checkLockToken(ctx, lockFile, h.token); err != nil {
+		s.lockState.released(lockFile)
+		return s.opError("unlock", key, err)
+	}
+	if err := s.deleteLockFile(lockFile); err != nil {
+		return err
+	}
+	s.lockState.released(lockFile)
+	return nil
+}
 
+// ForceUnlock removes the lock for key whoever holds it, for operators
+// clearing a lock left behind by a crashed instance.
+func (s *S3Store) ForceUnlock(ctx context.Context, key string) (err error) {
+	if s.skipDryRun("unlock", key) {
+		return nil
+	}
+	defer func() { s.mutated(ctx, "unlock", err, key) }()
+	lockFile := s.lockFileName(key)
+	if err := s.deleteLockFile(lockFile); err != nil {
+		return err
+	}
+	s.lockState.released(lockFile)
+	return nil
+}
+
+// RenewLocks refreshes the locks this store holds that are more than
+// halfway to going stale, so that operations outlasting the stale lock
+// duration don't lose them to other instances. It is meant to be called
+// periodically. Locks that were already taken over are dropped and
+// reported with ErrLockStale.
+func (s *S3Store) RenewLocks(ctx context.Context) error {
+	var errs []error
+	for _, h := range s.lockState.heldLocks() {
+		if time.Until(h.expires) > staleLockDuration/2 {
+			continue
+		}
+		lockFile := h.store.lockFileName(h.key)
+		if err := h.store.renewLock(ctx, lockFile, h.token); err != nil {
+			errs = append(errs, h.store.opError("renew lock", h.key, err))
 		}
 	}
+	return errors.Join(errs...)
 }
 
-// Unlock releases the lock for name.
-func (s *S3Store) Unlock(_ context.Context, key string) error {
-	return s.deleteLockFile(s.lockFileName(key))
+// Locks returns information about every lock file currently present,
+// keyed by the escaped lock name, which can be passed to ForceUnlock to
+// clear a lock left behind by a crashed instance.
+func (s *S3Store) Locks(ctx context.Context) ([]cm.KeyInfo, error) {
+	var locks []cm.KeyInfo
+	err := s.eachObjectNamed(ctx, s.lockDir()+"/", func(obj types.Object) error {
+		name, ok := strings.CutSuffix(path.Base(aws.ToString(obj.Key)), ".lock")
+		if !ok {
+			return nil
+		}
+		locks = append(locks, cm.KeyInfo{
+			Key:        name,
+			Size:       aws.ToInt64(obj.Size),
+			Modified:   aws.ToTime(obj.LastModified),
+			IsTerminal: true,
+		})
+		return nil
+	})
+	return locks, err
+}
+
+// Client returns the s3 client the store issues its requests with, for
+// supplementary operations such as presigning or bucket policy checks
+// against the same configuration. It returns nil if the store was given
+// a custom S3API with WithS3API; use API in that case.
+func (s *S3Store) Client() *s3.Client {
+	client, _ := s.client.(*s3.Client)
+	return client
 }
 
+// API returns the S3API the store issues its requests through.
+func (s *S3Store) API() S3API {
+	return s.client
+}
+
+// Bucket returns the name or access point ARN of the store's bucket.
+func (s *S3Store) Bucket() string {
+	return aws.ToString(s.bucket)
+}
+
+// Prefix returns the prefix under which the store's keys are stored.
+func (s *S3Store) Prefix() string {
+	return s.prefix
+}
+
+// Region returns the region the store was configured with, which is
+// empty if it was left to the SDK's default configuration.
+func (s *S3Store) Region() string {
+	return s.region
+}
+
+// String identifies the store by bucket and prefix, and by endpoint
+// when a custom one is used.
 func (s *S3Store) String() string {
-	return "S3Storage:" + s.prefix
+	str := "S3Storage:" + path.Join(aws.ToString(s.bucket), s.prefix)
+	if s.endpoint != "" {
+		str += "@" + s.endpoint
+	}
+	return str
 }
 
 func (s *S3Store) lockFileName(key string) string {
-	return filepath.Join(s.lockDir(), StorageKeys.Safe(key)+".lock")
+	return path.Join(s.lockDir(), StorageKeys.Safe(key)+".lock")
 }
 
 func (s *S3Store) lockDir() string {
-	return filepath.Join(s.prefix, "locks")
-}
-
-func (s *S3Store) fileLockIsStale(info cm.KeyInfo) bool {
-	return time.Since(info.Modified) > staleLockDuration
+	return path.Join(s.prefix, "locks")
 }
 
 func (s *S3Store) createLockFile(ctx context.Context, filename string) error {
-	//lf := s.lockFileName(key)
-	exists := s.Exists(ctx, filename)
-	if exists {
-		return fmt.Errorf(lockFileExists)
+	err := s.writeLockFile(ctx, filename, s.newLockInfo(0), "*")
+	if isPreconditionFailed(err) {
+		return ErrLockHeld
 	}
-	input := &s3.PutObjectInput{
+	return err
+}
+
+// statName returns information about the object with the given name.
+func (s *S3Store) statName(ctx context.Context, filename string) (cm.KeyInfo, error) {
+	result, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: s.bucket,
 		Key:    aws.String(filename),
-		Body:   bytes.NewReader([]byte("lock")),
-	}
-	_, err := s.client.PutObject(context.Background(), input)
-
+	})
 	if err != nil {
-		return err
+		return cm.KeyInfo{}, err
 	}
-	return nil
+	return cm.KeyInfo{
+		Key:        filename,
+		Size:       aws.ToInt64(result.ContentLength),
+		Modified:   aws.ToTime(result.LastModified),
+		IsTerminal: true,
+	}, nil
 }
 
 func (s *S3Store) deleteLockFile(keyPath string) error {
@@ -298,8 +920,9 @@ func (s *S3Store) deleteLockFile(keyPath string) error {
 
 func (s *S3Store) errNoSuchKey(err error) bool {
 	var nsk *types.NoSuchKey
+	var nf *types.NotFound
 	if err != nil {
-		if errors.As(err, &nsk) {
+		if errors.As(err, &nsk) || errors.As(err, &nf) {
 			return true
 		}
 	}
@@ -1 +1,303 @@
-package s3store
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+const testBucket = "test-bucket"
+
+// newTestStore returns a store backed by a fresh in-memory bucket,
+// along with the fake client for inspecting the bucket directly.
+func newTestStore(t *testing.T, opts ...s3store.Option) (*s3store.S3Store, *memstore.Client) {
+	t.Helper()
+	mem := memstore.New(testBucket)
+	return newTestStoreOn(t, mem, opts...), mem
+}
+
+// newTestStoreOn returns a store for testBucket in mem.
+func newTestStoreOn(t *testing.T, mem *memstore.Client, opts ...s3store.Option) *s3store.S3Store {
+	t.Helper()
+	return s3store.NewS3Store(testBucket, "us-east-1", append([]s3store.Option{s3store.WithS3API(mem)}, opts...)...)
+}
+
+// mustStore stores each key with its own name as value.
+func mustStore(t *testing.T, s *s3store.S3Store, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		if err := s.Store(context.Background(), key, []byte(key)); err != nil {
+			t.Fatalf("storing %s: %v", key, err)
+		}
+	}
+}
+
+func TestStoreLoadDelete(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+
+	if err := s.Store(ctx, "a/b", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Exists(ctx, "a/b") {
+		t.Fatal("stored key doesn't exist")
+	}
+	v, err := s.Load(ctx, "a/b")
+	if err != nil || string(v) != "value" {
+		t.Fatalf("Load = %q, %v", v, err)
+	}
+	info, err := s.Stat(ctx, "a/b")
+	if err != nil || info.Key != "a/b" || info.Size != 5 || !info.IsTerminal {
+		t.Fatalf("Stat = %+v, %v", info, err)
+	}
+	if err := s.Delete(ctx, "a/b"); err != nil {
+		t.Fatal(err)
+	}
+	if s.Exists(ctx, "a/b") {
+		t.Fatal("deleted key still exists")
+	}
+	if _, err := s.Load(ctx, "a/b"); !errors.Is(err, fs.ErrNotExist) || !errors.Is(err, s3store.ErrNotFound) {
+		t.Fatalf("Load of deleted key = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLockUnlock(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	a := newTestStoreOn(t, mem)
+	b := newTestStoreOn(t, mem)
+
+	if err := a.Lock(ctx, "issue/example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A second instance can't take the lock while it is held, and gives
+	// up once its context expires.
+	tctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := b.Lock(tctx, "issue/example.com"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Lock of held lock = %v, want context.DeadlineExceeded", err)
+	}
+	if err := b.Unlock(ctx, "issue/example.com"); !errors.Is(err, s3store.ErrLockNotHeld) {
+		t.Fatalf("Unlock by non-holder = %v, want ErrLockNotHeld", err)
+	}
+
+	if err := a.Unlock(ctx, "issue/example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Lock(ctx, "issue/example.com"); err != nil {
+		t.Fatalf("Lock after release: %v", err)
+	}
+	if err := b.Unlock(ctx, "issue/example.com"); err != nil {
+		t.Fatal(err)
+	}
+	locks, err := a.Locks(ctx)
+	if err != nil || len(locks) != 0 {
+		t.Fatalf("Locks after unlocking = %v, %v", locks, err)
+	}
+}
+
+func TestLockBlocksUntilReleased(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	a := newTestStoreOn(t, mem)
+	b := newTestStoreOn(t, mem)
+	if err := a.Lock(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	acquired := make(chan error, 1)
+	go func() { acquired <- b.Lock(ctx, "k") }()
+	select {
+	case err := <-acquired:
+		t.Fatalf("Lock returned %v while the lock was held", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := a.Unlock(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Lock didn't acquire the released lock")
+	}
+}
+
+func TestStoreIfNotExists(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	if err := s.StoreIfNotExists(ctx, "k", []byte("one")); err != nil {
+		t.Fatal(err)
+	}
+	err := s.StoreIfNotExists(ctx, "k", []byte("two"))
+	if !errors.Is(err, s3store.ErrExists) || !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("second StoreIfNotExists = %v, want ErrExists", err)
+	}
+	if v, _ := s.Load(ctx, "k"); string(v) != "one" {
+		t.Fatalf("value = %q, want the first write", v)
+	}
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	mustStore(t, s, "certificates/acme/a.com/a.com.crt", "certificates/acme/a.com/a.com.key",
+		"certificates/acme/b.com/b.com.crt", "acme/users/x", "top")
+
+	tests := []struct {
+		prefix    string
+		recursive bool
+		want      []string
+	}{
+		{"", false, []string{"acme", "certificates", "top"}},
+		{"certificates/acme", false, []string{"certificates/acme/a.com", "certificates/acme/b.com"}},
+		{"certificates/acme/", true, []string{
+			"certificates/acme/a.com/a.com.crt",
+			"certificates/acme/a.com/a.com.key",
+			"certificates/acme/b.com/b.com.crt",
+		}},
+		{"certificates/acme/a", true, nil},
+		{"missing", true, nil},
+	}
+	for _, tt := range tests {
+		got, err := s.List(ctx, tt.prefix, tt.recursive)
+		if err != nil {
+			t.Fatalf("List(%q, %v): %v", tt.prefix, tt.recursive, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("List(%q, %v) = %q, want %q", tt.prefix, tt.recursive, got, tt.want)
+		}
+	}
+}
+
+func TestStoreIfMatch(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	mustStore(t, s, "k")
+	_, etag, err := s.LoadWithETag(ctx, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.StoreIfMatch(ctx, "k", []byte("two"), etag); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.StoreIfMatch(ctx, "k", []byte("three"), etag); !errors.Is(err, s3store.ErrModified) {
+		t.Fatalf("StoreIfMatch with stale ETag = %v, want ErrModified", err)
+	}
+	if v, _ := s.Load(ctx, "k"); string(v) != "two" {
+		t.Fatalf("value = %q, want %q", v, "two")
+	}
+}
+
+func TestString(t *testing.T) {
+	s, _ := newTestStore(t, s3store.WithPrefix("caddy/prod"))
+	if got, want := s.String(), "S3Storage:test-bucket/caddy/prod"; got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+	s, _ = newTestStore(t, s3store.WithEndpoint("https://minio.internal:9000"))
+	if got, want := s.String(), "S3Storage:test-bucket/certmagic@https://minio.internal:9000"; got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+	_, err := s.Load(context.Background(), "a/b")
+	if err == nil || !strings.Contains(err.Error(), "load test-bucket/a/b") {
+		t.Errorf("Load error = %v, want the bucket and key", err)
+	}
+}
+
+func TestLockIsExclusive(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	var held atomic.Int32
+	var wg sync.WaitGroup
+	for range 8 {
+		s := newTestStoreOn(t, mem)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+			defer cancel()
+			if s.Lock(tctx, "k") == nil {
+				held.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := held.Load(); n != 1 {
+		t.Fatalf("%d stores obtained the lock, want 1", n)
+	}
+}
+
+func TestLoadWithInfo(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	if err := s.Store(ctx, "a/b", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	v, info, err := s.LoadWithInfo(ctx, "a/b")
+	if err != nil || string(v) != "value" {
+		t.Fatalf("LoadWithInfo = %q, %v", v, err)
+	}
+	if info.Key != "a/b" || info.Size != 5 || !info.IsTerminal || time.Since(info.Modified) > time.Minute {
+		t.Fatalf("LoadWithInfo info = %+v", info)
+	}
+	if _, _, err := s.LoadWithInfo(ctx, "missing"); !errors.Is(err, s3store.ErrNotFound) {
+		t.Fatalf("LoadWithInfo of missing key = %v, want ErrNotFound", err)
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	s, mem := newTestStore(t, s3store.WithPrefix("caddy"))
+	if s.Bucket() != testBucket || s.Prefix() != "caddy" || s.Region() != "us-east-1" {
+		t.Errorf("store for %s/%s in %s", s.Bucket(), s.Prefix(), s.Region())
+	}
+	if s.API() != mem || s.Client() != nil {
+		t.Errorf("API() = %T, Client() = %v, want the custom S3API and no client", s.API(), s.Client())
+	}
+
+	client := s3.New(s3.Options{Region: "eu-west-1"})
+	s = s3store.NewS3StoreFromClient(client, testBucket)
+	if s.Client() == nil || s.Client().Options().Region != "eu-west-1" {
+		t.Errorf("Client() = %v, want the given client's configuration", s.Client())
+	}
+}
+
+func TestNewS3StoreFromConfig(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeS3(t)
+	s3store.NewS3StoreForTesting(f.URL, "certs") // creates the bucket
+	cfg := aws.Config{
+		Region:       "eu-central-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+		BaseEndpoint: aws.String(f.URL),
+	}
+	s := s3store.NewS3StoreFromConfig(cfg, "certs", s3store.WithAllowHTTP(), s3store.WithPathStyle())
+	if s.Region() != "eu-central-1" || s.Client().Options().Region != "eu-central-1" {
+		t.Errorf("Region() = %q, client region = %q, want the configured region", s.Region(), s.Client().Options().Region)
+	}
+	if !s.Client().Options().UsePathStyle {
+		t.Error("options not applied to the client")
+	}
+	mustStore(t, s, "k")
+	if v, err := s.Load(ctx, "k"); err != nil || string(v) != "k" {
+		t.Fatalf("Load through the configured endpoint = %q, %v", v, err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.buckets["certs"]["certmagic/k"]; !ok {
+		t.Fatal("Store didn't go to the configured endpoint")
+	}
+}
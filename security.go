@@ -0,0 +1,223 @@
+package s3store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// SecurityFinding is a problem with the bucket's security settings
+// found by CheckBucketSecurity.
+type SecurityFinding struct {
+	// Check is the setting checked: "public-access-block",
+	// "bucket-policy", "secure-transport", "encryption" or
+	// "versioning".
+	Check string
+	// Problem describes what is wrong and Fix how to remedy it.
+	Problem string
+	Fix     string
+}
+
+func (f SecurityFinding) String() string {
+	return fmt.Sprintf("%s: %s; %s", f.Check, f.Problem, f.Fix)
+}
+
+// CheckBucketSecurity verifies that the bucket blocks public access,
+// has no policy making it public but one denying requests without TLS
+// (aws:SecureTransport), encrypts objects by default and keeps
+// versions of overwritten objects, so that private keys are never
+// unknowingly stored in a publicly accessible or unprotected bucket. It
+// returns one finding per problem; settings the store lacks permission
+// to read are reported as findings too. Account-level Block Public
+// Access settings are not taken into account.
+func (s *S3Store) CheckBucketSecurity(ctx context.Context) ([]SecurityFinding, error) {
+	var findings []SecurityFinding
+	for _, check := range []func(context.Context) (*SecurityFinding, error){
+		s.checkPublicAccessBlock,
+		s.checkBucketPolicy,
+		s.checkSecureTransport,
+		s.checkEncryption,
+		s.checkVersioning,
+	} {
+		f, err := check(ctx)
+		if err != nil {
+			return findings, err
+		}
+		if f != nil {
+			findings = append(findings, *f)
+		}
+	}
+	return findings, nil
+}
+
+func (s *S3Store) checkPublicAccessBlock(ctx context.Context) (*SecurityFinding, error) {
+	const check = "public-access-block"
+	fix := fmt.Sprintf("enable all four Block Public Access settings on bucket %s", aws.ToString(s.bucket))
+	out, err := s.client.GetPublicAccessBlock(ctx, &s3.GetPublicAccessBlockInput{Bucket: s.bucket})
+	if apiErrorCode(err) == "NoSuchPublicAccessBlockConfiguration" {
+		return &SecurityFinding{check, "Block Public Access is not configured", fix}, nil
+	}
+	if f, err := unverifiable(check, "s3:GetBucketPublicAccessBlock", err); f != nil || err != nil {
+		return f, err
+	}
+	c := out.PublicAccessBlockConfiguration
+	if c == nil || !aws.ToBool(c.BlockPublicAcls) || !aws.ToBool(c.IgnorePublicAcls) ||
+		!aws.ToBool(c.BlockPublicPolicy) || !aws.ToBool(c.RestrictPublicBuckets) {
+		return &SecurityFinding{check, "Block Public Access is only partially enabled", fix}, nil
+	}
+	return nil, nil
+}
+
+func (s *S3Store) checkBucketPolicy(ctx context.Context) (*SecurityFinding, error) {
+	const check = "bucket-policy"
+	out, err := s.client.GetBucketPolicyStatus(ctx, &s3.GetBucketPolicyStatusInput{Bucket: s.bucket})
+	if apiErrorCode(err) == "NoSuchBucketPolicy" {
+		return nil, nil
+	}
+	if f, err := unverifiable(check, "s3:GetBucketPolicyStatus", err); f != nil || err != nil {
+		return f, err
+	}
+	if out.PolicyStatus != nil && aws.ToBool(out.PolicyStatus.IsPublic) {
+		return &SecurityFinding{check, "the bucket policy grants public access",
+			"remove the statements granting access to everyone (Principal \"*\") from the bucket policy"}, nil
+	}
+	return nil, nil
+}
+
+func (s *S3Store) checkSecureTransport(ctx context.Context) (*SecurityFinding, error) {
+	const check = "secure-transport"
+	missing := &SecurityFinding{check, "the bucket policy doesn't deny requests made without TLS",
+		"add a statement denying s3:* when the condition Bool aws:SecureTransport is false"}
+	out, err := s.client.GetBucketPolicy(ctx, &s3.GetBucketPolicyInput{Bucket: s.bucket})
+	if apiErrorCode(err) == "NoSuchBucketPolicy" {
+		return missing, nil
+	}
+	if f, err := unverifiable(check, "s3:GetBucketPolicy", err); f != nil || err != nil {
+		return f, err
+	}
+	if !deniesInsecureTransport(aws.ToString(out.Policy)) {
+		return missing, nil
+	}
+	return nil, nil
+}
+
+// deniesInsecureTransport reports whether the bucket policy has a Deny
+// statement conditioned on aws:SecureTransport being false.
+func deniesInsecureTransport(policy string) bool {
+	var doc struct {
+		Statement json.RawMessage
+	}
+	if json.Unmarshal([]byte(policy), &doc) != nil {
+		return false
+	}
+	type statement struct {
+		Effect    string
+		Condition map[string]map[string]any
+	}
+	var statements []statement
+	// Statement may be a single statement instead of a list.
+	if json.Unmarshal(doc.Statement, &statements) != nil {
+		var st statement
+		if json.Unmarshal(doc.Statement, &st) != nil {
+			return false
+		}
+		statements = []statement{st}
+	}
+	for _, st := range statements {
+		if st.Effect != "Deny" {
+			continue
+		}
+		for op, conds := range st.Condition {
+			if !strings.EqualFold(op, "Bool") {
+				continue
+			}
+			for k, v := range conds {
+				if strings.EqualFold(k, "aws:SecureTransport") && fmt.Sprint(v) == "false" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (s *S3Store) checkEncryption(ctx context.Context) (*SecurityFinding, error) {
+	const check = "encryption"
+	_, err := s.client.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{Bucket: s.bucket})
+	if apiErrorCode(err) == "ServerSideEncryptionConfigurationNotFoundError" {
+		return &SecurityFinding{check, "default encryption is not configured",
+			"configure default encryption with SSE-S3 or SSE-KMS, or use WithKMSKey"}, nil
+	}
+	return unverifiable(check, "s3:GetEncryptionConfiguration", err)
+}
+
+func (s *S3Store) checkVersioning(ctx context.Context) (*SecurityFinding, error) {
+	const check = "versioning"
+	out, err := s.client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: s.bucket})
+	if f, err := unverifiable(check, "s3:GetBucketVersioning", err); f != nil || err != nil {
+		return f, err
+	}
+	if out.Status != types.BucketVersioningStatusEnabled {
+		return &SecurityFinding{check, "versioning is not enabled, overwritten or deleted keys can't be recovered",
+			"enable versioning on the bucket"}, nil
+	}
+	return nil, nil
+}
+
+// unverifiable turns a failure to read a setting into a finding if
+// access was denied, or else into an error.
+func unverifiable(check, permission string, err error) (*SecurityFinding, error) {
+	switch {
+	case err == nil:
+		return nil, nil
+	case errors.Is(kindOf(err), ErrAccessDenied):
+		return &SecurityFinding{check, "the setting could not be read",
+			"grant " + permission + " to verify it"}, nil
+	default:
+		return nil, fmt.Errorf("checking %s: %w", check, err)
+	}
+}
+
+// apiErrorCode returns the error code of the S3 error err,
+// or "" if err is not an API error.
+func apiErrorCode(err error) string {
+	var ae smithy.APIError
+	if errors.As(err, &ae) {
+		return ae.ErrorCode()
+	}
+	return ""
+}
+
+// WithAllowHTTP allows the store to send requests over plain HTTP,
+// e.g. to a local MinIO or LocalStack endpoint. By default requests to
+// http:// endpoints are refused with ErrInsecureTransport, as they
+// would expose private keys and credentials on the wire.
+func WithAllowHTTP() Option {
+	return func(s *S3Store) {
+		s.allowHTTP = true
+	}
+}
+
+// requireTLS is a client option that refuses to
+// send requests over plain HTTP.
+func (s *S3Store) requireTLS(o *s3.Options) {
+	o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Finalize.Add(middleware.FinalizeMiddlewareFunc("S3StoreRequireTLS", func(
+			ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler,
+		) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			if req, ok := in.Request.(*smithyhttp.Request); ok && req.URL.Scheme != "https" {
+				return middleware.FinalizeOutput{}, middleware.Metadata{}, ErrInsecureTransport
+			}
+			return next.HandleFinalize(ctx, in)
+		}), middleware.After)
+	})
+}
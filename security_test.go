@@ -0,0 +1,128 @@
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+// policyBucket serves policy as the bucket policy, reports it as
+// public if public is set, and denies reading the versioning setting.
+type policyBucket struct {
+	*memstore.Client
+	policy string
+	public bool
+}
+
+func (p policyBucket) GetBucketPolicy(ctx context.Context, params *s3.GetBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.GetBucketPolicyOutput, error) {
+	return &s3.GetBucketPolicyOutput{Policy: aws.String(p.policy)}, nil
+}
+
+func (p policyBucket) GetBucketPolicyStatus(ctx context.Context, params *s3.GetBucketPolicyStatusInput, optFns ...func(*s3.Options)) (*s3.GetBucketPolicyStatusOutput, error) {
+	return &s3.GetBucketPolicyStatusOutput{PolicyStatus: &types.PolicyStatus{IsPublic: aws.Bool(p.public)}}, nil
+}
+
+func (p policyBucket) GetBucketVersioning(ctx context.Context, params *s3.GetBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.GetBucketVersioningOutput, error) {
+	return nil, responseError(http.StatusForbidden, "AccessDenied")
+}
+
+func TestCheckBucketSecurity(t *testing.T) {
+	ctx := context.Background()
+	checks := func(findings []s3store.SecurityFinding) []string {
+		var names []string
+		for _, f := range findings {
+			names = append(names, f.Check)
+		}
+		return names
+	}
+
+	s, mem := newTestStore(t)
+	findings, err := s.CheckBucketSecurity(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := checks(findings), []string{"public-access-block", "secure-transport", "encryption", "versioning"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("findings for an unprotected bucket = %v, want %v", findings, want)
+	}
+
+	bucket := aws.String(testBucket)
+	if _, err := mem.PutPublicAccessBlock(ctx, &s3.PutPublicAccessBlockInput{
+		Bucket: bucket,
+		PublicAccessBlockConfiguration: &types.PublicAccessBlockConfiguration{
+			BlockPublicAcls:       aws.Bool(true),
+			IgnorePublicAcls:      aws.Bool(true),
+			BlockPublicPolicy:     aws.Bool(true),
+			RestrictPublicBuckets: aws.Bool(true),
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mem.PutBucketEncryption(ctx, &s3.PutBucketEncryptionInput{
+		Bucket: bucket,
+		ServerSideEncryptionConfiguration: &types.ServerSideEncryptionConfiguration{
+			Rules: []types.ServerSideEncryptionRule{{
+				ApplyServerSideEncryptionByDefault: &types.ServerSideEncryptionByDefault{SSEAlgorithm: types.ServerSideEncryptionAes256},
+			}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mem.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket:                  bucket,
+		VersioningConfiguration: &types.VersioningConfiguration{Status: types.BucketVersioningStatusEnabled},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	findings, err = s.CheckBucketSecurity(ctx)
+	if err != nil || len(findings) != 1 || findings[0].Check != "secure-transport" {
+		t.Fatalf("findings for a bucket without a policy = %v, %v, want secure-transport only", findings, err)
+	}
+
+	const denyHTTP = `{"Statement": {"Effect": "Deny", "Principal": "*", "Action": "s3:*",
+		"Condition": {"Bool": {"aws:SecureTransport": "false"}}}}`
+	s = s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(policyBucket{mem, denyHTTP, true}))
+	findings, err = s.CheckBucketSecurity(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := checks(findings), []string{"bucket-policy", "versioning"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("findings for a public policy = %v, want %v", findings, want)
+	}
+	if findings[1].Problem != "the setting could not be read" {
+		t.Errorf("finding for a denied check = %v", findings[1])
+	}
+}
+
+func TestRequireTLS(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeS3(t)
+	f.buckets["certs"] = make(map[string][]byte)
+	var requests atomic.Int32
+	f.handle = func(*http.Request) { requests.Add(1) }
+	opts := []s3store.Option{s3store.WithEndpoint(f.URL), s3store.WithPathStyle()}
+
+	s := s3store.NewS3StoreWithCredentials("test", "test", "certs", "us-east-1", opts...)
+	if err := s.Store(ctx, "k", []byte("secret")); !errors.Is(err, s3store.ErrInsecureTransport) {
+		t.Fatalf("Store over HTTP = %v, want ErrInsecureTransport", err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Fatalf("%d requests sent over HTTP", n)
+	}
+
+	s = s3store.NewS3StoreWithCredentials("test", "test", "certs", "us-east-1", append(opts, s3store.WithAllowHTTP())...)
+	if err := s.Store(ctx, "k", []byte("secret")); err != nil {
+		t.Fatalf("Store over HTTP with WithAllowHTTP: %v", err)
+	}
+	if requests.Load() == 0 {
+		t.Fatal("no request sent with WithAllowHTTP")
+	}
+}
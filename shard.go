@@ -0,0 +1,56 @@
+package s3store
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"strings"
+)
+
+// WithKeySharding spreads keys across 256 hashed sub-prefixes to avoid
+// hot S3 partitions in very large deployments. A shard directory derived
+// from the key's element at position depth is inserted before it, so with
+// depth 1 "certificates/example.com/example.com.crt" is stored as
+// "certificates/ab/example.com/example.com.crt". certmagic keys
+// certificates by issuer first, so depth 2 spreads them by domain. Keys
+// with no element at depth are stored as is. List, Migrate and the other
+// APIs returning keys translate shard directories away transparently;
+// List prefixes must consist of whole key elements.
+func WithKeySharding(depth int) Option {
+	return func(s *S3Store) {
+		s.shardDepth = depth
+	}
+}
+
+// shardOf returns the shard directory for the key element elem.
+func shardOf(elem string) string {
+	sum := md5.Sum([]byte(elem))
+	return hex.EncodeToString(sum[:1])
+}
+
+// shardKey inserts the shard directory into key.
+func (s *S3Store) shardKey(key string) string {
+	if s.shardDepth <= 0 {
+		return key
+	}
+	elems := strings.Split(key, "/")
+	if len(elems) <= s.shardDepth || elems[s.shardDepth] == "" {
+		return key
+	}
+	d := s.shardDepth
+	elems = append(elems[:d], append([]string{shardOf(elems[d])}, elems[d:]...)...)
+	return strings.Join(elems, "/")
+}
+
+// unshardKey is the inverse of shardKey. Keys stored before sharding
+// was enabled are returned unchanged.
+func (s *S3Store) unshardKey(key string) string {
+	if s.shardDepth <= 0 {
+		return key
+	}
+	elems := strings.Split(key, "/")
+	d := s.shardDepth
+	if len(elems) <= d+1 || elems[d] != shardOf(elems[d+1]) {
+		return key
+	}
+	return strings.Join(append(elems[:d], elems[d+1:]...), "/")
+}
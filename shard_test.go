@@ -0,0 +1,40 @@
+package s3store_test
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"reflect"
+	"slices"
+	"testing"
+
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+func TestKeySharding(t *testing.T) {
+	ctx := context.Background()
+	s, mem := newTestStore(t, s3store.WithKeySharding(2))
+	mustStore(t, s, "certificates/acme/a.com/a.com.crt", "certificates/acme/b.com/b.com.crt", "ocsp/x")
+
+	sum := md5.Sum([]byte("a.com"))
+	sharded := s.Prefix() + "/certificates/acme/" + hex.EncodeToString(sum[:1]) + "/a.com/a.com.crt"
+	names := objectNames(t, mem, testBucket)
+	if !slices.Contains(names, sharded) || !slices.Contains(names, s.Prefix()+"/ocsp/x") {
+		t.Fatalf("objects = %q, want %s and the unsharded ocsp/x", names, sharded)
+	}
+
+	if v, err := s.Load(ctx, "certificates/acme/a.com/a.com.crt"); err != nil || string(v) != "certificates/acme/a.com/a.com.crt" {
+		t.Fatalf("Load = %q, %v", v, err)
+	}
+	// Keys are listed in the order of their shards.
+	keys, err := s.List(ctx, "certificates/acme", true)
+	slices.Sort(keys)
+	want := []string{"certificates/acme/a.com/a.com.crt", "certificates/acme/b.com/b.com.crt"}
+	if err != nil || !reflect.DeepEqual(keys, want) {
+		t.Fatalf("List = %q, %v, want %q", keys, err, want)
+	}
+	keys, err = s.List(ctx, "certificates/acme/a.com", false)
+	if err != nil || !reflect.DeepEqual(keys, want[:1]) {
+		t.Fatalf("List of a domain = %q, %v", keys, err)
+	}
+}
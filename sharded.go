@@ -0,0 +1,131 @@
+package s3store
+
+import (
+	"context"
+	"hash/fnv"
+	"path"
+	"sort"
+	"strconv"
+
+	cm "github.com/caddyserver/certmagic"
+)
+
+// shardReplicas is the number of points each store has on the
+// hash ring, which evens out the share of keys each store gets.
+const shardReplicas = 128
+
+// ShardedStorage is a certmagic Storage spreading keys across several
+// stores, usually in different buckets, by consistent hashing, e.g. to
+// cap the number of objects per bucket or to split certificates across
+// isolation domains. Keys in the same directory, such as a site's
+// certificate, private key and metadata, are kept in the same store,
+// while top-level keys are spread by their own name.
+// Adding a store only moves the keys that now hash to it. List merges
+// the listings of all stores.
+type ShardedStorage struct {
+	stores []*S3Store
+	ring   []ringPoint
+}
+
+type ringPoint struct {
+	hash  uint32
+	store *S3Store
+}
+
+// NewShardedStorage returns a ShardedStorage over stores. The ring is
+// derived from each store's bucket, prefix and endpoint, so every
+// instance configured with the same stores places keys identically,
+// regardless of their order.
+func NewShardedStorage(stores ...*S3Store) *ShardedStorage {
+	c := &ShardedStorage{stores: stores}
+	for _, st := range stores {
+		for i := 0; i < shardReplicas; i++ {
+			c.ring = append(c.ring, ringPoint{hash: ringHash(st.String() + "#" + strconv.Itoa(i)), store: st})
+		}
+	}
+	sort.Slice(c.ring, func(i, j int) bool { return c.ring[i].hash < c.ring[j].hash })
+	return c
+}
+
+func ringHash(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// storeFor returns the store responsible for key: the first point on
+// the ring following the hash of key's directory. Top-level keys, such
+// as the names certmagic locks, are hashed whole; they would otherwise
+// all share the directory "." and end up in the same store.
+func (c *ShardedStorage) storeFor(key string) *S3Store {
+	dir := path.Dir(key)
+	if dir == "." {
+		dir = key
+	}
+	h := ringHash(dir)
+	i := sort.Search(len(c.ring), func(i int) bool { return c.ring[i].hash >= h })
+	if i == len(c.ring) {
+		i = 0
+	}
+	return c.ring[i].store
+}
+
+// Lock obtains the lock named by key from the store responsible for key.
+func (c *ShardedStorage) Lock(ctx context.Context, key string) error {
+	return c.storeFor(key).Lock(ctx, key)
+}
+
+// Unlock releases the lock for key.
+func (c *ShardedStorage) Unlock(ctx context.Context, key string) error {
+	return c.storeFor(key).Unlock(ctx, key)
+}
+
+// Store saves value at key.
+func (c *ShardedStorage) Store(ctx context.Context, key string, value []byte) error {
+	return c.storeFor(key).Store(ctx, key, value)
+}
+
+// Load retrieves the value at key.
+func (c *ShardedStorage) Load(ctx context.Context, key string) ([]byte, error) {
+	return c.storeFor(key).Load(ctx, key)
+}
+
+// Delete deletes key.
+func (c *ShardedStorage) Delete(ctx context.Context, key string) error {
+	return c.storeFor(key).Delete(ctx, key)
+}
+
+// Exists returns true if key exists.
+func (c *ShardedStorage) Exists(ctx context.Context, key string) bool {
+	return c.storeFor(key).Exists(ctx, key)
+}
+
+// List returns the union of the keys matching prefix in all stores,
+// sorted. Unlike ChainStorage it fails if any store can't be listed,
+// since the result would be missing a share of the keys.
+func (c *ShardedStorage) List(ctx context.Context, prefix string, recursive bool) ([]string, error) {
+	seen := make(map[string]bool)
+	for _, st := range c.stores {
+		keys, err := st.List(ctx, prefix, recursive)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			seen[k] = true
+		}
+	}
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Stat returns information about key.
+func (c *ShardedStorage) Stat(ctx context.Context, key string) (cm.KeyInfo, error) {
+	return c.storeFor(key).Stat(ctx, key)
+}
+
+// Interface guard
+var _ cm.Storage = (*ShardedStorage)(nil)
@@ -0,0 +1,132 @@
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+func TestShardedStorage(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New("a", "b", "c")
+	store := func(bucket string) *s3store.S3Store {
+		return s3store.NewS3Store(bucket, "us-east-1", s3store.WithS3API(mem))
+	}
+	a, b, c := store("a"), store("b"), store("c")
+	sharded := s3store.NewShardedStorage(a, b)
+
+	var keys []string
+	for i := range 20 {
+		dir := fmt.Sprintf("certificates/acme/site%02d.com", i)
+		keys = append(keys, dir+"/site.crt", dir+"/site.key")
+	}
+	for _, key := range keys {
+		if err := sharded.Store(ctx, key, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each key is stored once, next to the other keys in its directory,
+	// and both stores get a share.
+	owner := func(key string) *s3store.S3Store {
+		t.Helper()
+		var found *s3store.S3Store
+		for _, st := range []*s3store.S3Store{a, b, c} {
+			if st.Exists(ctx, key) {
+				if found != nil {
+					t.Fatalf("%s stored in both %s and %s", key, found.Bucket(), st.Bucket())
+				}
+				found = st
+			}
+		}
+		if found == nil {
+			t.Fatalf("%s not stored", key)
+		}
+		return found
+	}
+	shares := make(map[*s3store.S3Store]int)
+	for i := 0; i < len(keys); i += 2 {
+		if crt, key := owner(keys[i]), owner(keys[i+1]); crt != key {
+			t.Errorf("%s and %s stored in different buckets", keys[i], keys[i+1])
+		}
+		shares[owner(keys[i])]++
+	}
+	if shares[a] == 0 || shares[b] == 0 {
+		t.Fatalf("sites per bucket = a: %d, b: %d, want both used", shares[a], shares[b])
+	}
+
+	got, err := sharded.List(ctx, "certificates", true)
+	if err != nil || !reflect.DeepEqual(got, keys) {
+		t.Fatalf("List = %q, %v, want %q", got, err, keys)
+	}
+
+	// The placement doesn't depend on the order of the stores,
+	// and adding a store only moves keys to the new store.
+	reordered := s3store.NewShardedStorage(b, a)
+	grown := s3store.NewShardedStorage(c, a, b)
+	for _, key := range keys {
+		if v, err := reordered.Load(ctx, key); err != nil || string(v) != key {
+			t.Fatalf("Load(%s) with reordered stores = %q, %v", key, v, err)
+		}
+		if !grown.Exists(ctx, key) {
+			if err := grown.Store(ctx, key, []byte(key)); err != nil {
+				t.Fatal(err)
+			}
+			if !c.Exists(ctx, key) {
+				t.Errorf("%s moved to a store other than the new one", key)
+			}
+		}
+	}
+
+	if err := sharded.Lock(ctx, keys[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := sharded.Unlock(ctx, keys[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := sharded.Delete(ctx, keys[0]); err != nil {
+		t.Fatal(err)
+	}
+	if a.Exists(ctx, keys[0]) || b.Exists(ctx, keys[0]) {
+		t.Fatalf("%s exists after Delete", keys[0])
+	}
+}
+
+func TestShardedStorageStat(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New("a", "b")
+	a := s3store.NewS3Store("a", "us-east-1", s3store.WithS3API(mem))
+	b := s3store.NewS3Store("b", "us-east-1", s3store.WithS3API(mem))
+	sharded := s3store.NewShardedStorage(a, b)
+
+	const key = "certificates/acme/example.com/example.com.crt"
+	if err := sharded.Store(ctx, key, []byte("crt")); err != nil {
+		t.Fatal(err)
+	}
+	info, err := sharded.Stat(ctx, key)
+	if err != nil || info.Key != key || info.Size != 3 || !info.IsTerminal {
+		t.Fatalf("Stat = %+v, %v", info, err)
+	}
+	if _, err := sharded.Stat(ctx, "certificates/acme/missing.com/missing.com.crt"); !errors.Is(err, s3store.ErrNotFound) {
+		t.Fatalf("Stat of missing key = %v, want ErrNotFound", err)
+	}
+
+	// Top-level keys, like the names certmagic locks, are spread too.
+	for i := range 20 {
+		name := fmt.Sprintf("issue_cert_site%02d.com", i)
+		if err := sharded.Lock(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+		defer sharded.Unlock(ctx, name)
+	}
+	for _, st := range []*s3store.S3Store{a, b} {
+		if locks, err := st.Locks(ctx); err != nil || len(locks) == 0 {
+			t.Errorf("locks in %s = %d, %v, want a share", st.Bucket(), len(locks), err)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package s3store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// snapshotDir is the directory under the storage
+// prefix that snapshots are copied to.
+const snapshotDir = "snapshots"
+
+// CopyError reports the keys that could not be copied,
+// along with the reason for each.
+type CopyError struct {
+	Failed map[string]error
+}
+
+func (e *CopyError) Error() string {
+	return failedKeysMessage("copying", e.Failed)
+}
+
+// Snapshot server-side copies every key in the store to
+// snapshots/<name>-<timestamp>/<key> and returns the snapshot's ID,
+// "<name>-<timestamp>", e.g. to take a backup before a mass migration
+// or a Caddy upgrade. Locks, the trash and earlier snapshots are not
+// included. Each copy is verified against its source. Keys are copied
+// concurrently while the store stays in use, so a key written during
+// the snapshot may be copied before or after the write; stop writers
+// first for an exact point-in-time copy. If some keys could not be
+// copied, the ID is returned along with a *CopyError listing them.
+func (s *S3Store) Snapshot(ctx context.Context, name string) (string, error) {
+	if name == "" || strings.Contains(name, "/") {
+		return "", fmt.Errorf("invalid snapshot name %q", name)
+	}
+	id := name + "-" + time.Now().UTC().Format(trashTimeFormat)
+	if s.skipDryRun("snapshot", id) {
+		return id, nil
+	}
+	objects, err := s.liveObjects(ctx)
+	if err != nil {
+		return "", err
+	}
+	cerr := &CopyError{Failed: make(map[string]error)}
+	var mu sync.Mutex
+	bucket := aws.ToString(s.bucket)
+	s.forEachKey(slices.Sorted(maps.Keys(objects)), func(key string) {
+		obj := objects[key]
+		dst := snapshotDir + "/" + id + "/" + key
+		if err := s.copyVerified(ctx, bucket, aws.ToString(obj.Key), dst, aws.ToString(obj.ETag), aws.ToInt64(obj.Size)); err != nil {
+			mu.Lock()
+			defer mu.Unlock()
+			cerr.Failed[key] = err
+		}
+	})
+	if len(cerr.Failed) > 0 {
+		return id, cerr
+	}
+	return id, nil
+}
+
+// liveObjects returns the objects Snapshot copies, by key.
+func (s *S3Store) liveObjects(ctx context.Context) (map[string]types.Object, error) {
+	objects := make(map[string]types.Object)
+	err := s.eachObjectNamed(ctx, s.prefix+"/", func(obj types.Object) error {
+		if name := aws.ToString(obj.Key); s.snapshotted(name) {
+			objects[s.keyName(name)] = obj
+		}
+		return nil
+	})
+	return objects, err
+}
+
+// snapshotObjects returns the objects in the snapshot
+// with the given ID, by the key they were copied from.
+func (s *S3Store) snapshotObjects(ctx context.Context, id string) (map[string]types.Object, error) {
+	dir := snapshotDir + "/" + id
+	objects := make(map[string]types.Object)
+	err := s.eachObject(ctx, dir, func(obj types.Object) error {
+		if key, ok := strings.CutPrefix(s.keyName(aws.ToString(obj.Key)), dir+"/"); ok {
+			objects[key] = obj
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing snapshot %s: %w", id, err)
+	}
+	return objects, nil
+}
+
+// snapshotted reports whether the object name is data to include in a
+// snapshot rather than a lock, trashed object, snapshot or audit log.
+func (s *S3Store) snapshotted(name string) bool {
+	if strings.HasPrefix(name, s.lockDir()+"/") {
+		return false
+	}
+	key := s.keyName(name)
+	return !strings.HasPrefix(key, trashDir+"/") && !strings.HasPrefix(key, snapshotDir+"/") &&
+		!auditLogged(key, "")
+}
+
+// ListSnapshots returns the IDs of the store's snapshots, oldest first
+// for snapshots of the same name.
+func (s *S3Store) ListSnapshots(ctx context.Context) ([]string, error) {
+	dirs, err := s.List(ctx, snapshotDir, false)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		ids = append(ids, path.Base(dir))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// resolveSnapshot returns the ID of the snapshot called name: name
+// itself if it is an ID, or else the ID of the latest snapshot taken
+// with that name.
+func (s *S3Store) resolveSnapshot(ctx context.Context, name string) (string, error) {
+	ids, err := s.ListSnapshots(ctx)
+	if err != nil {
+		return "", err
+	}
+	var latest string
+	for _, id := range ids {
+		if id == name {
+			return id, nil
+		}
+		stamp, ok := strings.CutPrefix(id, name+"-")
+		if _, err := time.Parse(trashTimeFormat, stamp); ok && err == nil && id > latest {
+			latest = id
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("snapshot %q: %w", name, ErrNotFound)
+	}
+	return latest, nil
+}
+
+// RestoreOption configures RestoreSnapshot.
+type RestoreOption func(*restoreConfig)
+
+type restoreConfig struct {
+	dst      *S3Store
+	progress func(MigrateProgress)
+}
+
+// RestoreTo restores the snapshot into dst, which may use another
+// prefix, bucket or both, instead of over the live keys. dst's client
+// must be able to read from the snapshot's bucket.
+func RestoreTo(dst *S3Store) RestoreOption {
+	return func(c *restoreConfig) {
+		c.dst = dst
+	}
+}
+
+// RestoreProgressFunc registers fn to be called after each key is
+// processed. Keys are restored concurrently, but fn is called for one
+// key at a time. Skipped is set for keys that were not copied because
+// they are unchanged or conflicting.
+func RestoreProgressFunc(fn func(MigrateProgress)) RestoreOption {
+	return func(c *restoreConfig) {
+		c.progress = fn
+	}
+}
+
+// RestoreResult summarizes a RestoreSnapshot run.
+type RestoreResult struct {
+	// Restored are the keys copied from the snapshot.
+	Restored []string
+	// Unchanged are the keys whose current value equals
+	// the snapshot's and that were left alone.
+	Unchanged []string
+	// Conflicts are the keys whose current value differs from the
+	// snapshot's. They are restored, and thus also in Restored, with
+	// overwrite set, and left alone otherwise.
+	Conflicts []string
+	Failed    map[string]error
+}
+
+// RestoreSnapshot copies the keys of the snapshot called name back over
+// the live keys, completing the backup story started by Snapshot. name
+// is either a snapshot ID returned by Snapshot or ListSnapshots, or the
+// name given to Snapshot, which selects the latest snapshot taken with
+// it. Keys missing from the store are always restored; keys whose value
+// differs from the snapshot's are only overwritten if overwrite is set,
+// and reported as conflicts either way. Values are compared by ETag and
+// size. ETags differ between copies with SSE-KMS, and the ETag of a
+// multipart upload never matches that of its copy in the snapshot, so
+// with SSE-KMS every existing key, and otherwise every key whose live
+// value was uploaded in parts, counts as a conflict. Keys added since
+// the snapshot are kept. Failures of individual keys are collected in
+// the result; an error is only returned if the snapshot could not be
+// found or listed.
+func (s *S3Store) RestoreSnapshot(ctx context.Context, name string, overwrite bool, opts ...RestoreOption) (RestoreResult, error) {
+	cfg := restoreConfig{dst: s}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	dst := cfg.dst
+	result := RestoreResult{Failed: make(map[string]error)}
+	id, err := s.resolveSnapshot(ctx, name)
+	if err != nil {
+		return result, err
+	}
+	objects, err := s.snapshotObjects(ctx, id)
+	if err != nil {
+		return result, err
+	}
+	keys := slices.Sorted(maps.Keys(objects))
+
+	var mu sync.Mutex
+	var done int
+	s.forEachKey(keys, func(key string) {
+		obj := objects[key]
+		restored, conflict, err := s.restoreKey(ctx, dst, aws.ToString(obj.Key), key,
+			aws.ToString(obj.ETag), aws.ToInt64(obj.Size), overwrite)
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case err != nil:
+			result.Failed[key] = err
+		case restored:
+			result.Restored = append(result.Restored, key)
+		case !conflict:
+			result.Unchanged = append(result.Unchanged, key)
+		}
+		if conflict {
+			result.Conflicts = append(result.Conflicts, key)
+		}
+		done++
+		if cfg.progress != nil {
+			cfg.progress(MigrateProgress{
+				Key:     key,
+				Done:    done,
+				Total:   len(keys),
+				Skipped: err == nil && !restored,
+				Err:     err,
+			})
+		}
+	})
+	sort.Strings(result.Restored)
+	sort.Strings(result.Unchanged)
+	sort.Strings(result.Conflicts)
+	return result, nil
+}
+
+// restoreKey copies the snapshotted object name to key in dst unless
+// key exists there with the same content, or different content and
+// overwrite is false. It reports whether the object was copied and
+// whether the existing value conflicted with it.
+func (s *S3Store) restoreKey(ctx context.Context, dst *S3Store, name, key, etag string, size int64, overwrite bool) (restored, conflict bool, err error) {
+	current, err := dst.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: dst.bucket,
+		Key:    aws.String(dst.Filename(ctx, key)),
+	})
+	switch {
+	case errors.Is(kindOf(err), ErrNotFound):
+	case err != nil:
+		return false, false, err
+	case aws.ToString(current.ETag) == etag && aws.ToInt64(current.ContentLength) == size:
+		return false, false, nil
+	default:
+		conflict = true
+		if !overwrite {
+			return false, true, nil
+		}
+	}
+	if dst.skipDryRun("restore", key) {
+		return true, conflict, nil
+	}
+	err = dst.copyVerified(ctx, aws.ToString(s.bucket), name, key, etag, size)
+	if dst.cache != nil {
+		dst.cache.remove(key)
+	}
+	dst.mutated(ctx, "store", err, key)
+	if err == nil {
+		err = dst.replicate(ctx, func(ctx context.Context, r *S3Store) error {
+			return r.copyVerified(ctx, aws.ToString(s.bucket), name, key, etag, size)
+		})
+	}
+	return err == nil, conflict, err
+}
+
+// SnapshotDiff lists the keys that differ between two sets of keys, as
+// reported by DiffSnapshots.
+type SnapshotDiff struct {
+	// Added are the keys only in the second set, Removed those only in
+	// the first and Changed those whose ETag or size differs.
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// DiffSnapshots reports the keys added, removed and changed between the
+// snapshots called a and b, e.g. to audit what changed during an
+// incident window. Snapshots are named as for RestoreSnapshot; an empty
+// name stands for the store's live keys, so that DiffSnapshots(ctx,
+// "pre-upgrade", "") shows what changed since a snapshot. Values are
+// compared by ETag and size, or only by size with SSE-KMS, as every
+// copy of an object encrypted with it gets a new ETag.
+func (s *S3Store) DiffSnapshots(ctx context.Context, a, b string) (SnapshotDiff, error) {
+	var diff SnapshotDiff
+	before, err := s.snapshotOrLive(ctx, a)
+	if err != nil {
+		return diff, err
+	}
+	after, err := s.snapshotOrLive(ctx, b)
+	if err != nil {
+		return diff, err
+	}
+	for _, key := range slices.Sorted(maps.Keys(before)) {
+		obj, ok := after[key]
+		switch {
+		case !ok:
+			diff.Removed = append(diff.Removed, key)
+		case aws.ToInt64(obj.Size) != aws.ToInt64(before[key].Size) ||
+			s.kmsKeyID == "" && aws.ToString(obj.ETag) != aws.ToString(before[key].ETag):
+			diff.Changed = append(diff.Changed, key)
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(after)) {
+		if _, ok := before[key]; !ok {
+			diff.Added = append(diff.Added, key)
+		}
+	}
+	return diff, nil
+}
+
+// snapshotOrLive returns the objects of the snapshot called
+// name, or the live objects if name is empty.
+func (s *S3Store) snapshotOrLive(ctx context.Context, name string) (map[string]types.Object, error) {
+	if name == "" {
+		return s.liveObjects(ctx)
+	}
+	id, err := s.resolveSnapshot(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.snapshotObjects(ctx, id)
+}
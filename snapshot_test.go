@@ -0,0 +1,141 @@
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+func TestRestoreSnapshot(t *testing.T) {
+	ctx := context.Background()
+	s, mem := newTestStore(t)
+	mustStore(t, s, "a/1", "a/2", "a/3")
+	id, err := s.Snapshot(ctx, "pre")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, "a/1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Store(ctx, "a/2", []byte("changed")); err != nil {
+		t.Fatal(err)
+	}
+	mustStore(t, s, "a/4")
+
+	var progress int
+	res, err := s.RestoreSnapshot(ctx, "pre", false, s3store.RestoreProgressFunc(func(s3store.MigrateProgress) { progress++ }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := s3store.RestoreResult{
+		Restored:  []string{"a/1"},
+		Unchanged: []string{"a/3"},
+		Conflicts: []string{"a/2"},
+		Failed:    map[string]error{},
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("RestoreSnapshot = %+v, want %+v", res, want)
+	}
+	if progress != 3 {
+		t.Errorf("progress reported for %d keys, want 3", progress)
+	}
+	if v, _ := s.Load(ctx, "a/2"); string(v) != "changed" {
+		t.Errorf("conflicting key overwritten without overwrite set")
+	}
+
+	res, err = s.RestoreSnapshot(ctx, id, true)
+	if err != nil || !reflect.DeepEqual(res.Restored, []string{"a/2"}) {
+		t.Fatalf("RestoreSnapshot with overwrite = %+v, %v", res, err)
+	}
+	if v, _ := s.Load(ctx, "a/2"); string(v) != "a/2" {
+		t.Errorf("a/2 = %q after restoring with overwrite", v)
+	}
+	if !s.Exists(ctx, "a/4") {
+		t.Errorf("key added after the snapshot was removed")
+	}
+
+	alt := newTestStoreOn(t, mem, s3store.WithPrefix("alt"))
+	res, err = s.RestoreSnapshot(ctx, "pre", false, s3store.RestoreTo(alt))
+	if err != nil || len(res.Restored) != 3 {
+		t.Fatalf("RestoreSnapshot into another prefix = %+v, %v", res, err)
+	}
+	if _, err := s.RestoreSnapshot(ctx, "missing", false); err == nil {
+		t.Errorf("RestoreSnapshot of a missing snapshot succeeded")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	mustStore(t, s, "a/1", "certificates/acme/a.com/a.com.crt")
+	if err := s.Lock(ctx, "l"); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Unlock(ctx, "l")
+
+	pre, err := s.Snapshot(ctx, "pre")
+	if err != nil || !strings.HasPrefix(pre, "pre-") {
+		t.Fatalf("Snapshot = %s, %v", pre, err)
+	}
+	post, err := s.Snapshot(ctx, "post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids, err := s.ListSnapshots(ctx); err != nil || !reflect.DeepEqual(ids, []string{post, pre}) {
+		t.Fatalf("ListSnapshots = %q, %v, want %q", ids, err, []string{post, pre})
+	}
+
+	// Snapshots copy the keys but neither locks nor earlier snapshots.
+	keys, err := s.List(ctx, "snapshots/"+post, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"snapshots/" + post + "/a/1", "snapshots/" + post + "/certificates/acme/a.com/a.com.crt"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Fatalf("keys in snapshot = %q, want %q", keys, want)
+	}
+	if v, err := s.Load(ctx, want[0]); err != nil || string(v) != "a/1" {
+		t.Fatalf("snapshotted value = %q, %v", v, err)
+	}
+
+	if _, err := s.Snapshot(ctx, "a/b"); err == nil {
+		t.Fatal("Snapshot with a slash in its name succeeded")
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	mustStore(t, s, "a", "b", "c")
+	if _, err := s.Snapshot(ctx, "pre"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Store(ctx, "b", []byte("changed")); err != nil {
+		t.Fatal(err)
+	}
+	mustStore(t, s, "d")
+
+	diff, err := s.DiffSnapshots(ctx, "pre", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := s3store.SnapshotDiff{Added: []string{"d"}, Removed: []string{"a"}, Changed: []string{"b"}}
+	if !reflect.DeepEqual(diff, want) {
+		t.Fatalf("DiffSnapshots(pre, live) = %+v, want %+v", diff, want)
+	}
+	diff, err = s.DiffSnapshots(ctx, "", "pre")
+	want = s3store.SnapshotDiff{Added: []string{"a"}, Removed: []string{"d"}, Changed: []string{"b"}}
+	if err != nil || !reflect.DeepEqual(diff, want) {
+		t.Fatalf("DiffSnapshots(live, pre) = %+v, %v, want %+v", diff, err, want)
+	}
+	if _, err := s.DiffSnapshots(ctx, "missing", ""); !errors.Is(err, s3store.ErrNotFound) {
+		t.Fatalf("DiffSnapshots of missing snapshot = %v, want ErrNotFound", err)
+	}
+}
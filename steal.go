@@ -0,0 +1,55 @@
+package s3store
+
+import "time"
+
+// StealPolicy decides when Lock may remove a stale lock held by
+// someone else in order to obtain it.
+type StealPolicy struct {
+	never         bool
+	confirmations int
+	within        time.Duration
+}
+
+// StealStale removes stale locks as soon as they are seen. It is the
+// default.
+var StealStale = StealPolicy{confirmations: 1}
+
+// NeverSteal makes Lock fail with ErrLockStale instead of removing a
+// stale lock, leaving it to an operator or CleanStaleLocks.
+var NeverSteal = StealPolicy{never: true}
+
+// StealAfter removes a stale lock only once it has been seen stale and
+// unchanged at least confirmations times over at least the given
+// duration, so that a holder that is merely slow to renew its lock, for
+// example during long DNS-01 propagation, isn't robbed of it.
+func StealAfter(confirmations int, over time.Duration) StealPolicy {
+	return StealPolicy{confirmations: max(confirmations, 1), within: over}
+}
+
+// WithStealPolicy sets the policy by which Lock steals stale locks.
+func WithStealPolicy(p StealPolicy) Option {
+	return func(s *S3Store) {
+		s.stealPolicy = p
+	}
+}
+
+// stealCheck tracks the observations of a stale lock made by one call
+// to Lock.
+type stealCheck struct {
+	policy StealPolicy
+	etag   string
+	first  time.Time
+	seen   int
+}
+
+// observe records that the lock file with the given ETag was seen stale
+// and reports whether it may be stolen now.
+func (c *stealCheck) observe(etag string) bool {
+	if c.seen == 0 || etag != c.etag {
+		c.etag = etag
+		c.first = time.Now()
+		c.seen = 0
+	}
+	c.seen++
+	return c.seen >= c.policy.confirmations && time.Since(c.first) >= c.policy.within
+}
@@ -0,0 +1,82 @@
+package s3store
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// archiveRestoreDays is how long a copy restored from an archive
+// storage class other than Intelligent-Tiering stays readable.
+const archiveRestoreDays = 7
+
+// storageClassRule is the storage class used for keys under prefix.
+type storageClassRule struct {
+	prefix string
+	class  types.StorageClass
+}
+
+func (r storageClassRule) keyPrefix() string { return r.prefix }
+
+// WithStorageClass writes keys under prefix with the given storage
+// class, e.g. INTELLIGENT_TIERING for issued certificates that are
+// rarely read after startup, while OCSP staples stay in STANDARD. The
+// rule with the longest matching prefix applies; keys outside of all
+// prefixes use the bucket's default.
+//
+// Objects moved to an Intelligent-Tiering archive tier, which has to be
+// opted into in the bucket's configuration, can't be read until they
+// are restored. When a read hits such an object the store requests its
+// restore and returns an error matching ErrArchived, so the read
+// succeeds again once the restore is done instead of failing for good.
+func WithStorageClass(prefix string, class types.StorageClass) Option {
+	return func(s *S3Store) {
+		s.storageClasses = append(s.storageClasses, storageClassRule{
+			prefix: prefix,
+			class:  class,
+		})
+	}
+}
+
+// addStorageClass sets the storage class for key on input, if a rule
+// applies.
+func (s *S3Store) addStorageClass(input *s3.PutObjectInput, key string) {
+	if rule := matchPrefixRule(s.storageClasses, key); rule != nil {
+		input.StorageClass = rule.class
+	}
+}
+
+// restoreArchived requests the restore of the object name if err
+// reports that it is archived. Intelligent-Tiering objects move back to
+// the frequent access tier; other archived objects get a temporary
+// copy.
+func (s *S3Store) restoreArchived(ctx context.Context, name string, err error) {
+	var ios *types.InvalidObjectState
+	if !errors.As(err, &ios) {
+		return
+	}
+	input := &s3.RestoreObjectInput{
+		Bucket: s.bucket,
+		Key:    aws.String(name),
+	}
+	if ios.StorageClass != types.StorageClassIntelligentTiering {
+		input.RestoreRequest = &types.RestoreRequest{Days: aws.Int32(archiveRestoreDays)}
+	}
+	if _, rerr := s.client.RestoreObject(ctx, input); rerr != nil && !isRestoreInProgress(rerr) {
+		log.Printf("[ERROR][%s] Restoring archived %s: %v", s, s.logKey(s.keyName(name)), rerr)
+		return
+	}
+	log.Printf("[WARNING][%s] %s is archived (%s %s), restore requested", s, s.logKey(s.keyName(name)), ios.StorageClass, ios.AccessTier)
+}
+
+// isRestoreInProgress reports whether err is the response to a restore
+// request for an object already being restored.
+func isRestoreInProgress(err error) bool {
+	var ae smithy.APIError
+	return errors.As(err, &ae) && ae.ErrorCode() == "RestoreAlreadyInProgress"
+}
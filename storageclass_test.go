@@ -0,0 +1,37 @@
+package s3store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+func TestStorageClassLongestPrefix(t *testing.T) {
+	ctx := context.Background()
+	s, mem := newTestStore(t,
+		s3store.WithStorageClass("certificates/", types.StorageClassIntelligentTiering),
+		s3store.WithStorageClass("certificates/acme/a.com/", types.StorageClassStandardIa),
+	)
+	tests := map[string]types.StorageClass{
+		"certificates/acme/b.com/b.com.crt": types.StorageClassIntelligentTiering,
+		"certificates/acme/a.com/a.com.crt": types.StorageClassStandardIa,
+		"ocsp/a.com":                        "",
+	}
+	for key, want := range tests {
+		mustStore(t, s, key)
+		out, err := mem.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(testBucket),
+			Key:    aws.String(s.Filename(ctx, key)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.StorageClass != want {
+			t.Errorf("%s stored as %q, want %q", key, out.StorageClass, want)
+		}
+	}
+}
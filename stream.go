@@ -0,0 +1,70 @@
+package s3store
+
+import (
+	"context"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// StoreFrom saves the contents of r at key without holding the whole
+// value in memory. The data is streamed through the upload manager, so
+// r does not need to be seekable or of known length. The replica, if
+// any, gets a server-side copy of the stored object.
+func (s *S3Store) StoreFrom(ctx context.Context, key string, r io.Reader) (err error) {
+	if s.skipDryRun("store", key) {
+		return nil
+	}
+	defer func() { s.mutated(ctx, "store", err, key) }()
+	input := s.putObjectInput(ctx, key, nil)
+	input.Body = r
+	err = s.upload(ctx, input)
+	if input.IfNoneMatch != nil && isPreconditionFailed(err) {
+		err = s.kindError("store", key, ErrExists, err)
+	}
+	if s.cache != nil {
+		s.cache.remove(key)
+	}
+	if err != nil {
+		return s.opError("store", key, err)
+	}
+	return s.replicate(ctx, func(ctx context.Context, r *S3Store) error {
+		return r.copyObject(ctx, aws.ToString(s.bucket), s.Filename(ctx, key), key)
+	})
+}
+
+// OpenReader returns a reader for the value at key, read from the
+// failover bucket like with Load if the primary bucket is unavailable.
+// With checksums enabled, the read returning the end of the value
+// fails with a *ChecksumError if the data doesn't match. The caller
+// must close it when done.
+func (s *S3Store) OpenReader(ctx context.Context, key string) (io.ReadCloser, error) {
+	var result *s3.GetObjectOutput
+	err := s.withFailover(ctx, func(st *S3Store) (err error) {
+		result, err = st.getObject(ctx, &s3.GetObjectInput{
+			Bucket: st.bucket,
+			Key:    aws.String(st.Filename(ctx, key)),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, s.opError("load", key, err)
+	}
+	if s.checksums {
+		return newChecksumReader(key, result.Body, result.ChecksumSHA256), nil
+	}
+	return result.Body, nil
+}
+
+// LoadTo copies the value at key to w and returns
+// the number of bytes written.
+func (s *S3Store) LoadTo(ctx context.Context, key string, w io.Writer) (int64, error) {
+	r, err := s.OpenReader(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	n, err := io.Copy(w, r)
+	return n, s.opError("load", key, err)
+}
@@ -0,0 +1,108 @@
+package s3store_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+// unreachable fails every GetObject on bucket as if
+// the request couldn't be sent.
+type unreachable struct {
+	*memstore.Client
+	bucket string
+}
+
+func (u *unreachable) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if aws.ToString(params.Bucket) == u.bucket {
+		return nil, &smithyhttp.RequestSendError{Err: errors.New("connection refused")}
+	}
+	return u.Client.GetObject(ctx, params, optFns...)
+}
+
+func TestStoreFromReplicates(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket, "replica")
+	s := newTestStoreOn(t, mem, s3store.WithReplica("replica", ""))
+	if err := s.StoreFrom(ctx, "k", strings.NewReader("streamed")); err != nil {
+		t.Fatal(err)
+	}
+	replica := s3store.NewS3Store("replica", "us-east-1", s3store.WithS3API(mem))
+	if v, err := replica.Load(ctx, "k"); err != nil || string(v) != "streamed" {
+		t.Fatalf("replica Load = %q, %v", v, err)
+	}
+}
+
+func TestLoadToFailsOver(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket, "secondary")
+	secondary := s3store.NewS3Store("secondary", "us-east-1", s3store.WithS3API(mem))
+	mustStore(t, secondary, "k")
+
+	s := s3store.NewS3Store(testBucket, "us-east-1",
+		s3store.WithS3API(&unreachable{mem, testBucket}),
+		s3store.WithFailover("secondary", ""))
+	var buf bytes.Buffer
+	if _, err := s.LoadTo(ctx, "k", &buf); err != nil || buf.String() != "k" {
+		t.Fatalf("LoadTo = %q, %v", buf.String(), err)
+	}
+	r, err := s.OpenReader(ctx, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if b, _ := io.ReadAll(r); string(b) != "k" {
+		t.Fatalf("OpenReader read %q", b)
+	}
+}
+
+// corrupting flips the first byte of every object read.
+type corrupting struct {
+	*memstore.Client
+}
+
+func (c corrupting) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	out, err := c.Client.GetObject(ctx, params, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	b, _ := io.ReadAll(out.Body)
+	b[0] ^= 0xff
+	out.Body = io.NopCloser(bytes.NewReader(b))
+	return out, nil
+}
+
+func TestLoadToVerifiesChecksum(t *testing.T) {
+	ctx := context.Background()
+	mem := memstore.New(testBucket)
+	mustStore(t, newTestStoreOn(t, mem, s3store.WithChecksums()), "k")
+
+	s := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(corrupting{mem}), s3store.WithChecksums())
+	var cerr *s3store.ChecksumError
+	if _, err := s.LoadTo(ctx, "k", io.Discard); !errors.As(err, &cerr) {
+		t.Fatalf("LoadTo of corrupted object = %v, want *ChecksumError", err)
+	}
+	r, err := s.OpenReader(ctx, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := io.ReadAll(r); !errors.As(err, &cerr) {
+		t.Fatalf("reading corrupted object = %v, want *ChecksumError", err)
+	}
+
+	intact := newTestStoreOn(t, mem, s3store.WithChecksums())
+	var buf bytes.Buffer
+	if _, err := intact.LoadTo(ctx, "k", &buf); err != nil || buf.String() != "k" {
+		t.Fatalf("LoadTo = %q, %v", buf.String(), err)
+	}
+}
@@ -0,0 +1,37 @@
+package s3store_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+func TestSubStore(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t, s3store.WithPrefix("caddy"), s3store.WithCache(10))
+	prod, staging := s.SubStore("prod"), s.SubStore("staging")
+	mustStore(t, prod, "k")
+	if prod.Prefix() != "caddy/prod" {
+		t.Fatalf("sub-store prefix = %s", prod.Prefix())
+	}
+	if staging.Exists(ctx, "k") {
+		t.Fatal("sibling sub-store sees the key")
+	}
+	if v, err := s.Load(ctx, "prod/k"); err != nil || string(v) != "k" {
+		t.Fatalf("parent Load = %q, %v", v, err)
+	}
+
+	// The sub-store has its own cache, so the parent's key of
+	// the same name doesn't shadow the sub-store's.
+	if err := s.Store(ctx, "k", []byte("parent")); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := prod.Load(ctx, "k"); string(v) != "k" {
+		t.Fatalf("sub-store Load = %q, want its own value", v)
+	}
+	if keys, _ := s.List(ctx, "", false); !reflect.DeepEqual(keys, []string{"k", "prod"}) {
+		t.Fatalf("parent keys = %q", keys)
+	}
+}
@@ -0,0 +1,41 @@
+package s3store
+
+import "net/url"
+
+// WithTags applies the given tags to every object written by Store,
+// e.g. {"app": "caddy"}. Tags can be used for cost allocation,
+// lifecycle rules and tag-based IAM policies.
+func WithTags(tags map[string]string) Option {
+	return func(s *S3Store) {
+		if s.tags == nil {
+			s.tags = make(map[string]string, len(tags))
+		}
+		for k, v := range tags {
+			s.tags[k] = v
+		}
+	}
+}
+
+// WithTagFunc computes additional tags for each stored key, e.g. to
+// tag private keys and certificates differently. Tags returned by fn
+// take precedence over those set with WithTags.
+func WithTagFunc(fn func(key string) map[string]string) Option {
+	return func(s *S3Store) {
+		s.tagFunc = fn
+	}
+}
+
+// tagging returns the URL encoded tag set for key, or an empty
+// string if no tags apply.
+func (s *S3Store) tagging(key string) string {
+	values := url.Values{}
+	for k, v := range s.tags {
+		values.Set(k, v)
+	}
+	if s.tagFunc != nil {
+		for k, v := range s.tagFunc(key) {
+			values.Set(k, v)
+		}
+	}
+	return values.Encode()
+}
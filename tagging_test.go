@@ -0,0 +1,43 @@
+package s3store_test
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+func TestTags(t *testing.T) {
+	api := &recordingInputs{Client: memstore.New(testBucket), puts: make(map[string]*s3.PutObjectInput)}
+	s := s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(api),
+		s3store.WithTags(map[string]string{"app": "caddy", "kind": "other"}),
+		s3store.WithTags(map[string]string{"team": "edge"}),
+		s3store.WithTagFunc(func(key string) map[string]string {
+			if strings.HasSuffix(key, ".key") {
+				return map[string]string{"kind": "private key"}
+			}
+			return nil
+		}))
+	mustStore(t, s, "site.crt", "site.key")
+
+	for name, want := range map[string]url.Values{
+		"certmagic/site.crt": {"app": {"caddy"}, "kind": {"other"}, "team": {"edge"}},
+		"certmagic/site.key": {"app": {"caddy"}, "kind": {"private key"}, "team": {"edge"}},
+	} {
+		got, err := url.ParseQuery(aws.ToString(api.puts[name].Tagging))
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("Tagging of %s = %v, %v, want %v", name, got, err, want)
+		}
+	}
+
+	untagged := &recordingInputs{Client: memstore.New(testBucket), puts: make(map[string]*s3.PutObjectInput)}
+	mustStore(t, s3store.NewS3Store(testBucket, "us-east-1", s3store.WithS3API(untagged)), "k")
+	if tagging := untagged.puts["certmagic/k"].Tagging; tagging != nil {
+		t.Errorf("Tagging without tags = %q, want none", *tagging)
+	}
+}
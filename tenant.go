@@ -0,0 +1,124 @@
+package s3store
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	cm "github.com/caddyserver/certmagic"
+)
+
+// tenantsDir is the directory under the storage prefix
+// that holds each tenant's keys.
+const tenantsDir = "tenants"
+
+// tenantStorage is a view of an S3Store scoped to a single tenant.
+// It rejects keys that would resolve outside the tenant's prefix.
+type tenantStorage struct {
+	s   *S3Store
+	err error
+}
+
+// ForTenant returns a certmagic.Storage whose keys are stored under
+// tenants/<id>/, for platforms issuing certificates on behalf of many
+// customers from one bucket. Keys containing ".." elements or a leading
+// slash are rejected so a tenant can't reach another tenant's data. If
+// id is not a valid single path element, every operation on the
+// returned storage fails.
+func (s *S3Store) ForTenant(id string) cm.Storage {
+	if err := validTenantID(id); err != nil {
+		return &tenantStorage{err: err}
+	}
+	t := s.SubStore(path.Join(tenantsDir, id))
+	if keyID, ok := s.tenantKMSKeys[id]; ok {
+		t.kmsKeyID = keyID
+	}
+	return &tenantStorage{s: t}
+}
+
+func validTenantID(id string) error {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return fmt.Errorf("invalid tenant id %q", id)
+	}
+	return nil
+}
+
+// check returns an error if key could escape the tenant's prefix.
+func (t *tenantStorage) check(key string) error {
+	if t.err != nil {
+		return t.err
+	}
+	if strings.HasPrefix(key, "/") || strings.Contains(key, `\`) {
+		return fmt.Errorf("invalid key %q", key)
+	}
+	for _, elem := range strings.Split(key, "/") {
+		if elem == ".." {
+			return fmt.Errorf("invalid key %q", key)
+		}
+	}
+	return nil
+}
+
+func (t *tenantStorage) Store(ctx context.Context, key string, value []byte) error {
+	if err := t.check(key); err != nil {
+		return err
+	}
+	return t.s.Store(ctx, key, value)
+}
+
+func (t *tenantStorage) Load(ctx context.Context, key string) ([]byte, error) {
+	if err := t.check(key); err != nil {
+		return nil, err
+	}
+	return t.s.Load(ctx, key)
+}
+
+func (t *tenantStorage) Delete(ctx context.Context, key string) error {
+	if err := t.check(key); err != nil {
+		return err
+	}
+	return t.s.Delete(ctx, key)
+}
+
+func (t *tenantStorage) Exists(ctx context.Context, key string) bool {
+	return t.check(key) == nil && t.s.Exists(ctx, key)
+}
+
+func (t *tenantStorage) List(ctx context.Context, prefix string, recursive bool) ([]string, error) {
+	if err := t.check(prefix); err != nil {
+		return nil, err
+	}
+	return t.s.List(ctx, prefix, recursive)
+}
+
+func (t *tenantStorage) Stat(ctx context.Context, key string) (cm.KeyInfo, error) {
+	if err := t.check(key); err != nil {
+		return cm.KeyInfo{}, err
+	}
+	return t.s.Stat(ctx, key)
+}
+
+func (t *tenantStorage) Lock(ctx context.Context, key string) error {
+	if err := t.check(key); err != nil {
+		return err
+	}
+	return t.s.Lock(ctx, key)
+}
+
+func (t *tenantStorage) Unlock(ctx context.Context, key string) error {
+	if err := t.check(key); err != nil {
+		return err
+	}
+	return t.s.Unlock(ctx, key)
+}
+
+func (t *tenantStorage) String() string {
+	if t.err != nil {
+		return "S3Storage:invalid tenant"
+	}
+	return t.s.String()
+}
+
+// Interface guard
+var _ cm.Storage = (*tenantStorage)(nil)
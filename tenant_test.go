@@ -0,0 +1,120 @@
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/certmagic"
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+func TestForTenant(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	a, b := s.ForTenant("a"), s.ForTenant("b")
+	if err := a.Store(ctx, "certificates/x.crt", []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := s.Load(ctx, "tenants/a/certificates/x.crt"); err != nil || string(v) != "a" {
+		t.Fatalf("tenant key stored as %q, %v", v, err)
+	}
+	if b.Exists(ctx, "certificates/x.crt") {
+		t.Fatal("tenant b sees tenant a's key")
+	}
+
+	for _, key := range []string{"../a/certificates/x.crt", "certificates/../../a/x", "/abs", `a\b`} {
+		if err := b.Store(ctx, key, []byte("b")); err == nil {
+			t.Errorf("Store(%q) succeeded", key)
+		}
+		if _, err := b.Load(ctx, key); err == nil {
+			t.Errorf("Load(%q) succeeded", key)
+		}
+	}
+	if _, err := b.List(ctx, "..", true); err == nil {
+		t.Error("List(..) succeeded")
+	}
+
+	for _, id := range []string{"", ".", "..", "a/b"} {
+		if err := s.ForTenant(id).Store(ctx, "k", nil); err == nil {
+			t.Errorf("Store for tenant %q succeeded", id)
+		}
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	a, b := s.ForTenant("a"), s.ForTenant("b")
+	const key = "certificates/x.crt"
+	for _, tenant := range []struct {
+		st    certmagic.Storage
+		value string
+	}{{a, "a"}, {b, "b"}} {
+		if err := tenant.st.Store(ctx, key, []byte(tenant.value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Store(ctx, "certificates/only-a.crt", []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.Stat(ctx, "certificates/only-a.crt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Stat of another tenant's key = %v, want fs.ErrNotExist", err)
+	}
+	if info, err := b.Stat(ctx, key); err != nil || info.Key != key || info.Size != 1 {
+		t.Fatalf("Stat = %+v, %v, want the tenant's own key", info, err)
+	}
+	if keys, err := b.List(ctx, "", true); err != nil || !reflect.DeepEqual(keys, []string{key}) {
+		t.Fatalf("List = %q, %v, want only the tenant's own keys", keys, err)
+	}
+	if err := b.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := a.Load(ctx, key); err != nil || string(v) != "a" {
+		t.Fatalf("tenant a's key after tenant b deleted its own = %q, %v", v, err)
+	}
+
+	// Each tenant has its own locks.
+	for _, st := range []certmagic.Storage{a, b} {
+		lctx, cancel := context.WithTimeout(ctx, time.Second)
+		err := st.Lock(lctx, "issue_cert_example.com")
+		cancel()
+		if err != nil {
+			t.Fatalf("Lock of a name locked by another tenant: %v", err)
+		}
+	}
+	if err := a.Unlock(ctx, "issue_cert_example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Exists(ctx, "tenants/b/locks/issue_cert_example.com.lock") {
+		t.Fatal("Unlock released another tenant's lock")
+	}
+	if err := a.Unlock(ctx, "issue_cert_example.com"); !errors.Is(err, s3store.ErrLockNotHeld) {
+		t.Fatalf("Unlock of a lock held by another tenant = %v, want ErrLockNotHeld", err)
+	}
+	if err := b.Unlock(ctx, "issue_cert_example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"../a/certificates/x.crt", "/abs"} {
+		if err := b.Delete(ctx, key); err == nil {
+			t.Errorf("Delete(%q) succeeded", key)
+		}
+		if _, err := b.Stat(ctx, key); err == nil {
+			t.Errorf("Stat(%q) succeeded", key)
+		}
+		if err := b.Lock(ctx, key); err == nil {
+			t.Errorf("Lock(%q) succeeded", key)
+		}
+		if err := b.Unlock(ctx, key); err == nil {
+			t.Errorf("Unlock(%q) succeeded", key)
+		}
+	}
+	if !a.Exists(ctx, key) {
+		t.Fatal("tenant b deleted tenant a's key through an escaping key")
+	}
+}
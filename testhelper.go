@@ -0,0 +1,29 @@
+package s3store
+
+import (
+	"context"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// NewS3StoreForTesting returns a store for integration tests against a
+// local S3 compatible server such as LocalStack or MinIO listening at
+// endpoint. It uses path-style addressing, the static credentials
+// "test"/"test", allows plain HTTP, skips TLS certificate verification
+// and creates the bucket if it doesn't exist. Never use it against real
+// buckets.
+func NewS3StoreForTesting(endpoint, bucketName string, opts ...Option) *S3Store {
+	testOpts := []Option{
+		WithCredentialsProvider(credentials.NewStaticCredentialsProvider("test", "test", "")),
+		WithEndpoint(endpoint),
+		WithPathStyle(),
+		WithAllowHTTP(),
+		WithInsecureSkipTLSVerify(),
+	}
+	store := NewS3Store(bucketName, "us-east-1", append(testOpts, opts...)...)
+	if _, err := store.createBucketIfMissing(context.TODO()); err != nil {
+		log.Fatal(err)
+	}
+	return store
+}
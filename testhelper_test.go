@@ -0,0 +1,135 @@
+package s3store_test
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+// fakeS3 is a minimal path-style S3 server, just enough for the s3
+// client to create buckets and put and get objects over HTTP.
+type fakeS3 struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	buckets map[string]map[string][]byte
+	auth    []string
+	// status, if set, fails every request with that status code.
+	status int
+	// handle, if set, is called before each request is served.
+	handle func(r *http.Request)
+}
+
+func newFakeS3(t *testing.T) *fakeS3 {
+	t.Helper()
+	f := unstartedFakeS3(t)
+	f.Start()
+	return f
+}
+
+// newFakeS3TLS is newFakeS3 serving HTTPS with a self-signed
+// certificate.
+func newFakeS3TLS(t *testing.T) *fakeS3 {
+	t.Helper()
+	f := unstartedFakeS3(t)
+	f.StartTLS()
+	return f
+}
+
+func unstartedFakeS3(t *testing.T) *fakeS3 {
+	f := &fakeS3{buckets: make(map[string]map[string][]byte)}
+	f.Server = httptest.NewUnstartedServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeS3) serve(w http.ResponseWriter, r *http.Request) {
+	if f.handle != nil {
+		f.handle(r)
+	}
+	body, _ := io.ReadAll(r.Body)
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+	objects, ok := f.buckets[bucket]
+	switch {
+	case f.status != 0:
+		w.WriteHeader(f.status)
+	case key == "" && r.Method == http.MethodPut:
+		f.buckets[bucket] = make(map[string][]byte)
+	case !ok:
+		w.WriteHeader(http.StatusNotFound)
+		if r.Method != http.MethodHead {
+			io.WriteString(w, "<Error><Code>NoSuchBucket</Code></Error>")
+		}
+	case key == "":
+		// HeadBucket of an existing bucket.
+	case r.Method == http.MethodPut:
+		objects[key] = body
+		w.Header().Set("ETag", etagOf(body))
+	case r.Method == http.MethodGet:
+		value, ok := objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, "<Error><Code>NoSuchKey</Code></Error>")
+			return
+		}
+		w.Header().Set("ETag", etagOf(value))
+		w.Write(value)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// fail makes the server fail every request with status,
+// or serve them again if status is 0.
+func (f *fakeS3) fail(status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status = status
+}
+
+func etagOf(value []byte) string {
+	sum := md5.Sum(value)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func TestNewS3StoreForTesting(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeS3(t)
+	s := s3store.NewS3StoreForTesting(f.URL, "certs")
+	if _, ok := f.buckets["certs"]; !ok {
+		t.Fatal("bucket wasn't created")
+	}
+	if err := s.Store(ctx, "a/b", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	name := s.Filename(ctx, "a/b")
+	if v := f.buckets["certs"][name]; string(v) != "value" {
+		t.Fatalf("stored %q at %s", v, name)
+	}
+	if v, err := s.Load(ctx, "a/b"); err != nil || string(v) != "value" {
+		t.Fatalf("Load = %q, %v", v, err)
+	}
+	for _, auth := range f.auth {
+		if !strings.Contains(auth, "Credential=test/") {
+			t.Fatalf("request signed with %q, want the test credentials", auth)
+		}
+	}
+
+	// A second store finds the bucket in place.
+	s3store.NewS3StoreForTesting(f.URL, "certs")
+	if v := f.buckets["certs"][name]; string(v) != "value" {
+		t.Fatal("existing bucket was recreated")
+	}
+}
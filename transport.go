@@ -0,0 +1,110 @@
+package s3store
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"log"
+	"net/http"
+	"net/url"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// WithRootCAs verifies the endpoint's certificate against pool instead
+// of the system roots, e.g. for MinIO or Ceph behind an internal CA. To
+// trust the internal CA in addition to the public ones, start from
+// x509.SystemCertPool.
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(s *S3Store) {
+		s.transportOpts = append(s.transportOpts, func(tr *http.Transport) {
+			tlsConfig(tr).RootCAs = pool
+		})
+	}
+}
+
+// WithTLSConfig uses a copy of cfg for connections to S3, e.g. to
+// present a client certificate or require TLS 1.3. Options applied
+// later, such as WithRootCAs, modify the copy.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(s *S3Store) {
+		s.transportOpts = append(s.transportOpts, func(tr *http.Transport) {
+			tr.TLSClientConfig = cfg.Clone()
+		})
+	}
+}
+
+// WithInsecureSkipTLSVerify disables verification of the endpoint's
+// TLS certificate, for local development against MinIO or similar
+// servers with self-signed certificates. It makes connections open to
+// interception, so the store logs a warning whenever it is used; never
+// enable it in production. Prefer WithRootCAs where possible.
+func WithInsecureSkipTLSVerify() Option {
+	return func(s *S3Store) {
+		s.insecureSkipVerify = true
+		s.transportOpts = append(s.transportOpts, func(tr *http.Transport) {
+			tlsConfig(tr).InsecureSkipVerify = true
+		})
+	}
+}
+
+// WithProxy sends requests to S3 through the HTTP(S) proxy at proxyURL
+// instead of the proxy configured by the HTTPS_PROXY and NO_PROXY
+// environment variables. Hosts matching noProxy, a comma-separated list
+// in the NO_PROXY format (e.g. ".internal,10.0.0.0/8,localhost"), are
+// connected to directly.
+func WithProxy(proxyURL, noProxy string) Option {
+	proxy := (&httpproxy.Config{
+		HTTPProxy:  proxyURL,
+		HTTPSProxy: proxyURL,
+		NoProxy:    noProxy,
+	}).ProxyFunc()
+	return func(s *S3Store) {
+		s.transportOpts = append(s.transportOpts, func(tr *http.Transport) {
+			tr.Proxy = func(req *http.Request) (*url.URL, error) {
+				return proxy(req.URL)
+			}
+		})
+	}
+}
+
+// tlsConfig returns tr's TLS configuration, creating it if needed.
+func tlsConfig(tr *http.Transport) *tls.Config {
+	if tr.TLSClientConfig == nil {
+		tr.TLSClientConfig = &tls.Config{}
+	}
+	return tr.TLSClientConfig
+}
+
+// useTransportOptions applies the store's transport options to the
+// client's HTTP client. A custom *http.Client keeps its settings and
+// gets a modified copy of its transport; HTTP clients whose transport
+// can't be modified are left alone with a warning.
+func (s *S3Store) useTransportOptions(o *s3.Options) {
+	switch c := o.HTTPClient.(type) {
+	case nil:
+		o.HTTPClient = awshttp.NewBuildableClient().WithTransportOptions(s.transportOpts...)
+	case *awshttp.BuildableClient:
+		o.HTTPClient = c.WithTransportOptions(s.transportOpts...)
+	case *http.Client:
+		rt := c.Transport
+		if rt == nil {
+			rt = http.DefaultTransport
+		}
+		tr, ok := rt.(*http.Transport)
+		if !ok {
+			log.Printf("[WARNING][%s] Custom HTTP transport %T can't be configured, ignoring TLS and proxy options", s, rt)
+			return
+		}
+		tr = tr.Clone()
+		for _, opt := range s.transportOpts {
+			opt(tr)
+		}
+		client := *c
+		client.Transport = tr
+		o.HTTPClient = &client
+	default:
+		log.Printf("[WARNING][%s] Custom HTTP client %T can't be configured, ignoring TLS and proxy options", s, c)
+	}
+}
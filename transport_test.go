@@ -0,0 +1,120 @@
+package s3store_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+func TestTransportOptionsKeepCustomClient(t *testing.T) {
+	custom := &http.Client{Timeout: 7 * time.Second}
+	client := s3.New(s3.Options{Region: "us-east-1", HTTPClient: custom})
+	pool := x509.NewCertPool()
+	s := s3store.NewS3StoreFromClient(client, testBucket, s3store.WithRootCAs(pool))
+
+	hc, ok := s.Client().Options().HTTPClient.(*http.Client)
+	if !ok {
+		t.Fatalf("HTTP client replaced by %T", s.Client().Options().HTTPClient)
+	}
+	if hc.Timeout != custom.Timeout {
+		t.Errorf("timeout = %v, want %v", hc.Timeout, custom.Timeout)
+	}
+	tr, ok := hc.Transport.(*http.Transport)
+	if !ok || tr.TLSClientConfig == nil || tr.TLSClientConfig.RootCAs != pool {
+		t.Errorf("root CAs not applied to the custom client's transport")
+	}
+	if custom.Transport != nil {
+		t.Errorf("the caller's client was modified")
+	}
+}
+
+func TestInsecureSkipTLSVerify(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeS3TLS(t)
+	f.buckets["certs"] = make(map[string][]byte)
+	newStore := func(opts ...s3store.Option) *s3store.S3Store {
+		return s3store.NewS3StoreWithCredentials("key", "secret", "certs", "us-east-1",
+			append([]s3store.Option{s3store.WithEndpoint(f.URL), s3store.WithPathStyle()}, opts...)...)
+	}
+
+	if err := newStore().Store(ctx, "k", nil); err == nil {
+		t.Fatal("Store to an endpoint with a self-signed certificate succeeded")
+	}
+	if err := newStore(s3store.WithInsecureSkipTLSVerify()).Store(ctx, "k", []byte("v")); err != nil {
+		t.Fatalf("Store skipping verification: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(f.Certificate())
+	if _, err := newStore(s3store.WithRootCAs(pool)).Load(ctx, "k"); err != nil {
+		t.Fatalf("Load trusting the endpoint's certificate: %v", err)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	ctx := context.Background()
+	f := unstartedFakeS3(t)
+	f.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	f.StartTLS()
+	f.buckets["certs"] = make(map[string][]byte)
+	var handshakes []*tls.ConnectionState
+	f.handle = func(r *http.Request) { handshakes = append(handshakes, r.TLS) }
+	pool := x509.NewCertPool()
+	pool.AddCert(f.Certificate())
+	newStore := func(opts ...s3store.Option) *s3store.S3Store {
+		return s3store.NewS3StoreWithCredentials("key", "secret", "certs", "us-east-1",
+			append([]s3store.Option{s3store.WithEndpoint(f.URL), s3store.WithPathStyle()}, opts...)...)
+	}
+
+	if err := newStore(s3store.WithRootCAs(pool)).Store(ctx, "k", nil); err == nil {
+		t.Fatal("Store without a client certificate succeeded")
+	}
+	certPEM, keyPEM := newCert(t, "client", time.Hour)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS13}
+	if err := newStore(s3store.WithTLSConfig(cfg), s3store.WithRootCAs(pool)).Store(ctx, "k", []byte("v")); err != nil {
+		t.Fatalf("Store with a client certificate: %v", err)
+	}
+	if len(handshakes) != 1 || handshakes[0].Version != tls.VersionTLS13 || len(handshakes[0].PeerCertificates) != 1 {
+		t.Fatalf("connection states = %+v, want one TLS 1.3 connection presenting the certificate", handshakes)
+	}
+	if cfg.RootCAs != nil {
+		t.Fatal("WithRootCAs modified the caller's TLS configuration")
+	}
+}
+
+func TestProxy(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeS3(t)
+	f.buckets["certs"] = make(map[string][]byte)
+	var proxied []string
+	f.handle = func(r *http.Request) { proxied = append(proxied, r.Host) }
+	// The proxy function never proxies loopback addresses, so the
+	// endpoint is given a name that only the proxy, the fake, serves.
+	newStore := func(noProxy string) *s3store.S3Store {
+		return s3store.NewS3StoreWithCredentials("key", "secret", "certs", "us-east-1",
+			s3store.WithEndpoint("http://s3.example.test"), s3store.WithPathStyle(), s3store.WithAllowHTTP(),
+			s3store.WithProxy(f.URL, noProxy))
+	}
+
+	if err := newStore("").Store(ctx, "k", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	if len(proxied) != 1 || proxied[0] != "s3.example.test" {
+		t.Fatalf("proxied requests for %q, want one for s3.example.test", proxied)
+	}
+	tctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	newStore(".example.test").Exists(tctx, "k")
+	if len(proxied) != 1 {
+		t.Fatalf("request for a NO_PROXY host was proxied")
+	}
+}
@@ -0,0 +1,125 @@
+package s3store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// trashDir is the directory under the storage prefix
+// that soft-deleted objects are moved to.
+const trashDir = "trash"
+
+// trashTimeFormat names the per-deletion trash directories
+// so that they sort chronologically.
+const trashTimeFormat = "20060102T150405Z"
+
+// WithSoftDelete makes Delete, DeleteMany and DeletePrefix move objects
+// to trash/<timestamp>/<key> with a server-side copy before deleting
+// them, so operators can recover from accidental deletions. PurgeTrash
+// removes trashed objects older than retention.
+func WithSoftDelete(retention time.Duration) Option {
+	return func(s *S3Store) {
+		s.softDelete = true
+		s.trashRetention = retention
+	}
+}
+
+// moveToTrash copies the named objects into a new trash directory. It
+// returns the names that could not be copied, which must not be deleted.
+// Objects that no longer exist are considered moved.
+func (s *S3Store) moveToTrash(ctx context.Context, names []string) map[string]error {
+	failed := make(map[string]error)
+	stamp := time.Now().UTC().Format(trashTimeFormat)
+	bucket := aws.ToString(s.bucket)
+	for _, name := range names {
+		err := s.copyObject(ctx, bucket, name, path.Join(trashDir, stamp, s.keyName(name)))
+		var nsk *types.NoSuchKey
+		if err != nil && !errors.As(err, &nsk) {
+			failed[name] = err
+		}
+	}
+	return failed
+}
+
+// inTrash reports whether the object name lies in the trash directory.
+func (s *S3Store) inTrash(name string) bool {
+	return strings.HasPrefix(s.keyName(name), trashDir+"/")
+}
+
+// PurgeTrash permanently deletes trashed objects older than the
+// retention given to WithSoftDelete and returns how many were removed,
+// or in dry-run mode how many would have been.
+func (s *S3Store) PurgeTrash(ctx context.Context) (int, error) {
+	var names []string
+	err := s.eachObject(ctx, trashDir, func(obj types.Object) error {
+		parts := strings.SplitN(s.keyName(aws.ToString(obj.Key)), "/", 3)
+		if len(parts) < 3 {
+			return nil
+		}
+		deleted, err := time.Parse(trashTimeFormat, parts[1])
+		if err == nil && time.Since(deleted) > s.trashRetention {
+			names = append(names, aws.ToString(obj.Key))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if s.skipDryRun("purge", trashDir) {
+		return len(names), nil
+	}
+	failed, err := s.deleteObjects(ctx, names)
+	if err != nil {
+		return 0, err
+	}
+	return len(names) - len(failed), nil
+}
+
+// Restore brings back key after an accidental deletion or overwrite.
+// The most recently trashed copy of key is restored if there is one;
+// otherwise, on versioned buckets, the newest version preceding the
+// current one (or the delete marker) is made current again.
+func (s *S3Store) Restore(ctx context.Context, key string) error {
+	var trashed, stamp string
+	err := s.eachObject(ctx, trashDir, func(obj types.Object) error {
+		parts := strings.SplitN(s.keyName(aws.ToString(obj.Key)), "/", 3)
+		if len(parts) == 3 && parts[2] == key && parts[1] > stamp {
+			trashed, stamp = aws.ToString(obj.Key), parts[1]
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if trashed != "" {
+		if s.skipDryRun("restore", key) {
+			return nil
+		}
+		if err := s.copyObject(ctx, aws.ToString(s.bucket), trashed, key); err != nil {
+			return fmt.Errorf("restoring %s from trash: %w", s.logKey(key), err)
+		}
+		if s.cache != nil {
+			s.cache.remove(key)
+		}
+		_, err := s.deleteObjects(ctx, []string{trashed})
+		return err
+	}
+
+	versions, err := s.ListVersions(ctx, key)
+	if err != nil {
+		return err
+	}
+	for i, v := range versions {
+		if i > 0 && !v.DeleteMarker {
+			return s.RestoreVersion(ctx, key, v.ID)
+		}
+	}
+	return fmt.Errorf("no trashed copy or prior version of %s found", s.logKey(key))
+}
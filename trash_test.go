@@ -0,0 +1,53 @@
+package s3store_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+func TestSoftDelete(t *testing.T) {
+	ctx := context.Background()
+	s, mem := newTestStore(t, s3store.WithSoftDelete(time.Hour))
+	mustStore(t, s, "a/k", "a/j", "b")
+	if err := s.Delete(ctx, "a/k"); err != nil {
+		t.Fatal(err)
+	}
+	if s.Exists(ctx, "a/k") {
+		t.Fatal("soft-deleted key still exists")
+	}
+	trashed, err := s.List(ctx, "trash", true)
+	if err != nil || len(trashed) != 1 || !strings.HasSuffix(trashed[0], "/a/k") {
+		t.Fatalf("trash = %q, %v, want a copy of a/k", trashed, err)
+	}
+	if v, _ := s.Load(ctx, trashed[0]); string(v) != "a/k" {
+		t.Fatalf("trashed value = %q", v)
+	}
+
+	// Deleting everything leaves the trash alone.
+	if err := s.DeletePrefix(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	if keys, _ := s.List(ctx, "", false); !reflect.DeepEqual(keys, []string{"trash"}) {
+		t.Fatalf("keys after DeletePrefix = %q, want only the trash", keys)
+	}
+	if n, err := s.PurgeTrash(ctx); err != nil || n != 0 {
+		t.Fatalf("PurgeTrash within retention = %d, %v", n, err)
+	}
+	trashed, _ = s.List(ctx, "trash", true)
+	if len(trashed) != 3 {
+		t.Fatalf("trash = %q, want 3 objects", trashed)
+	}
+
+	purging := newTestStoreOn(t, mem, s3store.WithSoftDelete(0))
+	if n, err := purging.PurgeTrash(ctx); err != nil || n != 3 {
+		t.Fatalf("PurgeTrash after retention = %d, %v, want 3", n, err)
+	}
+	if keys, _ := s.List(ctx, "", true); len(keys) != 0 {
+		t.Fatalf("keys after PurgeTrash = %q", keys)
+	}
+}
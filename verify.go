@@ -0,0 +1,99 @@
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// VerifyError reports the S3 action a Verify step
+// needed and the error it failed with.
+type VerifyError struct {
+	Action string
+	Bucket string
+	Key    string
+	Err    error
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("verifying access: %s on %s/%s failed (check the IAM policy grants it): %v",
+		e.Action, e.Bucket, e.Key, e.Err)
+}
+
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+// Verify checks that the store can do everything certmagic needs by
+// heading the bucket and writing, reading, listing and deleting a canary
+// object under the storage prefix. It is meant to be called at startup so
+// misconfigured deployments fail immediately instead of at the first
+// renewal. In dry-run mode only the bucket is checked. The returned
+// *VerifyError names the action that failed.
+func (s *S3Store) Verify(ctx context.Context) error {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	key := s.Filename(ctx, ".verify-"+hex.EncodeToString(suffix))
+	bucket := aws.ToString(s.bucket)
+	fail := func(action string, err error) error {
+		return &VerifyError{Action: action, Bucket: bucket, Key: key, Err: err}
+	}
+
+	if _, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: s.bucket}); err != nil {
+		return fail("s3:ListBucket", err)
+	}
+	if s.skipDryRun("verify", key) {
+		return nil
+	}
+
+	canary := []byte("s3store verify")
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: s.bucket,
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(canary),
+		ACL:    s.acl,
+	})
+	if err != nil {
+		return fail("s3:PutObject", err)
+	}
+
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{Bucket: s.bucket, Key: aws.String(key)})
+	if err != nil {
+		return fail("s3:GetObject", err)
+	}
+	result.Body.Close()
+
+	if _, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: s.bucket, Key: aws.String(key)}); err != nil {
+		return fail("s3:GetObject", err)
+	}
+
+	list, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: s.bucket, Prefix: aws.String(key)})
+	if err != nil {
+		return fail("s3:ListBucket", err)
+	}
+	if len(list.Contents) == 0 {
+		return fail("s3:ListBucket", fmt.Errorf("canary object not listed"))
+	}
+
+	if _, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: s.bucket, Key: aws.String(key)}); err != nil {
+		return fail("s3:DeleteObject", err)
+	}
+	return nil
+}
+
+// Healthy performs a cheap HeadBucket request and returns an error if
+// the bucket cannot be reached. It is intended for readiness probes and
+// health endpoints.
+func (s *S3Store) Healthy(ctx context.Context) error {
+	if _, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: s.bucket}); err != nil {
+		return fmt.Errorf("%s: bucket unreachable: %w", s, err)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package s3store_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+func TestHealthy(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	if err := s.Healthy(ctx); err != nil {
+		t.Fatalf("Healthy: %v", err)
+	}
+
+	missing := s3store.NewS3Store("missing", "us-east-1", s3store.WithS3API(memstore.New(testBucket)))
+	if err := missing.Healthy(ctx); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("Healthy of missing bucket = %v, want an error naming it", err)
+	}
+
+	f := newFakeS3(t)
+	s = s3store.NewS3StoreForTesting(f.URL, "certs")
+	if err := s.Healthy(ctx); err != nil {
+		t.Fatalf("Healthy over HTTP: %v", err)
+	}
+	f.fail(http.StatusForbidden)
+	if err := s.Healthy(ctx); err == nil {
+		t.Fatal("Healthy of a bucket denying access succeeded")
+	}
+	f.fail(0)
+	if err := s.Healthy(ctx); err != nil {
+		t.Fatalf("Healthy after recovering: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package s3store
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Version describes one version of a key in a bucket with
+// versioning enabled.
+type Version struct {
+	ID           string
+	Modified     time.Time
+	Size         int64
+	IsLatest     bool
+	DeleteMarker bool
+}
+
+// ListVersions returns the versions of key, newest first. Delete
+// markers are included so the history of deletions is visible.
+func (s *S3Store) ListVersions(ctx context.Context, key string) ([]Version, error) {
+	name := s.Filename(ctx, key)
+	var versions []Version
+	paginator := s3.NewListObjectVersionsPaginator(s.client, &s3.ListObjectVersionsInput{
+		Bucket: s.bucket,
+		Prefix: aws.String(name),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range page.Versions {
+			if aws.ToString(v.Key) != name {
+				continue
+			}
+			versions = append(versions, Version{
+				ID:       aws.ToString(v.VersionId),
+				Modified: aws.ToTime(v.LastModified),
+				Size:     aws.ToInt64(v.Size),
+				IsLatest: aws.ToBool(v.IsLatest),
+			})
+		}
+		for _, m := range page.DeleteMarkers {
+			if aws.ToString(m.Key) != name {
+				continue
+			}
+			versions = append(versions, Version{
+				ID:           aws.ToString(m.VersionId),
+				Modified:     aws.ToTime(m.LastModified),
+				IsLatest:     aws.ToBool(m.IsLatest),
+				DeleteMarker: true,
+			})
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].Modified.After(versions[j].Modified)
+	})
+	return versions, nil
+}
+
+// LoadVersion retrieves the value of the given version of key.
+func (s *S3Store) LoadVersion(ctx context.Context, key, versionID string) ([]byte, error) {
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket:    s.bucket,
+		Key:       aws.String(s.Filename(ctx, key)),
+		VersionId: aws.String(versionID),
+	})
+	if err != nil {
+		return nil, s.opError("load version", key, err)
+	}
+	defer result.Body.Close()
+	return io.ReadAll(result.Body)
+}
+
+// RestoreVersion makes the given version of key the current one by
+// copying it over the latest version, e.g. to roll back an accidentally
+// overwritten private key. The newer versions are kept.
+func (s *S3Store) RestoreVersion(ctx context.Context, key, versionID string) error {
+	if s.skipDryRun("restore", key) {
+		return nil
+	}
+	name := s.Filename(ctx, key)
+	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     s.bucket,
+		Key:        aws.String(name),
+		CopySource: aws.String(aws.ToString(s.bucket) + "/" + url.PathEscape(name) + "?versionId=" + url.QueryEscape(versionID)),
+	})
+	if s.cache != nil {
+		s.cache.remove(key)
+	}
+	if err != nil {
+		return fmt.Errorf("restoring version %s of %s: %w", versionID, s.logKey(key), err)
+	}
+	return nil
+}
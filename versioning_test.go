@@ -0,0 +1,45 @@
+package s3store_test
+
+import (
+	"context"
+	"testing"
+
+	s3store "github.com/edwardwc/better-s3store"
+	"github.com/edwardwc/better-s3store/memstore"
+)
+
+func TestVersions(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStoreOn(t, memstore.New(), s3store.WithCreateBucket())
+	for _, v := range []string{"one", "two"} {
+		if err := s.Store(ctx, "k", []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustStore(t, s, "k2")
+	if err := s.Delete(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := s.ListVersions(ctx, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 3 || !versions[0].DeleteMarker || !versions[0].IsLatest || versions[1].DeleteMarker {
+		t.Fatalf("ListVersions = %+v, want a latest delete marker and 2 versions", versions)
+	}
+	first := versions[2].ID
+	if v, err := s.LoadVersion(ctx, "k", first); err != nil || string(v) != "one" {
+		t.Fatalf("LoadVersion = %q, %v", v, err)
+	}
+
+	if err := s.RestoreVersion(ctx, "k", first); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := s.Load(ctx, "k"); err != nil || string(v) != "one" {
+		t.Fatalf("Load after RestoreVersion = %q, %v", v, err)
+	}
+	if versions, _ := s.ListVersions(ctx, "k"); len(versions) != 4 {
+		t.Fatalf("RestoreVersion left %d versions, want 4", len(versions))
+	}
+}
@@ -0,0 +1,57 @@
+package s3store
+
+import (
+	"context"
+	"path"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// defaultWriteOncePatterns match the private keys of certificates.
+var defaultWriteOncePatterns = []string{"certificates/*/*/*.key"}
+
+// WithWriteOnce makes Store, Copy and Move refuse to overwrite existing
+// keys matching any of patterns, as interpreted by path.Match, returning
+// ErrExists instead, so that bugs or misconfigurations can't clobber live private
+// keys. Without patterns it protects the private keys of certificates.
+// Note that certmagic replaces a certificate's private key on renewal
+// unless it is configured to reuse keys; use ForceOverwrite to allow
+// deliberate overwrites.
+func WithWriteOnce(patterns ...string) Option {
+	return func(s *S3Store) {
+		if len(patterns) == 0 {
+			patterns = defaultWriteOncePatterns
+		}
+		s.writeOncePatterns = patterns
+	}
+}
+
+type forceOverwriteKey struct{}
+
+// ForceOverwrite returns a context that lets Store, Copy and Move
+// overwrite keys protected by WithWriteOnce.
+func ForceOverwrite(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceOverwriteKey{}, true)
+}
+
+// addWriteOnce makes input fail if key is protected and exists.
+func (s *S3Store) addWriteOnce(ctx context.Context, input *s3.PutObjectInput, key string) {
+	if s.writeOnce(ctx, key) {
+		input.IfNoneMatch = aws.String("*")
+	}
+}
+
+// writeOnce reports whether writes to key must not overwrite an
+// existing value.
+func (s *S3Store) writeOnce(ctx context.Context, key string) bool {
+	if force, _ := ctx.Value(forceOverwriteKey{}).(bool); force {
+		return false
+	}
+	for _, pattern := range s.writeOncePatterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package s3store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	s3store "github.com/edwardwc/better-s3store"
+)
+
+func TestWriteOnce(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t, s3store.WithWriteOnce())
+	key, crt := "certificates/acme/a.com/a.com.key", "certificates/acme/a.com/a.com.crt"
+	mustStore(t, s, key, crt)
+
+	if err := s.Store(ctx, key, []byte("other")); !errors.Is(err, s3store.ErrExists) {
+		t.Fatalf("overwriting a private key = %v, want ErrExists", err)
+	}
+	if v, _ := s.Load(ctx, key); string(v) != key {
+		t.Fatalf("private key overwritten with %q", v)
+	}
+	if err := s.Store(ctx, crt, []byte("renewed")); err != nil {
+		t.Fatalf("overwriting a certificate: %v", err)
+	}
+	if err := s.Store(s3store.ForceOverwrite(ctx), key, []byte("rotated")); err != nil {
+		t.Fatalf("forced overwrite: %v", err)
+	}
+	if v, _ := s.Load(ctx, key); string(v) != "rotated" {
+		t.Fatalf("private key = %q after a forced overwrite", v)
+	}
+}
+
+func TestWriteOnceCopy(t *testing.T) {
+	ctx := context.Background()
+	s, mem := newTestStore(t, s3store.WithWriteOnce())
+	other := newTestStoreOn(t, mem, s3store.WithPrefix("other"))
+	key := "certificates/acme/a.com/a.com.key"
+	mustStore(t, s, key, "backup")
+	mustStore(t, other, "backup")
+
+	if err := s.Copy(ctx, "backup", key); !errors.Is(err, s3store.ErrExists) {
+		t.Fatalf("copying over a private key = %v, want ErrExists", err)
+	}
+	if err := other.CopyTo(ctx, s, "backup", key); !errors.Is(err, s3store.ErrExists) {
+		t.Fatalf("copying over a private key from another store = %v, want ErrExists", err)
+	}
+	if v, _ := s.Load(ctx, key); string(v) != key {
+		t.Fatalf("private key overwritten with %q", v)
+	}
+	if err := s.Copy(ctx, key, "certificates/acme/b.com/b.com.key"); err != nil {
+		t.Fatalf("copying to a new private key: %v", err)
+	}
+	if err := s.Copy(s3store.ForceOverwrite(ctx), "backup", key); err != nil {
+		t.Fatalf("forced copy: %v", err)
+	}
+	if v, _ := s.Load(ctx, key); string(v) != "backup" {
+		t.Fatalf("private key = %q after a forced copy", v)
+	}
+}
+
+func TestWriteOnceMove(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t, s3store.WithWriteOnce())
+	key := "certificates/acme/a.com/a.com.key"
+	mustStore(t, s, key, "new")
+
+	if err := s.Move(ctx, "new", key); !errors.Is(err, s3store.ErrExists) {
+		t.Fatalf("moving over a private key = %v, want ErrExists", err)
+	}
+	if v, err := s.Load(ctx, "new"); err != nil || string(v) != "new" {
+		t.Fatalf("source of refused move = %q, %v, want it left in place", v, err)
+	}
+	if v, _ := s.Load(ctx, key); string(v) != key {
+		t.Fatalf("private key overwritten with %q", v)
+	}
+	if err := s.Move(s3store.ForceOverwrite(ctx), "new", key); err != nil {
+		t.Fatalf("forced move: %v", err)
+	}
+	if s.Exists(ctx, "new") {
+		t.Fatal("source of forced move still exists")
+	}
+}